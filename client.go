@@ -162,6 +162,7 @@ type Client struct {
 	ReadTimeout  time.Duration     // the net.Conn.SetReadTimeout value for new connections (ns)
 	WriteTimeout time.Duration     // the net.Conn.SetWriteTimeout value for new connections (ns)
 	TsigSecret   map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>
+	TsigProvider TsigProvider      // if not nil, computes and verifies the Tsig MACs instead of TsigSecret
 	Hijacked     net.Conn          // if set the calling code takes care of the connection
 	// LocalAddr string            // Local address to use
 }
@@ -287,6 +288,51 @@ func (c *Client) Exchange(m *Msg, a string) (r *Msg, err error) {
 	return r, nil
 }
 
+// tsigProvider returns the provider for the TSIG key name, or nil when
+// there is no secret for it.
+func (c *Client) tsigProvider(name string) TsigProvider {
+	if c.TsigProvider != nil {
+		return c.TsigProvider
+	}
+	if secret, ok := c.TsigSecret[name]; ok {
+		return tsigHMACProvider(secret)
+	}
+	return nil
+}
+
+// exchangeTsig performs a synchronous query like Exchange, but it also
+// signs m when it has a TSIG record. The reply must then be signed too and
+// its TSIG must validate.
+func (c *Client) exchangeTsig(m *Msg, a string) (*Msg, error) {
+	w := new(reply)
+	w.client = c
+	w.addr = a
+	w.req = m
+	if err := w.Dial(); err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if err := w.Send(m); err != nil {
+		return nil, err
+	}
+	in, err := w.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if in.Id != m.Id {
+		return nil, ErrId
+	}
+	if m.IsTsig() {
+		if !in.IsTsig() {
+			return nil, ErrNoSig
+		}
+		if err := w.TsigStatus(); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
 // Dial connects to the address addr for the network set in c.Net
 func (w *reply) Dial() error {
 	conn, err := net.Dial(w.Client().Net, w.addr)
@@ -331,13 +377,15 @@ func (w *reply) Receive() (*Msg, error) {
 		return nil, ErrUnpack
 	}
 	if m.IsTsig() {
-		secret := m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name
-		if _, ok := w.Client().TsigSecret[secret]; !ok {
+		provider := w.Client().tsigProvider(m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name)
+		if provider == nil {
 			w.tsigStatus = ErrSecret
 			return m, nil
 		}
 		// Need to work on the original message p, as that was used to calculate the tsig.
-		w.tsigStatus = TsigVerify(p, w.Client().TsigSecret[secret], w.tsigRequestMAC, w.tsigTimersOnly)
+		w.tsigStatus = TsigVerifyProvider(p, provider, w.tsigRequestMAC, w.tsigTimersOnly)
+		// The next envelope of a zone transfer is signed using this MAC
+		w.tsigRequestMAC = m.Extra[len(m.Extra)-1].(*RR_TSIG).MAC
 	}
 	return m, nil
 }
@@ -414,11 +462,11 @@ func (w *reply) Send(m *Msg) (err error) {
 	var out []byte
 	if m.IsTsig() {
 		mac := ""
-		name := m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name
-		if _, ok := w.Client().TsigSecret[name]; !ok {
+		provider := w.Client().tsigProvider(m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name)
+		if provider == nil {
 			return ErrSecret
 		}
-		out, mac, err = TsigGenerate(m, w.Client().TsigSecret[name], w.tsigRequestMAC, w.tsigTimersOnly)
+		out, mac, err = TsigGenerateProvider(m, provider, w.tsigRequestMAC, w.tsigTimersOnly)
 		if err != nil {
 			return err
 		}
@@ -443,7 +491,7 @@ func (w *reply) writeClient(p []byte) (n int, err error) {
 	if w.Client().Net == "" {
 		panic("c.Net empty")
 	}
-	if w.Client().Hijacked == nil {
+	if w.Client().Hijacked == nil && w.conn == nil {
 		if err = w.Dial(); err != nil {
 			return 0, err
 		}
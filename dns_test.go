@@ -120,3 +120,38 @@ func TestPack(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpackNoRdata(t *testing.T) {
+	// An unknown type, RFC 3597, with empty rdata
+	rr := &RR_RFC3597{Hdr: RR_Header{Name: "miek.nl.", Rrtype: 65280, Class: ClassINET, Ttl: 3600}}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", 65280)
+	m.Response = true
+	m.Answer = []RR{rr}
+	buf, ok := m.Pack()
+	if !ok {
+		t.Fatal("Failed to pack the response")
+	}
+	in := new(Msg)
+	if !in.Unpack(buf) {
+		t.Fatal("Failed to unpack the response")
+	}
+	// An ordinary response is not affected by the dynamic update rules
+	if _, ok := in.Answer[0].(*RR_RFC3597); !ok {
+		t.Fatalf("Expected an *RR_RFC3597 in a response, got %T", in.Answer[0])
+	}
+
+	// Delete an RRset, RFC 2136 section 2.5.2
+	u := NewUpdate("miek.nl.", ClassINET)
+	u.RRsetDelete([]RR{&RR_TXT{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeTXT, Class: ClassINET, Ttl: 3600}}})
+	buf, ok = u.Pack()
+	if !ok {
+		t.Fatal("Failed to pack the update")
+	}
+	if !in.Unpack(buf) {
+		t.Fatal("Failed to unpack the update")
+	}
+	if h, ok := in.Ns[0].(*RR_Header); !ok || h.Rrtype != TypeTXT || h.Class != ClassANY {
+		t.Fatalf("Expected an *RR_Header in the update section, got %T", in.Ns[0])
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"crypto"
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/md5"
 	"crypto/rand"
@@ -48,6 +49,8 @@ const (
 	ECCGOST          = 12
 	ECDSAP256SHA256  = 13
 	ECDSAP384SHA384  = 14
+	ED25519          = 15
+	ED448            = 16
 	INDIRECT         = 252
 	PRIVATEDNS       = 253 // Private (experimental keys)
 	PRIVATEOID       = 254
@@ -189,7 +192,9 @@ func (k *RR_DNSKEY) ToDS(h int) *RR_DS {
 		io.WriteString(s, string(digest))
 		ds.Digest = hex.EncodeToString(s.Sum(nil))
 	case GOST94:
-		/* I have no clue */
+		s := newGOST94()
+		io.WriteString(s, string(digest))
+		ds.Digest = hex.EncodeToString(s.Sum(nil))
 	default:
 		return nil
 	}
@@ -267,11 +272,15 @@ func (s *RR_RRSIG) Sign(k PrivateKey, rrset []RR) error {
 	case RSASHA512:
 		h = sha512.New()
 		ch = crypto.SHA512
+	case ECCGOST, ED25519:
+		// The data is hashed while signing, or signed as is
 	default:
 		return ErrAlg
 	}
-	io.WriteString(h, string(signdata))
-	sighash = h.Sum(nil)
+	if h != nil {
+		io.WriteString(h, string(signdata))
+		sighash = h.Sum(nil)
+	}
 
 	switch p := k.(type) {
 	case *dsa.PrivateKey:
@@ -294,8 +303,19 @@ func (s *RR_RRSIG) Sign(k PrivateKey, rrset []RR) error {
 		if err != nil {
 			return err
 		}
-		signature := r1.Bytes()
-		signature = append(signature, s1.Bytes()...)
+		// r and s are padded to the size of the curve, RFC 6605 section 4
+		intlen := (p.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*intlen)
+		copy(signature[intlen-len(r1.Bytes()):], r1.Bytes())
+		copy(signature[2*intlen-len(s1.Bytes()):], s1.Bytes())
+		s.Signature = unpackBase64(signature)
+	case ed25519.PrivateKey:
+		s.Signature = unpackBase64(ed25519.Sign(p, signdata))
+	case *gostPrivateKey:
+		signature, err := gostSign(p, signdata)
+		if err != nil {
+			return err
+		}
 		s.Signature = unpackBase64(signature)
 	default:
 		// Not given the correct key
@@ -402,7 +422,7 @@ func (s *RR_RRSIG) Verify(k *RR_DNSKEY, rrset []RR) error {
 		case ECDSAP256SHA256:
 			h = sha256.New()
 		case ECDSAP384SHA384:
-			h = sha512.New()
+			h = sha512.New384()
 		}
 		io.WriteString(h, string(signeddata))
 		sighash := h.Sum(nil)
@@ -411,7 +431,34 @@ func (s *RR_RRSIG) Verify(k *RR_DNSKEY, rrset []RR) error {
 		r.SetBytes(sigbuf[:len(sigbuf)/2])
 		s := big.NewInt(0)
 		s.SetBytes(sigbuf[len(sigbuf)/2:])
-		if ecdsa.Verify(pubkey, sighash, r, s) {
+		if !ecdsa.Verify(pubkey, sighash, r, s) {
+			return ErrSig
+		}
+		return nil
+	case ECCGOST:
+		pubkey := k.publicKeyGOST()
+		if pubkey == nil {
+			return ErrKey
+		}
+		if !gostVerify(pubkey, signeddata, sigbuf) {
+			return ErrSig
+		}
+		return nil
+	case ED25519:
+		pubkey := k.publicKeyED25519()
+		if pubkey == nil {
+			return ErrKey
+		}
+		if !ed25519.Verify(pubkey, signeddata, sigbuf) {
+			return ErrSig
+		}
+		return nil
+	case ED448:
+		pubkey := k.publicKeyED448()
+		if pubkey == nil {
+			return ErrKey
+		}
+		if !ed448Verify(pubkey, signeddata, sigbuf) {
 			return ErrSig
 		}
 		return nil
@@ -421,13 +468,15 @@ func (s *RR_RRSIG) Verify(k *RR_DNSKEY, rrset []RR) error {
 }
 
 // ValidityPeriod uses RFC1982 serial arithmetic to calculate 
-// if a signature period is valid.
+// if a signature period is valid. The inception is taken to be within 68
+// years of now, the expiration to be after the inception.
 func (s *RR_RRSIG) ValidityPeriod() bool {
-	utc := time.Now().UTC().Unix()
-	modi := (int64(s.Inception) - utc) / Year68
-	mode := (int64(s.Expiration) - utc) / Year68
-	ti := int64(s.Inception) + (modi * Year68)
-	te := int64(s.Expiration) + (mode * Year68)
+	return s.validityPeriod(time.Now().UTC().Unix())
+}
+
+func (s *RR_RRSIG) validityPeriod(utc int64) bool {
+	ti := serialTime(s.Inception, utc)
+	te := ti + int64(s.Expiration-s.Inception)
 	return ti <= utc && utc <= te
 }
 
@@ -461,6 +510,11 @@ func (k *RR_DNSKEY) setPublicKeyInPrivate(p PrivateKey) bool {
 			return false
 		}
 		t.PublicKey = *x
+	case ed25519.PrivateKey:
+		// The public key is derived from the seed, check that it matches
+		return bytes.Equal(t.Public().(ed25519.PublicKey), k.publicKeyED25519())
+	case *gostPrivateKey:
+		return bytes.Equal(t.public(), k.publicKeyGOST())
 	}
 	return true
 }
@@ -531,6 +585,34 @@ func (k *RR_DNSKEY) publicKeyCurve() *ecdsa.PublicKey {
 	return pubkey
 }
 
+// publicKeyGOST returns the GOST R 34.10-2001 public key from the DNSKEY
+// record.
+func (k *RR_DNSKEY) publicKeyGOST() []byte {
+	keybuf, err := packBase64([]byte(k.PublicKey))
+	if err != nil || len(keybuf) != 64 {
+		return nil
+	}
+	return keybuf
+}
+
+// publicKeyED25519 returns the Ed25519 public key from the DNSKEY record.
+func (k *RR_DNSKEY) publicKeyED25519() ed25519.PublicKey {
+	keybuf, err := packBase64([]byte(k.PublicKey))
+	if err != nil || len(keybuf) != ed25519.PublicKeySize {
+		return nil
+	}
+	return keybuf
+}
+
+// publicKeyED448 returns the Ed448 public key from the DNSKEY record.
+func (k *RR_DNSKEY) publicKeyED448() []byte {
+	keybuf, err := packBase64([]byte(k.PublicKey))
+	if err != nil || len(keybuf) != ed448PublicKeySize {
+		return nil
+	}
+	return keybuf
+}
+
 func (k *RR_DNSKEY) publicKeyDSA() *dsa.PublicKey {
 	keybuf, err := packBase64([]byte(k.PublicKey))
 	if err != nil {
@@ -639,6 +721,9 @@ func rawSignatureData(rrset []RR, s *RR_RRSIG) (buf []byte) {
 	for i, r := range rrset {
 		r1 := r
 		h1 := r1.Header()
+		// The owner name and TTL are changed below, restore them afterwards
+		name, ttl := h1.Name, h1.Ttl
+		defer func() { h1.Name, h1.Ttl = name, ttl }()
 		labels := SplitLabels(h1.Name)
 		// 6.2. Canonical RR Form. (4) - wildcards
 		if len(labels) > int(s.Labels) {
@@ -741,6 +826,8 @@ var Alg_str = map[uint8]string{
 	ECCGOST:          "ECC-GOST",
 	ECDSAP256SHA256:  "ECDSAP256SHA256",
 	ECDSAP384SHA384:  "ECDSAP384SHA384",
+	ED25519:          "ED25519",
+	ED448:            "ED448",
 	INDIRECT:         "INDIRECT",
 	PRIVATEDNS:       "PRIVATEDNS",
 	PRIVATEOID:       "PRIVATEOID",
@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"bytes"
+	"encoding/hex"
 	"os"
 	"strings"
 	"testing"
@@ -332,3 +334,74 @@ func TestKeyToDS(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// The examples of GOST R 34.11-94 with the CryptoPro parameters.
+func TestGOST94(t *testing.T) {
+	tests := map[string]string{
+		"":               "981e5f3ca30c841487830f84fb433e13ac1101569b9c13584ac483234cd656c0",
+		"abc":            "b285056dbf18d7392d7677369524dd14747459ed8143997e163b2986f92fd42c",
+		"message digest": "bc6041dd2aa401ebfa6e9886734174febdb4729aa972d60f549ac39b29721ba0",
+		"The quick brown fox jumps over the lazy dog":        "9004294a361a508c586fe53d1f1b02746765e71b765472786e4770d565830a76",
+		"Suppose the original message has length = 50 bytes": "c3730c5cbccacf915ac292676f21e8bd4ef75331d9405e5f1a61dc3130a65011",
+	}
+	for in, out := range tests {
+		h := newGOST94()
+		// Write in two parts, to check the buffering
+		h.Write([]byte(in[:len(in)/2]))
+		h.Write([]byte(in[len(in)/2:]))
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != out {
+			t.Logf("GOST R 34.11-94 of %q: expected %s, got %s", in, out, sum)
+			t.Fail()
+		}
+	}
+	// The base point is on the curve and has order q
+	c := gostCryptoProA
+	if x, y, _ := c.affine(c.g); !c.onCurve(x, y) {
+		t.Fatal("Base point not on the curve")
+	}
+	if _, _, ok := c.affine(c.mul(c.g, c.q.m)); ok {
+		t.Fatal("Base point does not have order q")
+	}
+}
+
+// The example of GOST R 34.10-2001, RFC 5832 section 7.1, with its own
+// test curve. The nonce k is given to the signing as the random data.
+func TestGOST3410(t *testing.T) {
+	c := newGOSTCurve(
+		"8000000000000000000000000000000000000000000000000000000000000431",
+		"7",
+		"5FBFF498AA938CE739B8E022FBAFEF40563F6E6A3472FC2A514C0CE9DAE23B7E",
+		"8000000000000000000000000000000150FE8A1892976154C59CFC193ACCF5B3",
+		"2",
+		"08E2A8A0E65147D4BD6316030E16D19C85C97F0A9CA267122B96ABBCEA7E8FC8",
+	)
+	n := func(s string) gostNat {
+		b, _ := hex.DecodeString(s)
+		return gostNatBytes(b)
+	}
+	d := n("7A929ADE789BB9BE10ED359DD39A72C11B60961F49397EEE1D19CE9891EC3B28")
+	qx := n("7F2B49E270DB6D90D8595BEC458B50C58585BA1D4E9B788F6689DBD8E56FD80B")
+	qy := n("26F1B489D6701DD185C8413A977B3CBBAF64D1C593D26627DFFB101A87FF77DA")
+	e := n("2DFBC1B372D89A1188C09C52E0EEC61FCE52032AB1022E8E67ECE6672B043EE5")
+	k := n("77105C9B20BCD3122823C8CF6FCC7B956DE33814E95B7FE64FED924594DCEAB3")
+	r := n("41AA28D2F1AB148280CD9ED56FEDA41974053554A42767B83AD043FD39DC0493")
+	s := n("01456C64BA4642A1653C235A98A60249BCD6D3F746B631DF928014F6C5BF9C40")
+
+	if x, y, _ := c.affine(c.mul(c.g, d)); x != qx || y != qy {
+		t.Fatalf("Wrong public key (%X, %X)", x.bytes(), y.bytes())
+	}
+	r1, s1, err := c.sign(d, e, bytes.NewReader(k.bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if r1 != r || s1 != s {
+		t.Fatalf("Wrong signature (%X, %X)", r1.bytes(), s1.bytes())
+	}
+	if !c.verify(qx, qy, e, r, s) {
+		t.Fatal("Failure to validate the signature")
+	}
+	e[0]++
+	if c.verify(qx, qy, e, r, s) {
+		t.Fatal("Signature of another hash validates")
+	}
+}
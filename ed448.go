@@ -0,0 +1,149 @@
+package dns
+
+// Ed448 signature verification, RFC 8032 section 5.2, as used by DNSSEC
+// algorithm 16 (RFC 8080). This is a straightforward implementation with
+// math/big. It is not constant time, which is fine for verification as it
+// only handles public data, but it can not be used for signing: Ed448 keys
+// can not be generated or used to sign.
+
+import (
+	"crypto/sha3"
+	"math/big"
+)
+
+const (
+	ed448PublicKeySize = 57
+	ed448SignatureSize = 114
+)
+
+// ed448Point is a point in projective coordinates.
+type ed448Point struct{ x, y, z *big.Int }
+
+var (
+	ed448P = new(big.Int).Sub(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 448), new(big.Int).Lsh(big.NewInt(1), 224)), big.NewInt(1))
+	ed448D = new(big.Int).Sub(ed448P, big.NewInt(39081))
+	ed448L = ed448Int("181709681073901722637330951972001133588410340171829515070372549795146003961539585716195755291692375963310293709091662304773755859649779")
+	ed448B = &ed448Point{
+		ed448Int("224580040295924300187604334099896036246789641632564134246125461686950415467406032909029192869357953282578032075146446173674602635247710"),
+		ed448Int("298819210078481492676017930443930673437544040154080242095928241372331506189835876003536878655418784733982303233503462500531545062832660"),
+		big.NewInt(1),
+	}
+	// dom4(0, ""), RFC 8032 section 2
+	ed448Dom = []byte("SigEd448\x00\x00")
+)
+
+func ed448Int(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+// ed448Mod reduces x modulo p, in place.
+func ed448Mod(x *big.Int) *big.Int { return x.Mod(x, ed448P) }
+
+// add returns p + q, RFC 8032 section 5.2.4.
+func (p *ed448Point) add(q *ed448Point) *ed448Point {
+	a := ed448Mod(new(big.Int).Mul(p.z, q.z))
+	b := ed448Mod(new(big.Int).Mul(a, a))
+	c := ed448Mod(new(big.Int).Mul(p.x, q.x))
+	d := ed448Mod(new(big.Int).Mul(p.y, q.y))
+	e := ed448Mod(new(big.Int).Mul(ed448D, new(big.Int).Mul(c, d)))
+	f := ed448Mod(new(big.Int).Sub(b, e))
+	g := ed448Mod(new(big.Int).Add(b, e))
+	h := ed448Mod(new(big.Int).Mul(new(big.Int).Add(p.x, p.y), new(big.Int).Add(q.x, q.y)))
+	x := new(big.Int).Sub(new(big.Int).Sub(h, c), d)
+	x = ed448Mod(x.Mul(x, f).Mul(x, a))
+	y := new(big.Int).Sub(d, c)
+	y = ed448Mod(y.Mul(y, g).Mul(y, a))
+	return &ed448Point{x, y, ed448Mod(new(big.Int).Mul(f, g))}
+}
+
+// mul returns [n]p.
+func (p *ed448Point) mul(n *big.Int) *ed448Point {
+	r := &ed448Point{big.NewInt(0), big.NewInt(1), big.NewInt(1)}
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		r = r.add(r)
+		if n.Bit(i) == 1 {
+			r = r.add(p)
+		}
+	}
+	return r
+}
+
+// equal checks if p and q are the same point.
+func (p *ed448Point) equal(q *ed448Point) bool {
+	x1, x2 := ed448Mod(new(big.Int).Mul(p.x, q.z)), ed448Mod(new(big.Int).Mul(q.x, p.z))
+	y1, y2 := ed448Mod(new(big.Int).Mul(p.y, q.z)), ed448Mod(new(big.Int).Mul(q.y, p.z))
+	return x1.Cmp(x2) == 0 && y1.Cmp(y2) == 0
+}
+
+// ed448Decode decodes a point, RFC 8032 section 5.2.3. It returns nil when
+// buf is not a valid encoding.
+func ed448Decode(buf []byte) *ed448Point {
+	if len(buf) != ed448PublicKeySize || buf[ed448PublicKeySize-1]&0x7F != 0 {
+		return nil
+	}
+	sign := uint(buf[ed448PublicKeySize-1] >> 7)
+	y := ed448FromBytes(buf[:ed448PublicKeySize-1])
+	if y.Cmp(ed448P) >= 0 {
+		return nil
+	}
+	// x^2 = (y^2 - 1) / (d y^2 - 1)
+	y2 := ed448Mod(new(big.Int).Mul(y, y))
+	u := ed448Mod(new(big.Int).Sub(y2, big.NewInt(1)))
+	v := ed448Mod(new(big.Int).Sub(new(big.Int).Mul(ed448D, y2), big.NewInt(1)))
+	x2 := ed448Mod(new(big.Int).Mul(u, new(big.Int).ModInverse(v, ed448P)))
+	e := new(big.Int).Rsh(new(big.Int).Add(ed448P, big.NewInt(1)), 2)
+	x := new(big.Int).Exp(x2, e, ed448P)
+	if ed448Mod(new(big.Int).Mul(x, x)).Cmp(x2) != 0 {
+		return nil
+	}
+	if x.Sign() == 0 && sign == 1 {
+		return nil
+	}
+	if x.Bit(0) != sign {
+		x.Sub(ed448P, x)
+	}
+	return &ed448Point{x, y, big.NewInt(1)}
+}
+
+// ed448FromBytes returns the little-endian integer in buf.
+func ed448FromBytes(buf []byte) *big.Int {
+	b := make([]byte, len(buf))
+	for i, v := range buf {
+		b[len(buf)-1-i] = v
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// ed448Hash returns SHAKE256(dom4 || parts..., 114) as an integer modulo L.
+func ed448Hash(parts ...[]byte) *big.Int {
+	h := sha3.NewSHAKE256()
+	h.Write(ed448Dom)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	buf := make([]byte, 114)
+	h.Read(buf)
+	x := ed448FromBytes(buf)
+	return x.Mod(x, ed448L)
+}
+
+// ed448Verify checks the signature sig of msg made with the public key
+// pub, RFC 8032 section 5.2.7.
+func ed448Verify(pub, msg, sig []byte) bool {
+	if len(sig) != ed448SignatureSize {
+		return false
+	}
+	A := ed448Decode(pub)
+	R := ed448Decode(sig[:57])
+	if A == nil || R == nil {
+		return false
+	}
+	S := ed448FromBytes(sig[57:])
+	if S.Cmp(ed448L) >= 0 {
+		return false
+	}
+	h := ed448Hash(sig[:57], pub, msg)
+	four := big.NewInt(4)
+	return ed448B.mul(S).mul(four).equal(R.add(A.mul(h)).mul(four))
+}
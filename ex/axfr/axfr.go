@@ -30,17 +30,17 @@ func main() {
 		m.SetTsig(name, dns.HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
 	}
 
-	if err := client.XfrReceive(m, *nameserver); err == nil {
-		for r := range client.ReplyChan {
-			if r.Error != nil {
-				if r.Error == dns.ErrXfrLast {
-					fmt.Printf("%v\n", r.Reply)
-				}
-				break
-			}
-			fmt.Printf("%v\n", r.Reply)
-		}
-	} else {
+	ch, err := client.XfrIn(m, *nameserver, nil)
+	if err != nil {
 		fmt.Printf("Error %v\n", err)
+		return
+	}
+	for e := range ch {
+		for _, r := range e.RR {
+			fmt.Printf("%v\n", r)
+		}
+		if e.Error != nil {
+			fmt.Printf("Error %v\n", e.Error)
+		}
 	}
 }
@@ -0,0 +1,87 @@
+package main
+
+// Check a zone file for errors, like named-checkzone does.
+// All problems found are printed one per line, with -json each
+// line is a JSON object with the fields file, name, type, code
+// and error. The exit status is 1 when problems are found.
+import (
+	"dns"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type problem struct {
+	File  string `json:"file"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+func main() {
+	jsn := flag.Bool("json", false, "print the problems as JSON objects, one per line")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-json] ZONE FILE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	origin, file := dns.Fqdn(flag.Arg(0)), flag.Arg(1)
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+	defer f.Close()
+
+	var problems []problem
+	zp := dns.NewZoneParser(f, origin, file)
+	zp.SetErrorRecovery(true)
+	var rrs []dns.RR
+	for {
+		rr, err := zp.Next()
+		if err == io.EOF {
+			break
+		}
+		rrs = append(rrs, rr)
+	}
+	for _, e := range zp.Errors() {
+		problems = append(problems, problem{File: file, Code: "PARSE", Error: e.Error()})
+	}
+	for _, e := range dns.CheckZone(origin, rrs) {
+		p := problem{File: file, Name: e.Name, Code: dns.Check_str[e.Code], Error: e.Err}
+		if e.Type != 0 {
+			if s, ok := dns.Rr_str[e.Type]; ok {
+				p.Type = s
+			} else {
+				p.Type = fmt.Sprintf("TYPE%d", e.Type)
+			}
+		}
+		problems = append(problems, p)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, p := range problems {
+		if *jsn {
+			enc.Encode(p)
+			continue
+		}
+		if p.Name == "" {
+			fmt.Printf("%s\n", p.Error)
+			continue
+		}
+		fmt.Printf("%s: %s %s %s: %s\n", p.File, p.Name, p.Type, p.Code, p.Error)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	if !*jsn {
+		fmt.Printf("zone %s: loaded %d RRs, OK\n", origin, len(rrs))
+	}
+}
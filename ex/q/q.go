@@ -277,7 +277,7 @@ func sectionCheck(set []dns.RR, server string, tcp bool) {
 	}
 }
 
-// Check if we have nsec3 records and if so, check them
+// Check if we have nsec3 or nsec records and if so, check them
 func nsecCheck(in *dns.Msg) {
 	for _, r := range in.Answer {
 		if r.Header().Rrtype == dns.TypeNSEC3 {
@@ -294,6 +294,18 @@ func nsecCheck(in *dns.Msg) {
 			goto Check
 		}
 	}
+	for _, r := range in.Ns {
+		if r.Header().Rrtype == dns.TypeNSEC {
+			goto CheckNsec
+		}
+	}
+	return
+CheckNsec:
+	if w, err := in.NsecVerify(in.Question[0]); err != nil {
+		fmt.Printf(";- [beta] Incorrect denial of existence (NSEC): %s\n", err.Error())
+	} else if w != 0 {
+		fmt.Printf(";+ [beta] Correct denial of existence (NSEC)\n")
+	}
 	return
 Check:
 	w, err := in.Nsec3Verify(in.Question[0])
@@ -302,6 +314,12 @@ Check:
 		fmt.Printf(";+ [beta] Correct denial of existence (NSEC3/NXDOMAIN)\n")
 	case dns.NSEC3_NODATA:
 		fmt.Printf(";+ [beta] Correct denial of existence (NSEC3/NODATA)\n")
+	case dns.NSEC3_WILDCARD:
+		fmt.Printf(";+ [beta] Correct wildcard expansion (NSEC3)\n")
+	case dns.NSEC3_NODS:
+		fmt.Printf(";+ [beta] Correct denial of DS at delegation (NSEC3)\n")
+	case dns.NSEC3_OPTOUT:
+		fmt.Printf(";+ [beta] Insecure denial of existence (NSEC3/opt-out)\n")
 	default:
 		// w == 0
 		if err != nil {
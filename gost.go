@@ -0,0 +1,621 @@
+package dns
+
+// GOST R 34.11-94 hashing and GOST R 34.10-2001 signatures, as used by
+// DNSSEC algorithm 12 and DS digest type 3, RFC 5933. The parameters are
+// the CryptoPro ones of RFC 4357: id-GostR3411-94-CryptoProParamSet for
+// the hash and id-GostR3410-2001-CryptoPro-A-ParamSet for the curve. The
+// curve arithmetic works on 64 bit limbs in Montgomery form and the scalar
+// multiplication is a ladder with complete addition formulas, so the
+// secret key and nonce do not change the steps taken.
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// The S-boxes of id-GostR3411-94-CryptoProParamSet, RFC 4357 section
+// 11.2, K1 first.
+var gostSbox = [8][16]byte{
+	{0xA, 0x4, 0x5, 0x6, 0x8, 0x1, 0x3, 0x7, 0xD, 0xC, 0xE, 0x0, 0x9, 0x2, 0xB, 0xF},
+	{0x5, 0xF, 0x4, 0x0, 0x2, 0xD, 0xB, 0x9, 0x1, 0x7, 0x6, 0x3, 0xC, 0xE, 0xA, 0x8},
+	{0x7, 0xF, 0xC, 0xE, 0x9, 0x4, 0x1, 0x0, 0x3, 0xB, 0x5, 0x2, 0x6, 0xA, 0x8, 0xD},
+	{0x4, 0xA, 0x7, 0xC, 0x0, 0xF, 0x2, 0x8, 0xE, 0x1, 0x6, 0x5, 0xD, 0xB, 0x9, 0x3},
+	{0x7, 0x6, 0x4, 0xB, 0x9, 0xC, 0x2, 0xA, 0x1, 0x8, 0x0, 0xE, 0xF, 0xD, 0x3, 0x5},
+	{0x7, 0x6, 0x2, 0x4, 0xD, 0x9, 0xF, 0x0, 0xA, 0x1, 0x5, 0xB, 0x8, 0xE, 0xC, 0x3},
+	{0xD, 0xE, 0x4, 0x1, 0x7, 0x0, 0x5, 0xA, 0x3, 0xC, 0x8, 0xF, 0x6, 0x2, 0x9, 0xB},
+	{0x1, 0x3, 0xA, 0x9, 0x5, 0xB, 0x4, 0xF, 0x8, 0x6, 0x7, 0xE, 0xD, 0x0, 0x2, 0xC},
+}
+
+// gostEncrypt encrypts the 64 bit block in with GOST 28147-89 in simple
+// substitution mode, with the 256 bit key.
+func gostEncrypt(key, in []byte) []byte {
+	var k [8]uint32
+	for i := range k {
+		k[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	f := func(x uint32) uint32 {
+		var y uint32
+		for i := uint(0); i < 8; i++ {
+			y |= uint32(gostSbox[i][(x>>(4*i))&0xF]) << (4 * i)
+		}
+		return y<<11 | y>>21
+	}
+	n1, n2 := binary.LittleEndian.Uint32(in), binary.LittleEndian.Uint32(in[4:])
+	for i := 0; i < 32; i++ {
+		j := i % 8
+		if i >= 24 {
+			j = 7 - j
+		}
+		n1, n2 = n2^f(n1+k[j]), n1
+	}
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint32(out, n2)
+	binary.LittleEndian.PutUint32(out[4:], n1)
+	return out
+}
+
+// The 256 bit values of GOST R 34.11-94 are little-endian byte slices.
+
+// gostC3 is the constant C3 of the key generation.
+var gostC3 = []byte{
+	0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00,
+	0x00, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0xFF,
+}
+
+func gostXor(a, b []byte) []byte {
+	c := make([]byte, 32)
+	for i := range c {
+		c[i] = a[i] ^ b[i]
+	}
+	return c
+}
+
+// gostA is the function A: y4||y3||y2||y1 -> (y1 xor y2)||y4||y3||y2.
+func gostA(y []byte) []byte {
+	c := make([]byte, 32)
+	copy(c, y[8:])
+	for i := 0; i < 8; i++ {
+		c[24+i] = y[i] ^ y[8+i]
+	}
+	return c
+}
+
+// gostP is the byte transposition P.
+func gostP(y []byte) []byte {
+	c := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for k := 0; k < 8; k++ {
+			c[i+4*k] = y[8*i+k]
+		}
+	}
+	return c
+}
+
+// gostPsi is the function psi on 16 bit words: y16||...||y1 ->
+// (y1 xor y2 xor y3 xor y4 xor y13 xor y16)||y16||...||y2.
+func gostPsi(y []byte) []byte {
+	c := make([]byte, 32)
+	copy(c, y[2:])
+	for _, i := range []int{0, 2, 4, 6, 24, 30} {
+		c[30] ^= y[i]
+		c[31] ^= y[i+1]
+	}
+	return c
+}
+
+// gostStep is the step function f(H, M).
+func gostStep(h, m []byte) []byte {
+	u, v := h, m
+	var s [32]byte
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			u, v = gostA(u), gostA(gostA(v))
+			if i == 2 {
+				u = gostXor(u, gostC3)
+			}
+		}
+		copy(s[8*i:], gostEncrypt(gostP(gostXor(u, v)), h[8*i:8*i+8]))
+	}
+	x := s[:]
+	for i := 0; i < 12; i++ {
+		x = gostPsi(x)
+	}
+	x = gostPsi(gostXor(m, x))
+	x = gostXor(h, x)
+	for i := 0; i < 61; i++ {
+		x = gostPsi(x)
+	}
+	return x
+}
+
+// gostAdd adds b to a, modulo 2^256.
+func gostAdd(a, b []byte) {
+	c := 0
+	for i := range a {
+		c += int(a[i]) + int(b[i])
+		a[i] = byte(c)
+		c >>= 8
+	}
+}
+
+// gost94 is a GOST R 34.11-94 hash.Hash.
+type gost94 struct {
+	h, sum []byte
+	buf    []byte
+	n      uint64 // length in bytes
+}
+
+func newGOST94() hash.Hash {
+	d := new(gost94)
+	d.Reset()
+	return d
+}
+
+func (d *gost94) Reset() {
+	d.h, d.sum, d.buf, d.n = make([]byte, 32), make([]byte, 32), nil, 0
+}
+
+func (d *gost94) Size() int      { return 32 }
+func (d *gost94) BlockSize() int { return 32 }
+
+func (d *gost94) Write(p []byte) (int, error) {
+	d.n += uint64(len(p))
+	d.buf = append(d.buf, p...)
+	for len(d.buf) >= 32 {
+		d.block(d.buf[:32])
+		d.buf = d.buf[32:]
+	}
+	return len(p), nil
+}
+
+func (d *gost94) block(m []byte) {
+	d.h = gostStep(d.h, m)
+	gostAdd(d.sum, m)
+}
+
+func (d *gost94) Sum(in []byte) []byte {
+	e := *d
+	e.h, e.sum = append([]byte{}, d.h...), append([]byte{}, d.sum...)
+	if len(e.buf) > 0 {
+		m := make([]byte, 32)
+		copy(m, e.buf)
+		e.block(m)
+	}
+	l := make([]byte, 32)
+	binary.LittleEndian.PutUint64(l, e.n*8)
+	e.h = gostStep(e.h, l)
+	e.h = gostStep(e.h, e.sum)
+	return append(in, e.h...)
+}
+
+// gostNat is a 256 bit number, 64 bit limbs, least significant first.
+type gostNat [4]uint64
+
+// gostNatBig returns x as a gostNat, x must be smaller than 2^256.
+func gostNatBig(x *big.Int) gostNat {
+	var b [32]byte
+	x.FillBytes(b[:])
+	return gostNatBytes(b[:])
+}
+
+// gostNatBytes returns the 32 big-endian bytes buf as a gostNat.
+func gostNatBytes(buf []byte) gostNat {
+	var x gostNat
+	for i := range x {
+		x[i] = binary.BigEndian.Uint64(buf[24-8*i:])
+	}
+	return x
+}
+
+// gostNatLittle returns the 32 little-endian bytes buf as a gostNat.
+func gostNatLittle(buf []byte) gostNat {
+	var x gostNat
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	return x
+}
+
+// bytes returns x as 32 big-endian bytes.
+func (x gostNat) bytes() []byte {
+	buf := make([]byte, 32)
+	for i := range x {
+		binary.BigEndian.PutUint64(buf[24-8*i:], x[i])
+	}
+	return buf
+}
+
+// little returns x as 32 little-endian bytes.
+func (x gostNat) little() []byte {
+	buf := make([]byte, 32)
+	for i := range x {
+		binary.LittleEndian.PutUint64(buf[8*i:], x[i])
+	}
+	return buf
+}
+
+func (x gostNat) isZero() bool { return x[0]|x[1]|x[2]|x[3] == 0 }
+
+// less returns 1 if x < y and 0 otherwise, in constant time.
+func (x gostNat) less(y gostNat) uint64 {
+	var b uint64
+	for i := range x {
+		_, b = bits.Sub64(x[i], y[i], b)
+	}
+	return b
+}
+
+// gostSelect returns y if c is 1 and x if c is 0, in constant time.
+func gostSelect(c uint64, x, y gostNat) gostNat {
+	mask := -c
+	for i := range x {
+		x[i] ^= mask & (x[i] ^ y[i])
+	}
+	return x
+}
+
+// gostField is the arithmetic modulo a 256 bit odd number m, larger than
+// 2^255. The numbers are in Montgomery form: x is kept as xR mod m, with
+// R = 2^256. All operations are constant time.
+type gostField struct {
+	m   gostNat
+	m0  uint64  // -m^-1 mod 2^64
+	r2  gostNat // R^2 mod m
+	one gostNat // R mod m, 1 in Montgomery form
+	m2  gostNat // m - 2, the exponent of the inverse
+}
+
+func newGOSTField(m *big.Int) *gostField {
+	f := &gostField{m: gostNatBig(m)}
+	inv := uint64(1)
+	for i := 0; i < 6; i++ {
+		inv *= 2 - f.m[0]*inv
+	}
+	f.m0 = -inv
+	r := new(big.Int).Lsh(big.NewInt(1), 256)
+	f.one = gostNatBig(new(big.Int).Mod(r, m))
+	f.r2 = gostNatBig(r.Mod(r.Mul(r, r), m))
+	f.m2 = gostNatBig(new(big.Int).Sub(m, big.NewInt(2)))
+	return f
+}
+
+// reduce returns x mod m, for any x < 2^256 as m > 2^255.
+func (f *gostField) reduce(x gostNat) gostNat {
+	var z gostNat
+	var b uint64
+	for i := range x {
+		z[i], b = bits.Sub64(x[i], f.m[i], b)
+	}
+	return gostSelect(b, z, x)
+}
+
+func (f *gostField) add(x, y gostNat) gostNat {
+	var z, w gostNat
+	var c, b uint64
+	for i := range x {
+		z[i], c = bits.Add64(x[i], y[i], c)
+	}
+	for i := range z {
+		w[i], b = bits.Sub64(z[i], f.m[i], b)
+	}
+	// Keep z when it is smaller than m and did not overflow
+	return gostSelect(b&^c, w, z)
+}
+
+func (f *gostField) sub(x, y gostNat) gostNat {
+	var z, w gostNat
+	var b, c uint64
+	for i := range x {
+		z[i], b = bits.Sub64(x[i], y[i], b)
+	}
+	for i := range z {
+		w[i], c = bits.Add64(z[i], f.m[i], c)
+	}
+	return gostSelect(b, z, w)
+}
+
+// mul returns xyR^-1 mod m, the product of x and y in Montgomery form.
+func (f *gostField) mul(x, y gostNat) gostNat {
+	var t [6]uint64
+	for i := range y {
+		// t += x * y[i]
+		var c, hi, lo, cc uint64
+		for j := range x {
+			hi, lo = bits.Mul64(x[j], y[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[4], t[5] = bits.Add64(t[4], c, 0)
+		// t = (t + u * m) / 2^64, with u such that the low limb is 0
+		u := t[0] * f.m0
+		hi, lo = bits.Mul64(u, f.m[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(u, f.m[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[3], cc = bits.Add64(t[4], c, 0)
+		t[4] = t[5] + cc
+	}
+	// t < 2m, subtract m when t >= m
+	var z gostNat
+	var b uint64
+	for i := range z {
+		z[i], b = bits.Sub64(t[i], f.m[i], b)
+	}
+	_, b = bits.Sub64(t[4], 0, b)
+	return gostSelect(b, z, gostNat{t[0], t[1], t[2], t[3]})
+}
+
+// toMont returns x, smaller than m, in Montgomery form.
+func (f *gostField) toMont(x gostNat) gostNat { return f.mul(x, f.r2) }
+
+// fromMont returns x in Montgomery form as a number.
+func (f *gostField) fromMont(x gostNat) gostNat { return f.mul(x, gostNat{1}) }
+
+// inv returns the inverse of x, x^(m-2), 0 when x is 0. The exponent is
+// public, only its bits decide the steps.
+func (f *gostField) inv(x gostNat) gostNat {
+	z := f.one
+	for i := 255; i >= 0; i-- {
+		z = f.mul(z, z)
+		if (f.m2[i/64]>>uint(i%64))&1 == 1 {
+			z = f.mul(z, x)
+		}
+	}
+	return z
+}
+
+// gostPoint is a point in projective coordinates, in Montgomery form
+// modulo p. The point at infinity is (0:1:0).
+type gostPoint struct {
+	x, y, z gostNat
+}
+
+// gostCurve is a GOST R 34.10-2001 curve: y^2 = x^3 + ax + b modulo p,
+// the base point g has order q. The curve must have prime order, q, for
+// the addition formulas to be complete.
+type gostCurve struct {
+	p, q     *gostField
+	a, b, b3 gostNat // a, b and 3b in Montgomery form
+	g        gostPoint
+}
+
+// newGOSTCurve returns the curve with the hexadecimal parameters p, a, b,
+// q and base point (x, y).
+func newGOSTCurve(p, a, b, q, x, y string) *gostCurve {
+	n := func(s string) *big.Int {
+		i, _ := new(big.Int).SetString(s, 16)
+		return i
+	}
+	c := &gostCurve{p: newGOSTField(n(p)), q: newGOSTField(n(q))}
+	c.a = c.p.toMont(gostNatBig(n(a)))
+	c.b = c.p.toMont(gostNatBig(n(b)))
+	c.b3 = c.p.add(c.p.add(c.b, c.b), c.b)
+	c.g = c.point(gostNatBig(n(x)), gostNatBig(n(y)))
+	return c
+}
+
+// gostCryptoProA is id-GostR3410-2001-CryptoPro-A-ParamSet, RFC 4357
+// section 11.4.
+var gostCryptoProA = newGOSTCurve(
+	"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFD97",
+	"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFD94",
+	"A6",
+	"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF6C611070995AD10045841B09B761B893",
+	"1",
+	"8D91E471E0989CDA27DF505A453F2B7635294F2DDF23E3B122ACC99C9E9F1E14",
+)
+
+// point returns the affine point (x, y).
+func (c *gostCurve) point(x, y gostNat) gostPoint {
+	return gostPoint{c.p.toMont(x), c.p.toMont(y), c.p.one}
+}
+
+// onCurve checks that the affine point (x, y) is on the curve.
+func (c *gostCurve) onCurve(x, y gostNat) bool {
+	if x.less(c.p.m) == 0 || y.less(c.p.m) == 0 {
+		return false
+	}
+	f := c.p
+	pt := c.point(x, y)
+	return f.mul(pt.y, pt.y) == f.add(f.mul(f.add(f.mul(pt.x, pt.x), c.a), pt.x), c.b)
+}
+
+// add returns p1 + p2, with the complete addition formulas for any a of
+// Renes, Costello and Batina, "Complete addition formulas for prime order
+// elliptic curves", algorithm 1. It also doubles.
+func (c *gostCurve) add(p1, p2 gostPoint) gostPoint {
+	f := c.p
+	t0 := f.mul(p1.x, p2.x)
+	t1 := f.mul(p1.y, p2.y)
+	t2 := f.mul(p1.z, p2.z)
+	t3 := f.mul(f.add(p1.x, p1.y), f.add(p2.x, p2.y))
+	t3 = f.sub(t3, f.add(t0, t1))
+	t4 := f.mul(f.add(p1.x, p1.z), f.add(p2.x, p2.z))
+	t4 = f.sub(t4, f.add(t0, t2))
+	t5 := f.mul(f.add(p1.y, p1.z), f.add(p2.y, p2.z))
+	t5 = f.sub(t5, f.add(t1, t2))
+	z3 := f.add(f.mul(c.b3, t2), f.mul(c.a, t4))
+	x3 := f.sub(t1, z3)
+	z3 = f.add(t1, z3)
+	y3 := f.mul(x3, z3)
+	t1 = f.add(f.add(t0, t0), t0)
+	t2 = f.mul(c.a, t2)
+	t4 = f.mul(c.b3, t4)
+	t1 = f.add(t1, t2)
+	t2 = f.mul(c.a, f.sub(t0, t2))
+	t4 = f.add(t4, t2)
+	y3 = f.add(y3, f.mul(t1, t4))
+	x3 = f.sub(f.mul(x3, t3), f.mul(t5, t4))
+	z3 = f.add(f.mul(z3, t5), f.mul(t3, t1))
+	return gostPoint{x3, y3, z3}
+}
+
+// swap swaps p1 and p2 if s is 1, in constant time.
+func gostSwap(s uint64, p1, p2 *gostPoint) {
+	x, y, z := p1.x, p1.y, p1.z
+	p1.x, p1.y, p1.z = gostSelect(s, p1.x, p2.x), gostSelect(s, p1.y, p2.y), gostSelect(s, p1.z, p2.z)
+	p2.x, p2.y, p2.z = gostSelect(s, p2.x, x), gostSelect(s, p2.y, y), gostSelect(s, p2.z, z)
+}
+
+// mul returns [k]pt with a Montgomery ladder over all 256 bits of k, in
+// constant time.
+func (c *gostCurve) mul(pt gostPoint, k gostNat) gostPoint {
+	r0, r1 := gostPoint{y: c.p.one}, pt
+	for i := 255; i >= 0; i-- {
+		b := (k[i/64] >> uint(i%64)) & 1
+		gostSwap(b, &r0, &r1)
+		r1 = c.add(r0, r1)
+		r0 = c.add(r0, r0)
+		gostSwap(b, &r0, &r1)
+	}
+	return r0
+}
+
+// affine returns the affine coordinates of pt, false for the point at
+// infinity.
+func (c *gostCurve) affine(pt gostPoint) (x, y gostNat, ok bool) {
+	f := c.p
+	zinv := f.inv(pt.z)
+	return f.fromMont(f.mul(pt.x, zinv)), f.fromMont(f.mul(pt.y, zinv)), !pt.z.isZero()
+}
+
+// random returns a random number in [1, q) read from r.
+func (c *gostCurve) random(r io.Reader) (gostNat, error) {
+	buf := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return gostNat{}, err
+		}
+		k := gostNatBytes(buf)
+		if k.less(c.q.m) == 1 && !k.isZero() {
+			return k, nil
+		}
+	}
+}
+
+// hash returns the hash e, as an integer, modulo q and 1 when that is 0.
+func (c *gostCurve) hash(e gostNat) gostNat {
+	e = c.q.reduce(e)
+	if e.isZero() {
+		e[0] = 1
+	}
+	return e
+}
+
+// sign signs the hash e, as an integer, with the private key d, the nonce
+// is read from rand. GOST R 34.10-2001 section 6.1 (RFC 5832 section
+// 6.1).
+func (c *gostCurve) sign(d, e gostNat, rand io.Reader) (r, s gostNat, err error) {
+	f := c.q
+	e = f.toMont(c.hash(e))
+	for {
+		k, err := c.random(rand)
+		if err != nil {
+			return r, s, err
+		}
+		x, _, _ := c.affine(c.mul(c.g, k))
+		if r = f.reduce(x); r.isZero() {
+			continue
+		}
+		// s = rd + ke mod q
+		s = f.fromMont(f.add(f.mul(f.toMont(r), f.toMont(d)), f.mul(f.toMont(k), e)))
+		if !s.isZero() {
+			return r, s, nil
+		}
+	}
+}
+
+// verify checks the signature (r, s) of the hash e, as an integer, made
+// with the public key (qx, qy), GOST R 34.10-2001 section 7 (RFC 5832
+// section 6.2). Only public data is handled, it is not constant time.
+func (c *gostCurve) verify(qx, qy, e, r, s gostNat) bool {
+	f := c.q
+	if s.isZero() || r.isZero() || s.less(f.m) == 0 || r.less(f.m) == 0 || !c.onCurve(qx, qy) {
+		return false
+	}
+	v := f.inv(f.toMont(c.hash(e)))
+	z1 := f.fromMont(f.mul(f.toMont(s), v))
+	z2 := f.fromMont(f.sub(gostNat{}, f.mul(f.toMont(r), v)))
+	x, _, ok := c.affine(c.add(c.mul(c.g, z1), c.mul(c.point(qx, qy), z2)))
+	return ok && f.reduce(x) == r
+}
+
+// gostAsn1Prefix is the start of the GostAsn1 private key of the key files,
+// the PKCS#8 encoding of the private key with the CryptoPro-A curve and
+// hash parameters, RFC 5933 section 2.2. The private key, 32 little-endian
+// bytes, follows.
+var gostAsn1Prefix = []byte{
+	0x30, 0x45, 0x02, 0x01, 0x00, 0x30, 0x1c, 0x06, 0x06, 0x2a, 0x85, 0x03, 0x02, 0x02, 0x13, 0x30,
+	0x12, 0x06, 0x07, 0x2a, 0x85, 0x03, 0x02, 0x02, 0x23, 0x01, 0x06, 0x07, 0x2a, 0x85, 0x03, 0x02,
+	0x02, 0x1e, 0x01, 0x04, 0x22, 0x04, 0x20,
+}
+
+// gostPrivateKey is a GOST R 34.10-2001 private key with its public key.
+type gostPrivateKey struct {
+	d    gostNat
+	x, y gostNat
+}
+
+// newGOSTKey returns the private key for d, which must be in [1, q).
+func newGOSTKey(d gostNat) *gostPrivateKey {
+	c := gostCryptoProA
+	x, y, _ := c.affine(c.mul(c.g, d))
+	return &gostPrivateKey{d, x, y}
+}
+
+// generateGOSTKey generates a private key with randomness from r.
+func generateGOSTKey(r io.Reader) (*gostPrivateKey, error) {
+	d, err := gostCryptoProA.random(r)
+	if err != nil {
+		return nil, err
+	}
+	return newGOSTKey(d), nil
+}
+
+// public returns the public key as in the DNSKEY, x and y little-endian,
+// RFC 5933 section 2.1.
+func (k *gostPrivateKey) public() []byte {
+	return append(k.x.little(), k.y.little()...)
+}
+
+// gostHash returns the GOST R 34.11-94 hash of data as the integer e of
+// GOST R 34.10-2001: the hash is read as a little-endian number.
+func gostHash(data []byte) gostNat {
+	h := newGOST94()
+	h.Write(data)
+	return gostNatLittle(h.Sum(nil))
+}
+
+// gostSign signs data with k. The signature is s followed by r, RFC 5933
+// section 2.2 and RFC 4490 section 3.2.
+func gostSign(k *gostPrivateKey, data []byte) ([]byte, error) {
+	r, s, err := gostCryptoProA.sign(k.d, gostHash(data), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return append(s.bytes(), r.bytes()...), nil
+}
+
+// gostVerify checks the signature sig of data made with the public key
+// pub, x and y little-endian.
+func gostVerify(pub, data, sig []byte) bool {
+	if len(pub) != 64 || len(sig) != 64 {
+		return false
+	}
+	s, r := gostNatBytes(sig[:32]), gostNatBytes(sig[32:])
+	return gostCryptoProA.verify(gostNatLittle(pub[:32]), gostNatLittle(pub[32:]), gostHash(data), r, s)
+}
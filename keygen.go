@@ -2,6 +2,7 @@ package dns
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -20,8 +21,10 @@ type PrivateKey interface{}
 // The public part is put inside the DNSKEY record. 
 // The Algorithm in the key must be set as this will define
 // what kind of DNSKEY will be generated.
-// The ECDSA algorithms imply a fixed keysize, in that case
+// The ECDSA and EdDSA algorithms imply a fixed keysize, in that case
 // bits should be set to the size of the algorithm.
+// ED448 keys can not be generated, ErrAlg is returned: only verification
+// is supported for that algorithm.
 func (r *RR_DNSKEY) Generate(bits int) (PrivateKey, error) {
 	switch r.Algorithm {
 	case DSA, DSANSEC3SHA1:
@@ -44,6 +47,10 @@ func (r *RR_DNSKEY) Generate(bits int) (PrivateKey, error) {
 		if bits != 384 {
 			return nil, ErrKeySize
 		}
+	case ECCGOST, ED25519:
+		if bits != 256 {
+			return nil, ErrKeySize
+		}
 	}
 
 	switch r.Algorithm {
@@ -81,6 +88,20 @@ func (r *RR_DNSKEY) Generate(bits int) (PrivateKey, error) {
 		}
 		r.setPublicKeyCurve(priv.PublicKey.X, priv.PublicKey.Y)
 		return priv, nil
+	case ECCGOST:
+		priv, err := generateGOSTKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r.PublicKey = unpackBase64(priv.public())
+		return priv, nil
+	case ED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r.PublicKey = unpackBase64(pub)
+		return priv, nil
 	default:
 		return nil, ErrAlg
 	}
@@ -130,6 +151,18 @@ func (r *RR_DNSKEY) PrivateKeyString(p PrivateKey) (s string) {
 		s = _FORMAT +
 			"Algorithm: " + algorithm + "\n" +
 			"PrivateKey: " + private + "\n"
+	case *gostPrivateKey:
+		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + Alg_str[r.Algorithm] + ")"
+		private := unpackBase64(append(append([]byte{}, gostAsn1Prefix...), t.d.little()...))
+		s = _FORMAT +
+			"Algorithm: " + algorithm + "\n" +
+			"GostAsn1: " + private + "\n"
+	case ed25519.PrivateKey:
+		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + Alg_str[r.Algorithm] + ")"
+		private := unpackBase64(t.Seed())
+		s = _FORMAT +
+			"Algorithm: " + algorithm + "\n" +
+			"PrivateKey: " + private + "\n"
 	case *dsa.PrivateKey:
 		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + Alg_str[r.Algorithm] + ")"
 		prime := unpackBase64(t.PublicKey.Parameters.P.Bytes())
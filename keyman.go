@@ -0,0 +1,348 @@
+package dns
+
+// Managing the keys of a signed zone: the timing metadata of the keys and
+// planning key rollovers, see RFC 6781 section 4.1 and RFC 7583.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManagedKey is a SigningKey with the timing metadata BIND keeps with its
+// keys. A zero Publish or Activate means since ever, a zero Inactive or
+// Delete means never.
+type ManagedKey struct {
+	*SigningKey
+	Created     time.Time
+	Publish     time.Time // the DNSKEY is in the zone from Publish
+	Activate    time.Time // the key signs from Activate
+	Inactive    time.Time // the key no longer signs from Inactive
+	Delete      time.Time // the DNSKEY is removed from the zone at Delete
+	SyncPublish time.Time // the DS is in the parent zone from SyncPublish, Publish when zero
+	SyncDelete  time.Time // the DS is removed from the parent zone at SyncDelete, Delete when zero
+}
+
+// The names of the timing metadata in the key files, in the order they
+// are written.
+var keyTiming_str = []string{"Created", "Publish", "Activate", "Inactive", "Delete", "SyncPublish", "SyncDelete"}
+
+// timing returns pointers to the timing metadata in the order of
+// keyTiming_str.
+func (k *ManagedKey) timing() []*time.Time {
+	return []*time.Time{&k.Created, &k.Publish, &k.Activate, &k.Inactive, &k.Delete, &k.SyncPublish, &k.SyncDelete}
+}
+
+// within checks if t is in [from, until), a zero from or until is
+// unbounded.
+func within(t, from, until time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (until.IsZero() || t.Before(until))
+}
+
+// Published checks if the DNSKEY is in the zone at t.
+func (k *ManagedKey) Published(t time.Time) bool { return within(t, k.Publish, k.Delete) }
+
+// Active checks if the key signs the zone at t.
+func (k *ManagedKey) Active(t time.Time) bool {
+	return k.Published(t) && within(t, k.Activate, k.Inactive)
+}
+
+// Synced checks if the DS of the key is in the parent zone at t. Only keys
+// with the SEP flag have a DS.
+func (k *ManagedKey) Synced(t time.Time) bool {
+	if k.DNSKEY.Flags&SEP == 0 {
+		return false
+	}
+	from, until := k.SyncPublish, k.SyncDelete
+	if from.IsZero() {
+		from = k.Publish
+	}
+	if until.IsZero() {
+		until = k.Delete
+	}
+	return within(t, from, until)
+}
+
+// FileName returns the base name of the key files, without the .key or
+// .private extension, as used by BIND: Kmiek.nl.+008+12345.
+func (k *ManagedKey) FileName() string {
+	alg := strconv.Itoa(int(k.DNSKEY.Algorithm))
+	tag := strconv.Itoa(int(k.DNSKEY.KeyTag()))
+	return "K" + strings.ToLower(Fqdn(k.DNSKEY.Hdr.Name)) + "+" + strings.Repeat("0", 3-len(alg)) + alg +
+		"+" + strings.Repeat("0", 5-len(tag)) + tag
+}
+
+// WriteFiles writes the key to the .key and .private files in dir, in the
+// format of BIND. The timing metadata is put in both files.
+func (k *ManagedKey) WriteFiles(dir string) error {
+	var pub, priv bytes.Buffer
+	kind := "zone-signing"
+	if k.DNSKEY.Flags&SEP != 0 {
+		kind = "key-signing"
+	}
+	pub.WriteString("; This is a " + kind + " key, keyid " + strconv.Itoa(int(k.DNSKEY.KeyTag())) + ", for " + k.DNSKEY.Hdr.Name + "\n")
+	priv.WriteString(k.DNSKEY.PrivateKeyString(k.Private))
+	for i, t := range k.timing() {
+		if t.IsZero() {
+			continue
+		}
+		date := t.UTC().Format("20060102150405")
+		pub.WriteString("; " + keyTiming_str[i] + ": " + date + " (" + t.UTC().Format(time.ANSIC) + ")\n")
+		priv.WriteString(keyTiming_str[i] + ": " + date + "\n")
+	}
+	pub.WriteString(k.DNSKEY.String() + "\n")
+	file := filepath.Join(dir, k.FileName())
+	if err := ioutil.WriteFile(file+".key", pub.Bytes(), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file+".private", priv.Bytes(), 0600)
+}
+
+// ReadManagedKey reads a key from the .key and .private files written by
+// BIND or WriteFiles. The name of either file, or their common base name,
+// can be given. The timing metadata is read from the .private file.
+func ReadManagedKey(file string) (*ManagedKey, error) {
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".key"), ".private")
+	f, err := os.Open(file + ".key")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ReadRR(f, file+".key")
+	if err != nil {
+		return nil, err
+	}
+	key, ok := r.(*RR_DNSKEY)
+	if !ok {
+		return nil, &Error{Err: "dns: no DNSKEY in key file", Name: file + ".key"}
+	}
+	b, err := ioutil.ReadFile(file + ".private")
+	if err != nil {
+		return nil, err
+	}
+	priv, err := key.ReadPrivateKey(bytes.NewReader(b), file+".private")
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseKey(bytes.NewReader(b), file+".private")
+	if err != nil {
+		return nil, err
+	}
+	k := &ManagedKey{SigningKey: &SigningKey{key, priv}}
+	for i, t := range k.timing() {
+		v, ok := m[strings.ToLower(keyTiming_str[i])]
+		if !ok {
+			continue
+		}
+		if *t, err = time.Parse("20060102150405", v); err != nil {
+			return nil, &Error{Err: "dns: bad key timing", Name: keyTiming_str[i] + ": " + v}
+		}
+	}
+	return k, nil
+}
+
+// Rollover holds the durations that determine how long each step of a
+// key rollover takes, RFC 7583 section 3.
+type Rollover struct {
+	DNSKEYTTL         time.Duration // TTL of the DNSKEY RRset
+	MaxTTL            time.Duration // largest TTL of the signed RRsets in the zone
+	DSTTL             time.Duration // TTL of the DS RRset in the parent zone
+	Propagation       time.Duration // time for a change to reach all the name servers of the zone
+	ParentPropagation time.Duration // time for a DS submitted to the parent to reach all its name servers
+	ResignDelay       time.Duration // time needed to replace all signatures made by the old key, zero when the zone is signed completely
+	Safety            time.Duration // margin added to each wait
+}
+
+// ZSK plans a pre-publish rollover from the zone signing key old to next,
+// starting at at. The DNSKEY of next is published at at, and next replaces
+// old once the DNSKEY RRset with next has expired from all caches. The
+// DNSKEY of old is removed once the signatures made by old have expired.
+func (r *Rollover) ZSK(old, next *ManagedKey, at time.Time) {
+	next.Publish = at
+	next.Activate = at.Add(r.Propagation + r.DNSKEYTTL + r.Safety)
+	old.Inactive = next.Activate
+	old.Delete = old.Inactive.Add(r.ResignDelay + r.Propagation + r.MaxTTL + r.Safety)
+}
+
+// KSK plans a double-DS rollover from the key signing key old to next,
+// starting at at. The DS of next is submitted to the parent at at. Once
+// the DS RRset with both DS records is in all caches, the DNSKEY of old is
+// replaced by next, and the DS of old is removed once the old DNSKEY RRset
+// has expired from all caches.
+func (r *Rollover) KSK(old, next *ManagedKey, at time.Time) {
+	next.SyncPublish = at
+	next.Publish = at.Add(r.ParentPropagation + r.DSTTL + r.Safety)
+	next.Activate = next.Publish
+	old.Inactive, old.Delete = next.Publish, next.Publish
+	old.SyncDelete = next.Publish.Add(r.Propagation + r.DNSKEYTTL + r.Safety)
+}
+
+// KeyManager manages the keys of a zone, stored in the key files of BIND.
+// It tells which keys are published and sign the zone at a given time.
+type KeyManager struct {
+	Zone  string
+	Keys  []*ManagedKey
+	Dir   string // if not empty, the directory the key files are written to
+	Clock Clock  // if nil the system clock is used
+}
+
+// ReadKeyManager reads the keys of zone from the key files in dir.
+func ReadKeyManager(dir, zone string) (*KeyManager, error) {
+	m := &KeyManager{Zone: strings.ToLower(Fqdn(zone)), Dir: dir}
+	files, err := filepath.Glob(filepath.Join(dir, "K"+m.Zone+"+*.private"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		k, err := ReadManagedKey(f)
+		if err != nil {
+			return nil, err
+		}
+		m.Keys = append(m.Keys, k)
+	}
+	return m, nil
+}
+
+// Save writes the files of all keys to Dir.
+func (m *KeyManager) Save() error {
+	for _, k := range m.Keys {
+		if err := k.WriteFiles(m.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewKey generates a key for the zone with the flags, algorithm and size,
+// see RR_DNSKEY.Generate, and adds it. The key has no timing set except
+// Created, so it is published and active until a rollover is planned.
+// When Dir is set the key files are written.
+func (m *KeyManager) NewKey(flags uint16, algorithm uint8, bits int) (*ManagedKey, error) {
+	key := &RR_DNSKEY{Hdr: RR_Header{Name: m.Zone, Rrtype: TypeDNSKEY, Class: ClassINET, Ttl: 3600},
+		Flags: flags, Protocol: 3, Algorithm: algorithm}
+	priv, err := key.Generate(bits)
+	if err != nil {
+		return nil, err
+	}
+	k := &ManagedKey{SigningKey: &SigningKey{key, priv}, Created: m.clock().Now().UTC().Truncate(time.Second)}
+	if m.Dir != "" {
+		if err := k.WriteFiles(m.Dir); err != nil {
+			return nil, err
+		}
+	}
+	m.Keys = append(m.Keys, k)
+	return k, nil
+}
+
+// Roll generates a successor for old, with the same flags, algorithm and
+// size, and plans the rollover to it starting at at: a double-DS rollover
+// for a key with the SEP flag, a pre-publish rollover otherwise. When Dir
+// is set the files of both keys are written.
+func (m *KeyManager) Roll(old *ManagedKey, at time.Time, r *Rollover) (*ManagedKey, error) {
+	next, err := m.NewKey(old.DNSKEY.Flags, old.DNSKEY.Algorithm, keySize(old.DNSKEY))
+	if err != nil {
+		return nil, err
+	}
+	if old.DNSKEY.Flags&SEP != 0 {
+		r.KSK(old, next, at)
+	} else {
+		r.ZSK(old, next, at)
+	}
+	if m.Dir != "" {
+		if err := old.WriteFiles(m.Dir); err != nil {
+			return nil, err
+		}
+		if err := next.WriteFiles(m.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// DNSKEYs returns the DNSKEY RRset of the zone at t. It should replace
+// the DNSKEY RRset of the zone before signing it.
+func (m *KeyManager) DNSKEYs(t time.Time) []RR {
+	var rrs []RR
+	for _, k := range m.Keys {
+		if k.Published(t) {
+			rrs = append(rrs, k.DNSKEY)
+		}
+	}
+	return rrs
+}
+
+// DS returns the DS records, with digest type h, that should be in the
+// parent zone at t.
+func (m *KeyManager) DS(t time.Time, h int) []RR {
+	var rrs []RR
+	for _, k := range m.Keys {
+		if k.Synced(t) {
+			if ds := k.DNSKEY.ToDS(h); ds != nil {
+				rrs = append(rrs, ds)
+			}
+		}
+	}
+	return rrs
+}
+
+// Signer sets the keys of s to the keys active at t: the keys with the
+// SEP flag sign the DNSKEY RRset, the others the rest of the zone. When
+// only keys with the SEP flag are active, the ZoneSigner uses them to sign
+// the whole zone.
+func (m *KeyManager) Signer(s *ZoneSigner, t time.Time) {
+	s.KSK, s.ZSK = nil, nil
+	for _, k := range m.Keys {
+		if !k.Active(t) {
+			continue
+		}
+		if k.DNSKEY.Flags&SEP != 0 {
+			s.KSK = append(s.KSK, k.SigningKey)
+		} else {
+			s.ZSK = append(s.ZSK, k.SigningKey)
+		}
+	}
+}
+
+// Next returns the first time after t at which the published or active
+// keys, or the DS records, change. It returns the zero time when nothing
+// is planned.
+func (m *KeyManager) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, k := range m.Keys {
+		for _, e := range k.timing()[1:] {
+			if e.After(t) && (next.IsZero() || e.Before(next)) {
+				next = *e
+			}
+		}
+	}
+	return next
+}
+
+func (m *KeyManager) clock() Clock {
+	if m.Clock == nil {
+		return systemClock{}
+	}
+	return m.Clock
+}
+
+// keySize returns the size in bits of the key, as used by Generate.
+func keySize(k *RR_DNSKEY) int {
+	switch k.Algorithm {
+	case DSA, DSANSEC3SHA1:
+		return 1024
+	case ECDSAP256SHA256:
+		return 256
+	case ECDSAP384SHA384:
+		return 384
+	case ECCGOST, ED25519:
+		return 256
+	}
+	if p := k.publicKeyRSA(); p != nil {
+		return p.N.BitLen()
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package dns
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKeyManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := &fakeClock{now: time.Unix(time.Now().Unix(), 0)}
+	m := &KeyManager{Zone: "miek.nl.", Dir: dir, Clock: clock}
+	ksk, err := m.NewKey(ZONE|SEP, RSASHA256, 512)
+	if err != nil {
+		t.Fatalf("Failed to generate the KSK: %s", err)
+	}
+	zsk, err := m.NewKey(ZONE, RSASHA256, 512)
+	if err != nil {
+		t.Fatalf("Failed to generate the ZSK: %s", err)
+	}
+	r := &Rollover{DNSKEYTTL: time.Hour, MaxTTL: 24 * time.Hour, DSTTL: 2 * time.Hour, Propagation: time.Hour,
+		ParentPropagation: 12 * time.Hour}
+
+	// check checks the DNSKEYs, signing keys and DS records at t
+	check := func(step string, at time.Time, dnskeys []*ManagedKey, ksks []*ManagedKey, zsks []*ManagedKey, ds []*ManagedKey) {
+		same := func(what string, rrs []RR, keys []*ManagedKey) {
+			if len(rrs) != len(keys) {
+				t.Fatalf("%s: expected %d %s, got %d", step, len(keys), what, len(rrs))
+			}
+			for i, k := range keys {
+				tag := uint16(0)
+				switch rr := rrs[i].(type) {
+				case *RR_DNSKEY:
+					tag = rr.KeyTag()
+				case *RR_DS:
+					tag = rr.KeyTag
+				}
+				if tag != k.DNSKEY.KeyTag() {
+					t.Fatalf("%s: expected %s %d, got %s", step, what, k.DNSKEY.KeyTag(), rrs[i])
+				}
+			}
+		}
+		keys := func(keys []*SigningKey) (rrs []RR) {
+			for _, k := range keys {
+				rrs = append(rrs, k.DNSKEY)
+			}
+			return
+		}
+		s := new(ZoneSigner)
+		m.Signer(s, at)
+		same("DNSKEYs", m.DNSKEYs(at), dnskeys)
+		same("KSKs", keys(s.KSK), ksks)
+		same("ZSKs", keys(s.ZSK), zsks)
+		same("DS records", m.DS(at, SHA256), ds)
+	}
+	start := clock.Now()
+	check("start", start, []*ManagedKey{ksk, zsk}, []*ManagedKey{ksk}, []*ManagedKey{zsk}, []*ManagedKey{ksk})
+
+	// Pre-publish ZSK rollover
+	at := start.Add(24 * time.Hour)
+	zsk1, err := m.Roll(zsk, at, r)
+	if err != nil {
+		t.Fatalf("Failed to roll the ZSK: %s", err)
+	}
+	check("before the ZSK rollover", at.Add(-time.Second), []*ManagedKey{ksk, zsk}, []*ManagedKey{ksk}, []*ManagedKey{zsk}, []*ManagedKey{ksk})
+	check("ZSK pre-published", at, []*ManagedKey{ksk, zsk, zsk1}, []*ManagedKey{ksk}, []*ManagedKey{zsk}, []*ManagedKey{ksk})
+	if next := m.Next(at); !next.Equal(at.Add(2 * time.Hour)) {
+		t.Fatalf("Expected the next change at %s, got %s", at.Add(2*time.Hour), next)
+	}
+	at = m.Next(at)
+	check("ZSK rolled", at, []*ManagedKey{ksk, zsk, zsk1}, []*ManagedKey{ksk}, []*ManagedKey{zsk1}, []*ManagedKey{ksk})
+	at = m.Next(at)
+	if !at.Equal(start.Add(24*time.Hour + 2*time.Hour + 25*time.Hour)) {
+		t.Fatalf("Old ZSK removed at %s", at)
+	}
+	check("old ZSK removed", at, []*ManagedKey{ksk, zsk1}, []*ManagedKey{ksk}, []*ManagedKey{zsk1}, []*ManagedKey{ksk})
+
+	// Double-DS KSK rollover
+	at = at.Add(24 * time.Hour)
+	ksk1, err := m.Roll(ksk, at, r)
+	if err != nil {
+		t.Fatalf("Failed to roll the KSK: %s", err)
+	}
+	check("DS submitted", at, []*ManagedKey{ksk, zsk1}, []*ManagedKey{ksk}, []*ManagedKey{zsk1}, []*ManagedKey{ksk, ksk1})
+	at = m.Next(at)
+	check("KSK rolled", at, []*ManagedKey{zsk1, ksk1}, []*ManagedKey{ksk1}, []*ManagedKey{zsk1}, []*ManagedKey{ksk, ksk1})
+	at = m.Next(at)
+	check("old DS removed", at, []*ManagedKey{zsk1, ksk1}, []*ManagedKey{ksk1}, []*ManagedKey{zsk1}, []*ManagedKey{ksk1})
+	if next := m.Next(at); !next.IsZero() {
+		t.Fatalf("Unexpected change at %s", next)
+	}
+
+	// The zone signed during the ZSK rollover
+	at = start.Add(24*time.Hour + 2*time.Hour)
+	s := &ZoneSigner{Clock: clock}
+	m.Signer(s, at)
+	rrs := parseZoneString(t, signTestZone)
+	rrs = append(rrs, m.DNSKEYs(at)...)
+	signed, err := s.Sign("miek.nl.", rrs)
+	if err != nil {
+		t.Fatalf("Failed to sign the zone: %s", err)
+	}
+	if errs := CheckZone("miek.nl.", signed); len(errs) != 0 {
+		t.Fatalf("Signed zone has problems: %v", errs)
+	}
+
+	// Read the keys back from the files
+	m1, err := ReadKeyManager(dir, "miek.nl")
+	if err != nil {
+		t.Fatalf("Failed to read the keys: %s", err)
+	}
+	if len(m1.Keys) != len(m.Keys) {
+		t.Fatalf("Expected %d keys, got %d", len(m.Keys), len(m1.Keys))
+	}
+	for _, k := range m.Keys {
+		var k1 *ManagedKey
+		for _, k2 := range m1.Keys {
+			if k2.DNSKEY.String() == k.DNSKEY.String() {
+				k1 = k2
+			}
+		}
+		if k1 == nil {
+			t.Fatalf("Key %d not read", k.DNSKEY.KeyTag())
+		}
+		times, times1 := k.timing(), k1.timing()
+		for i := range times {
+			if !times[i].Equal(*times1[i]) {
+				t.Fatalf("Key %d: %s %s read as %s", k.DNSKEY.KeyTag(), keyTiming_str[i], times[i], times1[i])
+			}
+		}
+		sig := &RR_RRSIG{Hdr: RR_Header{Ttl: 3600}, Algorithm: k1.DNSKEY.Algorithm, KeyTag: k1.DNSKEY.KeyTag(),
+			SignerName: "miek.nl.", Inception: 1e9, Expiration: 2e9}
+		rrset := []RR{k.DNSKEY}
+		if err := sig.Sign(k1.Private, rrset); err != nil {
+			t.Fatalf("Failed to sign with key %d read back: %s", k.DNSKEY.KeyTag(), err)
+		}
+		if err := sig.Verify(k.DNSKEY, rrset); err != nil {
+			t.Fatalf("Failed to verify with key %d read back: %s", k.DNSKEY.KeyTag(), err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/dsa"
 	"io"
@@ -62,7 +64,9 @@ func (k *RR_DNSKEY) ReadPrivateKey(q io.Reader, file string) (PrivateKey, error)
 		if e != nil {
 			return nil, e
 		}
-		// setPublicKeyInPrivate(p)
+		if !k.setPublicKeyInPrivate(p) {
+			return nil, ErrPrivKey
+		}
 		return p, e
 	case "13 (ECDSAP256SHA256)":
 		fallthrough
@@ -75,6 +79,18 @@ func (k *RR_DNSKEY) ReadPrivateKey(q io.Reader, file string) (PrivateKey, error)
 			return nil, ErrPrivKey
 		}
 		return p, e
+	case "15 (ED25519)":
+		p, e := readPrivateKeyEdDSA(m)
+		if e != nil {
+			return nil, e
+		}
+		if !k.setPublicKeyInPrivate(p) {
+			return nil, ErrPrivKey
+		}
+		return p, e
+	case "16 (ED448)":
+		// Only verification is supported
+		return nil, ErrAlg
 	}
 	return nil, ErrPrivKey
 }
@@ -122,7 +138,7 @@ func readPrivateKeyDSA(m map[string]string) (PrivateKey, error) {
 	p.X = big.NewInt(0)
 	for k, v := range m {
 		switch k {
-		case "private_value(x):":
+		case "private_value(x)":
 			v1, err := packBase64([]byte(v))
 			if err != nil {
 				return nil, err
@@ -141,7 +157,7 @@ func readPrivateKeyECDSA(m map[string]string) (PrivateKey, error) {
 	// Need to check if we have everything
 	for k, v := range m {
 		switch k {
-		case "privatekey:":
+		case "privatekey":
 			v1, err := packBase64([]byte(v))
 			if err != nil {
 				return nil, err
@@ -154,24 +170,34 @@ func readPrivateKeyECDSA(m map[string]string) (PrivateKey, error) {
 	return p, nil
 }
 
+// readPrivateKeyEdDSA reads an Ed25519 key, the private key is the seed,
+// RFC 8080 section 6.
+func readPrivateKeyEdDSA(m map[string]string) (PrivateKey, error) {
+	seed, err := packBase64([]byte(m["privatekey"]))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrPrivKey
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// readPrivateKeyGOST reads a GOST key, the private key is the PKCS#8
+// encoding of RFC 5933 section 2.2.
 func readPrivateKeyGOST(m map[string]string) (PrivateKey, error) {
-//	p := new(ecdsa.PrivateKey)
-//	p.D = big.NewInt(0)
-	// Need to check if we have everything
-	for k, v := range m {
-		switch k {
-		case "gostasn1:":
-			v1, err := packBase64([]byte(v))
-			if err != nil {
-				return nil, err
-			}
-			v1 = v1
-			//p.D.SetBytes(v1)
-		case "created:", "publish:", "activate:":
-			/* not used in Go (yet) */
-		}
+	v, err := packBase64([]byte(m["gostasn1"]))
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != len(gostAsn1Prefix)+32 || !bytes.Equal(v[:len(gostAsn1Prefix)], gostAsn1Prefix) {
+		return nil, ErrPrivKey
+	}
+	d := gostNatLittle(v[len(gostAsn1Prefix):])
+	if d.isZero() || d.less(gostCryptoProA.q.m) == 0 {
+		return nil, ErrPrivKey
 	}
-	return nil, nil
+	return newGOSTKey(d), nil
 }
 
 // parseKey reads a private key from r. It returns a map[string]string,
@@ -190,7 +216,7 @@ func parseKey(r io.Reader, file string) (map[string]string, error) {
 			k = l.token
 		case _VALUE:
 			if k == "" {
-				return nil, &ParseError{file, "no private key seen", l}
+				return nil, &ParseError{file, "no private key seen", l, ""}
 			}
 			//println("Setting", strings.ToLower(k), "to", l.token, "b")
 			m[strings.ToLower(k)] = l.token
@@ -1,5 +1,9 @@
 package dns
 
+import (
+	"strings"
+)
+
 // Holds a bunch of helper functions for dealing with labels.
 
 // SplitLabels splits a domainname string into its labels.
@@ -58,3 +62,31 @@ func CompareLabels(s1, s2 string) (n int) {
 	}
 	return
 }
+
+// compareNames compares the domain names s1 and s2 in the canonical
+// order defined in RFC 4034, section 6.1. It returns -1 when s1 sorts
+// before s2, 1 when it sorts after s2 and 0 when they are equal.
+func compareNames(s1, s2 string) int {
+	l1 := SplitLabels(strings.ToLower(s1))
+	l2 := SplitLabels(strings.ToLower(s2))
+
+	x1 := len(l1) - 1
+	x2 := len(l2) - 1
+	for x1 >= 0 && x2 >= 0 {
+		if l1[x1] < l2[x2] {
+			return -1
+		}
+		if l1[x1] > l2[x2] {
+			return 1
+		}
+		x1--
+		x2--
+	}
+	switch {
+	case x1 < x2:
+		return -1
+	case x1 > x2:
+		return 1
+	}
+	return 0
+}
@@ -45,20 +45,32 @@ var (
 	ErrSecret      error = &Error{Err: "dns: no secrets defined"}
 	ErrSigGen      error = &Error{Err: "dns: bad signature generation"}
 	ErrAuth        error = &Error{Err: "dns: bad authentication"}
+	ErrTrunc       error = &Error{Err: "dns: bad MAC truncation"}
 	ErrXfrSoa      error = &Error{Err: "dns: no SOA seen"}
+	ErrSoa         error = &Error{Err: "dns: no SOA, or more than one, at the zone apex"}
 	ErrXfrLast     error = &Error{Err: "dns: last SOA"}
 	ErrXfrType     error = &Error{Err: "dns: no ixfr, nor axfr"}
+	ErrXfrSerial   error = &Error{Err: "dns: ixfr serials do not follow the zone"}
+	ErrXfrDelete   error = &Error{Err: "dns: ixfr deletes RR not in zone"}
+	ErrJournal     error = &Error{Err: "dns: diff does not continue the journal"}
+	ErrJournalSoa  error = &Error{Err: "dns: serial not in journal"}
+	ErrSerial      error = &Error{Err: "dns: new serial not greater than the old serial"}
 	ErrHandle      error = &Error{Err: "dns: handle is nil"}
 	ErrChan        error = &Error{Err: "dns: channel is nil"}
 	ErrName        error = &Error{Err: "dns: type not found for name"}
 	ErrRRset       error = &Error{Err: "dns: invalid rrset"}
 	ErrDenialNsec3 error = &Error{Err: "dns: no NSEC3 records"}
+	ErrDenialNsec  error = &Error{Err: "dns: no NSEC records"}
+	ErrDenialName  error = &Error{Err: "dns: no covering NSEC found for name"}
+	ErrDenialDs    error = &Error{Err: "dns: no NSEC proving the delegation has no DS"}
+	ErrNsec3Hash   error = &Error{Err: "dns: NSEC3 hash collision"}
 	ErrDenialCe    error = &Error{Err: "dns: no matching closest encloser found"}
 	ErrDenialNc    error = &Error{Err: "dns: no covering NSEC3 found for next closer"}
-	ErrDenialSo    error = &Error{Err: "dns: no covering NSEC3 found for source of synthesis"}
-	ErrDenialBit   error = &Error{Err: "dns: type not denied in NSEC3 bitmap"}
+	ErrDenialSo    error = &Error{Err: "dns: no covering NSEC or NSEC3 found for source of synthesis"}
+	ErrDenialBit   error = &Error{Err: "dns: type not denied in NSEC or NSEC3 bitmap"}
 	ErrDenialWc    error = &Error{Err: "dns: wildcard exist, but closest encloser is denied"}
 	ErrDenialHdr   error = &Error{Err: "dns: message rcode conflicts with message content"}
+	ErrAnchor      error = &Error{Err: "dns: DNSKEY RRset not signed by a trusted key"}
 )
 
 // A manually-unpacked version of (id, bits).
@@ -997,8 +1009,9 @@ func packRR(rr RR, msg []byte, off int, compression map[string]int, compress boo
 	return off1, true
 }
 
-// Resource record unpacker.
-func unpackRR(msg []byte, off int) (rr RR, off1 int, ok bool) {
+// Resource record unpacker. When empty is true an RR without rdata is
+// returned as an *RR_Header.
+func unpackRR(msg []byte, off int, empty bool) (rr RR, off1 int, ok bool) {
 	// unpack just the header, to find the rr type and length
 	var h RR_Header
 	off0 := off
@@ -1006,6 +1019,11 @@ func unpackRR(msg []byte, off int) (rr RR, off1 int, ok bool) {
 		return nil, len(msg), false
 	}
 	end := off + int(h.Rdlength)
+	if empty && h.Rdlength == 0 && h.Rrtype != TypeOPT && h.Rrtype != TypeANY {
+		// No rdata, as used in the prerequisite and update
+		// sections of dynamic updates (RFC 2136)
+		return &h, end, true
+	}
 	// make an rr of that type and re-unpack.
 	mk, known := rr_mk[h.Rrtype]
 	if !known {
@@ -1161,7 +1179,9 @@ func (dns *Msg) Pack() (msg []byte, ok bool) {
 	return msg[:off], true
 }
 
-// Unpack unpacks a binary message to a Msg structure.
+// Unpack unpacks a binary message to a Msg structure. In the prerequisite
+// and update sections of a dynamic update (RFC 2136), the Answer and Ns
+// sections, RRs without rdata are unpacked as an *RR_Header.
 func (dns *Msg) Unpack(msg []byte) bool {
 	// Header.
 	var dh Header
@@ -1191,14 +1211,15 @@ func (dns *Msg) Unpack(msg []byte) bool {
 	for i := 0; i < len(dns.Question); i++ {
 		off, ok = unpackStruct(&dns.Question[i], msg, off)
 	}
+	update := dns.Opcode == OpcodeUpdate
 	for i := 0; i < len(dns.Answer); i++ {
-		dns.Answer[i], off, ok = unpackRR(msg, off)
+		dns.Answer[i], off, ok = unpackRR(msg, off, update)
 	}
 	for i := 0; i < len(dns.Ns); i++ {
-		dns.Ns[i], off, ok = unpackRR(msg, off)
+		dns.Ns[i], off, ok = unpackRR(msg, off, update)
 	}
 	for i := 0; i < len(dns.Extra); i++ {
-		dns.Extra[i], off, ok = unpackRR(msg, off)
+		dns.Extra[i], off, ok = unpackRR(msg, off, false)
 	}
 	if !ok {
 		return false
@@ -0,0 +1,153 @@
+package dns
+
+// Sending and receiving NOTIFY messages, see RFC 1996.
+
+import (
+	"net"
+	"time"
+)
+
+// Notifier sends NOTIFY messages for a zone to its secondaries.
+type Notifier struct {
+	Secondaries   []string      // addresses of the secondaries, host:port
+	TsigName      string        // if not empty, the NOTIFYs are signed with this key
+	TsigSecret    string        // secret of the TSIG key TsigName
+	TsigAlgorithm string        // algorithm of the TSIG key TsigName, HmacMD5 if empty
+	Retries       int           // number of retransmissions, 5 when zero
+	Interval      time.Duration // time to wait for the first acknowledgement, 60 seconds when zero
+}
+
+// Notify sends a NOTIFY for the zone of soa, with soa in the answer
+// section, to all secondaries in parallel. The NOTIFY is retransmitted
+// until it is acknowledged or the retries are exhausted, the time waited
+// for an acknowledgement doubles with every retransmission.
+// One error is returned for each secondary, in the same order as
+// Secondaries. It is nil when the secondary acknowledged the NOTIFY.
+func (n *Notifier) Notify(soa *RR_SOA) []error {
+	errs := make([]error, len(n.Secondaries))
+	done := make(chan bool)
+	for i, a := range n.Secondaries {
+		go func(i int, a string) {
+			errs[i] = n.notify(soa, a)
+			done <- true
+		}(i, a)
+	}
+	for _ = range n.Secondaries {
+		<-done
+	}
+	return errs
+}
+
+// notify sends the NOTIFY to a single secondary.
+func (n *Notifier) notify(soa *RR_SOA, a string) (err error) {
+	retries, interval := n.Retries, n.Interval
+	if retries == 0 {
+		retries = 5
+	}
+	if interval == 0 {
+		interval = 60 * time.Second
+	}
+	alg := n.TsigAlgorithm
+	if alg == "" {
+		alg = HmacMD5
+	}
+	id := Id()
+	for i := 0; i <= retries; i++ {
+		m := new(Msg)
+		m.SetNotify(soa.Hdr.Name)
+		m.Id = id
+		m.Answer = []RR{soa}
+		c := NewClient()
+		c.ReadTimeout = interval
+		if n.TsigName != "" {
+			c.TsigSecret = map[string]string{n.TsigName: n.TsigSecret}
+			m.SetTsig(n.TsigName, alg, 300, m.Id, time.Now().Unix())
+		}
+		start := time.Now()
+		var in *Msg
+		in, err = c.exchangeTsig(m, a)
+		if err == nil {
+			if !in.Response || in.Opcode != OpcodeNotify {
+				return &Error{Err: "dns: bad notify acknowledgement", Name: a}
+			}
+			if in.Rcode != RcodeSuccess {
+				return &Error{Err: "dns: bad rcode: " + Rcode_str[in.Rcode], Name: a}
+			}
+			return nil
+		}
+		// Network errors return early, make sure the interval is used
+		time.Sleep(interval - time.Since(start))
+		interval *= 2
+	}
+	return err
+}
+
+// NotifyReceiver is a Handler that accepts NOTIFY messages. A NOTIFY that
+// is accepted is acknowledged, after which the function Notify is called.
+// A NOTIFY is refused when it comes from an address that is not allowed, or
+// when its TSIG does not validate. To have a Secondary react to it use:
+//
+//	n := &NotifyReceiver{Notify: func(zone string, serial uint32) { s.Notify() }}
+//	dns.Handle(zone, n)
+type NotifyReceiver struct {
+	Allow       []*net.IPNet                     // if not empty, only accept NOTIFYs from these networks
+	RequireTsig bool                             // only accept NOTIFYs with a valid TSIG
+	Notify      func(zone string, serial uint32) // called for each accepted NOTIFY, serial is 0 when the NOTIFY has no SOA
+	Handler     Handler                          // handles the requests that are not NOTIFY, when nil they are refused
+}
+
+// ServeDNS implements the Handler interface.
+func (n *NotifyReceiver) ServeDNS(w ResponseWriter, r *Msg) {
+	if !r.IsNotify() {
+		if n.Handler != nil {
+			n.Handler.ServeDNS(w, r)
+			return
+		}
+		Refused(w, r)
+		return
+	}
+	m := new(Msg)
+	if !n.allowed(w.RemoteAddr()) || (r.IsTsig() && w.TsigStatus() != nil) || (n.RequireTsig && !r.IsTsig()) {
+		m.SetRcode(r, RcodeRefused)
+		m.Opcode = OpcodeNotify
+		w.Write(m)
+		return
+	}
+	m.SetReply(r)
+	m.Opcode = OpcodeNotify
+	if r.IsTsig() {
+		t := r.Extra[len(r.Extra)-1].(*RR_TSIG)
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, m.MsgHdr.Id, time.Now().Unix())
+	}
+	w.Write(m)
+
+	var serial uint32
+	for _, rr := range r.Answer {
+		if soa, ok := rr.(*RR_SOA); ok {
+			serial = soa.Serial
+		}
+	}
+	if n.Notify != nil {
+		n.Notify(r.Question[0].Name, serial)
+	}
+}
+
+// allowed checks if a is in one of the networks in n.Allow.
+func (n *NotifyReceiver) allowed(a net.Addr) bool {
+	if len(n.Allow) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch a := a.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	}
+	for _, network := range n.Allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	secret := map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}
+	notified := make(chan uint32, 10)
+	_, local, _ := net.ParseCIDR("127.0.0.0/8")
+	_, other, _ := net.ParseCIDR("10.0.0.0/8")
+	recv := &NotifyReceiver{Allow: []*net.IPNet{local}, RequireTsig: true,
+		Notify: func(zone string, serial uint32) { notified <- serial }}
+	mux := NewServeMux()
+	mux.Handle("miek.nl.", recv)
+	go (&Server{Addr: "127.0.0.1:8060", Net: "udp", Handler: mux, TsigSecret: secret}).ListenAndServe()
+	mux1 := NewServeMux()
+	mux1.Handle("miek.nl.", &NotifyReceiver{Allow: []*net.IPNet{other}, Notify: recv.Notify})
+	go (&Server{Addr: "127.0.0.1:8062", Net: "udp", Handler: mux1, TsigSecret: secret}).ListenAndServe()
+	time.Sleep(2e8)
+
+	soa := xfrTestZone(42, 0)[0].(*RR_SOA)
+	n := &Notifier{Secondaries: []string{"127.0.0.1:8060"}, TsigName: "axfr.", TsigSecret: secret["axfr."],
+		Retries: 1, Interval: 5e8}
+	if errs := n.Notify(soa); errs[0] != nil {
+		t.Fatalf("NOTIFY not acknowledged: %s", errs[0])
+	}
+	select {
+	case serial := <-notified:
+		if serial != 42 {
+			t.Fatalf("Expected serial 42, got %d", serial)
+		}
+	case <-time.After(1e9):
+		t.Fatal("Notify function not called")
+	}
+	n.TsigAlgorithm = HmacSHA256
+	if errs := n.Notify(soa); errs[0] != nil {
+		t.Fatalf("NOTIFY signed with HMAC-SHA256 not acknowledged: %s", errs[0])
+	}
+	if serial := <-notified; serial != 42 {
+		t.Fatalf("Expected serial 42, got %d", serial)
+	}
+	n.TsigAlgorithm = ""
+
+	// Refused: no TSIG, and not from an allowed network
+	n.TsigName = ""
+	if errs := n.Notify(soa); errs[0] == nil {
+		t.Fatal("NOTIFY without TSIG should be refused")
+	}
+	n.TsigName = "axfr."
+	n.Secondaries = []string{"127.0.0.1:8062"}
+	if errs := n.Notify(soa); errs[0] == nil {
+		t.Fatal("NOTIFY from an address not allowed should be refused")
+	}
+	if len(notified) != 0 {
+		t.Fatal("Notify function called for a refused NOTIFY")
+	}
+
+	// No one listening, retransmit and give up
+	n.Secondaries = []string{"127.0.0.1:8060", "127.0.0.1:8061"}
+	start := time.Now()
+	n.Interval = 1e8
+	errs := n.Notify(soa)
+	if errs[0] != nil || errs[1] == nil {
+		t.Fatalf("Expected only the second secondary to fail: %v", errs)
+	}
+	if time.Since(start) < 3e8 {
+		t.Fatalf("Expected a retransmission after the interval doubled")
+	}
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -17,3 +18,221 @@ func TestPackNsec3(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestNsecCover(t *testing.T) {
+	nsec, _ := NewRR("c.b.example.org. IN NSEC sub.example.org. TXT RRSIG NSEC")
+	last, _ := NewRR("*.w.example.org. IN NSEC example.org. TXT RRSIG NSEC")
+	for _, name := range []string{"nx.example.org.", "d.b.example.org.", "a.c.b.example.org."} {
+		if !nsec.(*RR_NSEC).Cover(name) {
+			t.Logf("%s should be covered", name)
+			t.Fail()
+		}
+	}
+	for _, name := range []string{"c.b.example.org.", "sub.example.org.", "a.example.org.", "a.sub.example.org."} {
+		if nsec.(*RR_NSEC).Cover(name) {
+			t.Logf("%s should not be covered", name)
+			t.Fail()
+		}
+	}
+	if !last.(*RR_NSEC).Cover("x.w.example.org.") || last.(*RR_NSEC).Cover("a.example.org.") {
+		t.Log("Wrong cover for the last NSEC")
+		t.Fail()
+	}
+}
+
+func TestNsecVerify(t *testing.T) {
+	nsec := map[string]string{
+		"apex":  "example.org. IN NSEC a.example.org. NS SOA RRSIG NSEC",
+		"a":     "a.example.org. IN NSEC c.b.example.org. A RRSIG NSEC",
+		"c.b":   "c.b.example.org. IN NSEC sub.example.org. TXT RRSIG NSEC",
+		"sub":   "sub.example.org. IN NSEC *.w.example.org. NS NSEC",
+		"*.w":   "*.w.example.org. IN NSEC example.org. TXT RRSIG NSEC",
+		"soa":   "example.org. IN SOA ns.example.org. hostmaster.example.org. 1 3600 900 86400 3600",
+		"ns":    "sub.example.org. IN NS ns.example.net.",
+		"txt":   "x.w.example.org. IN TXT \"wildcard\"",
+		"rrsig": "x.w.example.org. IN RRSIG TXT 8 3 3600 20300101000000 20000101000000 1 example.org. AAAA",
+	}
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []string
+		ns     []string
+		result int
+		err    error
+	}{
+		{"nx.example.org.", TypeA, RcodeNameError, nil, []string{"soa", "c.b", "apex"}, NSEC_NXDOMAIN, nil},
+		{"nx.example.org.", TypeA, RcodeNameError, nil, []string{"soa", "c.b"}, 0, ErrDenialSo},
+		{"nx.example.org.", TypeA, RcodeSuccess, nil, []string{"soa", "c.b", "apex"}, 0, ErrDenialHdr},
+		{"nx.example.org.", TypeA, RcodeNameError, nil, []string{"soa", "apex"}, 0, ErrDenialName},
+		{"a.example.org.", TypeMX, RcodeSuccess, nil, []string{"soa", "a"}, NSEC_NODATA, nil},
+		{"a.example.org.", TypeA, RcodeSuccess, nil, []string{"soa", "a"}, 0, ErrDenialBit},
+		{"b.example.org.", TypeA, RcodeSuccess, nil, []string{"soa", "a"}, NSEC_NODATA, nil},
+		{"b.example.org.", TypeA, RcodeNameError, nil, []string{"soa", "a"}, 0, ErrDenialHdr},
+		{"x.w.example.org.", TypeMX, RcodeSuccess, nil, []string{"soa", "*.w"}, NSEC_NODATA, nil},
+		{"x.w.example.org.", TypeTXT, RcodeSuccess, nil, []string{"soa", "*.w"}, 0, ErrDenialBit},
+		{"x.w.example.org.", TypeTXT, RcodeSuccess, []string{"txt", "rrsig"}, []string{"*.w"}, NSEC_WILDCARD, nil},
+		{"x.w.example.org.", TypeTXT, RcodeSuccess, []string{"txt", "rrsig"}, nil, 0, ErrDenialName},
+		{"x.w.example.org.", TypeTXT, RcodeSuccess, []string{"txt"}, nil, 0, nil},
+		{"www.sub.example.org.", TypeA, RcodeSuccess, nil, []string{"ns", "sub"}, NSEC_NODS, nil},
+		{"www.sub.example.org.", TypeA, RcodeSuccess, nil, []string{"ns", "c.b"}, 0, ErrDenialDs},
+		{"sub.example.org.", TypeDS, RcodeSuccess, nil, []string{"soa", "sub"}, NSEC_NODATA, nil},
+		{"sub.example.org.", TypeMX, RcodeSuccess, nil, []string{"soa", "sub"}, 0, ErrDenialBit},
+		{"nx.example.org.", TypeA, RcodeNameError, nil, []string{"soa"}, 0, ErrDenialNsec},
+	}
+	for i, tc := range tests {
+		m := new(Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.Rcode = tc.rcode
+		for _, k := range tc.answer {
+			m.Answer = append(m.Answer, newRR(t, nsec[k]))
+		}
+		for _, k := range tc.ns {
+			m.Ns = append(m.Ns, newRR(t, nsec[k]))
+		}
+		result, err := m.NsecVerify(m.Question[0])
+		if result != tc.result || err != tc.err {
+			t.Logf("Test %d: expected %d %v, got %d %v", i, tc.result, tc.err, result, err)
+			t.Fail()
+		}
+	}
+}
+
+// The example zone of RFC 5155 appendix A.
+const nsec3TestZone = `example. 3600 IN SOA ns1.example. bugs.x.w.example. 1 3600 300 3600000 3600
+example. 3600 IN NS ns1.example.
+example. 3600 IN NS ns2.example.
+example. 3600 IN MX 1 xx.example.
+a.example. 3600 IN NS ns1.a.example.
+a.example. 3600 IN NS ns2.a.example.
+a.example. 3600 IN DS 58470 5 1 3079F1593EBAD6DC121E202A8B766A6A4837206C
+ns1.a.example. 3600 IN A 192.0.2.5
+ns2.a.example. 3600 IN A 192.0.2.6
+ai.example. 3600 IN A 192.0.2.9
+ai.example. 3600 IN AAAA 2001:db8::f00:baa9
+b.example. 3600 IN NS ns1.b.example.
+b.example. 3600 IN NS ns2.b.example.
+ns1.b.example. 3600 IN A 192.0.2.7
+ns2.b.example. 3600 IN A 192.0.2.8
+c.example. 3600 IN NS ns1.c.example.
+c.example. 3600 IN NS ns2.c.example.
+ns1.c.example. 3600 IN A 192.0.2.7
+ns2.c.example. 3600 IN A 192.0.2.8
+ns1.example. 3600 IN A 192.0.2.1
+ns2.example. 3600 IN A 192.0.2.2
+*.w.example. 3600 IN MX 1 ai.example.
+x.w.example. 3600 IN MX 1 xx.example.
+x.y.w.example. 3600 IN MX 1 xx.example.
+xx.example. 3600 IN A 192.0.2.10
+xx.example. 3600 IN AAAA 2001:db8::f00:baaa
+`
+
+// TestNsec3Verify checks the responses of RFC 5155 appendix B, made from
+// the signed example zone.
+func TestNsec3Verify(t *testing.T) {
+	key := &RR_DNSKEY{Hdr: RR_Header{Name: "example.", Rrtype: TypeDNSKEY, Class: ClassINET, Ttl: 3600},
+		Flags: ZONE | SEP, Protocol: 3, Algorithm: RSASHA256}
+	priv, err := key.Generate(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	type nsec3Test struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []RR
+		ns     []string // the names whose NSEC3 is in the authority section
+		result int
+		err    error
+	}
+	for _, optout := range []bool{true, false} {
+		s := &ZoneSigner{ZSK: []*SigningKey{{key, priv}}, OptOut: optout,
+			NSEC3: &RR_NSEC3PARAM{Hash: SHA1, Iterations: 12, Salt: "AABBCCDD"}}
+		rrs, err := s.Sign("example.", parseZoneString(t, nsec3TestZone))
+		if err != nil {
+			t.Fatalf("Failed to sign the zone: %s", err)
+		}
+		// nsec3 returns the NSEC3 of name, or the NSEC3 covering it when
+		// name starts with a ~
+		nsec3 := func(name string) RR {
+			h := strings.ToLower(HashName(name, SHA1, 12, "AABBCCDD")) + ".example."
+			for _, r := range rrs {
+				if n, ok := r.(*RR_NSEC3); ok && (r.Header().Name == h || name[0] == '~' && n.Cover(name[1:])) {
+					return r
+				}
+			}
+			t.Fatalf("No NSEC3 for %s", name)
+			return nil
+		}
+		if optout && nsec3("example.").Header().Name != "0p9mhaveqvm6t7vbl5lop2u3t2rp3tom.example." {
+			t.Fatalf("Wrong hash for the apex: %s", nsec3("example."))
+		}
+		ns := newRR(t, "c.example. 3600 IN NS ns1.c.example.")
+		soa := newRR(t, "example. 3600 IN SOA ns1.example. bugs.x.w.example. 1 3600 300 3600000 3600")
+		mx := newRR(t, "a.z.w.example. 3600 IN MX 1 ai.example.")
+		sig := newRR(t, "a.z.w.example. 3600 IN RRSIG MX 7 2 3600 20150420235959 20051021000000 40430 example. AAAA")
+		nxdomain := NSEC3_NXDOMAIN
+		if optout {
+			nxdomain = NSEC3_OPTOUT
+		}
+		tests := []nsec3Test{
+			// B.1, name error
+			{"a.c.x.w.example.", TypeA, RcodeNameError, nil, []string{"~c.x.w.example.", "x.w.example.", "~*.x.w.example."}, nxdomain, nil},
+			{"a.c.x.w.example.", TypeA, RcodeNameError, nil, []string{"~c.x.w.example.", "x.w.example."}, 0, ErrDenialSo},
+			{"a.c.x.w.example.", TypeA, RcodeNameError, nil, []string{"x.w.example.", "~*.x.w.example."}, 0, ErrDenialNc},
+			{"a.c.x.w.example.", TypeA, RcodeNameError, nil, []string{"~*.x.w.example."}, 0, ErrDenialCe},
+			{"a.c.x.w.example.", TypeA, RcodeSuccess, nil, []string{"~c.x.w.example.", "x.w.example.", "~*.x.w.example."}, 0, ErrDenialHdr},
+			// B.2, no data
+			{"ns1.example.", TypeMX, RcodeSuccess, nil, []string{"ns1.example."}, NSEC3_NODATA, nil},
+			{"ns1.example.", TypeA, RcodeSuccess, nil, []string{"ns1.example."}, 0, ErrDenialBit},
+			// B.2.1, no data for an empty non-terminal
+			{"y.w.example.", TypeA, RcodeSuccess, nil, []string{"y.w.example."}, NSEC3_NODATA, nil},
+			// B.4, wildcard expansion
+			{"a.z.w.example.", TypeMX, RcodeSuccess, []RR{mx, sig}, []string{"ns2.example."}, NSEC3_WILDCARD, nil},
+			{"a.z.w.example.", TypeMX, RcodeSuccess, []RR{mx, sig}, []string{"w.example."}, 0, ErrDenialNc},
+			{"a.z.w.example.", TypeMX, RcodeSuccess, []RR{mx}, nil, 0, nil},
+			// B.5, wildcard no data
+			{"a.z.w.example.", TypeAAAA, RcodeSuccess, nil, []string{"w.example.", "ns2.example.", "*.w.example."}, NSEC3_NODATA, nil},
+			{"a.z.w.example.", TypeMX, RcodeSuccess, nil, []string{"w.example.", "ns2.example.", "*.w.example."}, 0, ErrDenialBit},
+			// B.6, DS no data
+			{"example.", TypeDS, RcodeSuccess, nil, []string{"example."}, NSEC3_NODATA, nil},
+			{"a.example.", TypeDS, RcodeSuccess, nil, []string{"a.example."}, 0, ErrDenialBit},
+			{"a.example.", TypeA, RcodeSuccess, nil, []string{"a.example."}, 0, ErrDenialBit},
+		}
+		if optout {
+			// B.3, referral to an opt-out unsigned zone, and a DS query for it
+			tests = append(tests, []nsec3Test{
+				{"a.c.x.w.example.", TypeA, RcodeNameError, nil, []string{"example.", "x.w.example.", "a.example."}, NSEC3_OPTOUT, nil},
+				{"mc.c.example.", TypeMX, RcodeSuccess, nil, []string{"example.", "a.example."}, NSEC3_OPTOUT, nil},
+				{"c.example.", TypeDS, RcodeSuccess, nil, []string{"example.", "a.example."}, NSEC3_OPTOUT, nil},
+				{"mc.c.example.", TypeMX, RcodeSuccess, nil, []string{"example."}, 0, ErrDenialDs},
+			}...)
+		} else {
+			tests = append(tests, []nsec3Test{
+				{"mc.c.example.", TypeMX, RcodeSuccess, nil, []string{"c.example."}, NSEC3_NODS, nil},
+				{"c.example.", TypeDS, RcodeSuccess, nil, []string{"c.example."}, NSEC3_NODATA, nil},
+			}...)
+		}
+		for i, tc := range tests {
+			m := new(Msg)
+			m.SetQuestion(tc.qname, tc.qtype)
+			m.Rcode = tc.rcode
+			m.Answer = tc.answer
+			switch {
+			case tc.answer != nil:
+			case strings.HasSuffix(tc.qname, "c.example.") && tc.qtype != TypeDS:
+				m.Ns = append(m.Ns, ns)
+			default:
+				m.Ns = append(m.Ns, soa)
+			}
+			for _, name := range tc.ns {
+				m.Ns = append(m.Ns, nsec3(name))
+			}
+			result, err := m.Nsec3Verify(m.Question[0])
+			if result != tc.result || err != tc.err {
+				t.Logf("Opt-out %t, test %d: expected %d %v, got %d %v", optout, i, tc.result, tc.err, result, err)
+				t.Fail()
+			}
+		}
+	}
+}
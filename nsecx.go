@@ -7,10 +7,23 @@ import (
 	"strings"
 )
 
+// Results of Nsec3Verify.
 const (
 	_ = iota
 	NSEC3_NXDOMAIN
 	NSEC3_NODATA
+	NSEC3_WILDCARD // the answer was synthesized from a wildcard
+	NSEC3_NODS     // the referral is to a delegation without a DS
+	NSEC3_OPTOUT   // the name is in an opt-out span, the denial is insecure
+)
+
+// Results of NsecVerify.
+const (
+	_ = iota
+	NSEC_NXDOMAIN
+	NSEC_NODATA
+	NSEC_WILDCARD // the answer was synthesized from a wildcard
+	NSEC_NODS     // the referral is to a delegation without a DS
 )
 
 type saltWireFmt struct {
@@ -69,150 +82,334 @@ func (nsec3 *RR_NSEC3) Match(domain string) bool {
 	return strings.ToUpper(SplitLabels(nsec3.Header().Name)[0]) == strings.ToUpper(HashName(domain, nsec3.Hash, nsec3.Iterations, nsec3.Salt))
 }
 
-// Cover checks if domain is covered by the NSEC3 record. Domain must be given in plain text.
+// Cover checks if domain is covered by the NSEC3 record: its hash sorts
+// between the hashed owner name and the next hashed owner name. Domain
+// must be given in plain text.
 func (nsec3 *RR_NSEC3) Cover(domain string) bool {
 	hashdom := strings.ToUpper(HashName(domain, nsec3.Hash, nsec3.Iterations, nsec3.Salt))
 	nextdom := strings.ToUpper(nsec3.NextDomain)
-	owner := strings.ToUpper(SplitLabels(nsec3.Header().Name)[0])                                                                              // The hashed part
-	apex := strings.ToUpper(HashName(strings.Join(SplitLabels(nsec3.Header().Name)[1:], "."), nsec3.Hash, nsec3.Iterations, nsec3.Salt)) + "." // The name of the zone
-	// if nextdomain equals the apex, it is considered The End. So in that case hashdom is always less then nextdomain
-	if hashdom > owner && nextdom == apex {
-		return true
+	owner := strings.ToUpper(SplitLabels(nsec3.Header().Name)[0]) // The hashed part
+	if owner < nextdom {
+		return hashdom > owner && hashdom < nextdom
 	}
+	// The last NSEC3 of the zone, nextdom is the first hash
+	return hashdom > owner || hashdom < nextdom
+}
 
-	if hashdom > owner && hashdom <= nextdom {
-		return true
+// Cover checks if domain is covered by the NSEC record: it sorts between
+// the owner name and the next domain name in the canonical order. Domain
+// must be given in plain text.
+func (nsec *RR_NSEC) Cover(domain string) bool {
+	owner, next := nsec.Header().Name, nsec.NextDomain
+	if compareNames(owner, next) < 0 {
+		return compareNames(domain, owner) > 0 && compareNames(domain, next) < 0
 	}
-
-	return false
+	// The last NSEC of the zone, next is the apex
+	return compareNames(domain, owner) > 0 || compareNames(domain, next) < 0
 }
 
-// Cover checks if domain is covered by the NSEC record. Domain must be given in plain text.
-func (nsec *RR_NSEC) Cover(domain string) bool {
+// bitmapHas checks if the type t is set in the type bitmap of an NSEC or
+// NSEC3 record.
+func bitmapHas(bitmap []uint16, t uint16) bool {
+	for _, t1 := range bitmap {
+		if t1 == t {
+			return true
+		}
+	}
 	return false
 }
 
-// NsecVerify verifies an denial of existence response with NSECs
-// NsecVerify returns nil when the NSECs in the message contain
-// the correct proof. This function does not validates the NSECs.
-func (m *Msg) NsecVerify(q Question) error {
+// NsecVerify verifies a denial of existence response with NSECs, see RFC
+// 4035 section 5.4. It returns NSEC_NXDOMAIN when the NSECs prove that the
+// name and the wildcard that could have matched it do not exist, and
+// NSEC_NODATA when the name, an empty non-terminal or the matching
+// wildcard exists without the type. For an answer synthesized from a
+// wildcard it returns NSEC_WILDCARD when the name itself is proven not to
+// exist, for a referral NSEC_NODS when the delegation is proven to have no
+// DS. A positive answer returns 0 and no error.
+// This function does not validate the NSECs.
+func (m *Msg) NsecVerify(q Question) (int, error) {
+	var nsec []*RR_NSEC
+	for _, r := range m.Ns {
+		if n, ok := r.(*RR_NSEC); ok {
+			nsec = append(nsec, n)
+		}
+	}
+	qname := strings.ToLower(q.Name)
 
-	return nil
-}
+	if len(m.Answer) > 0 {
+		// Wildcard expansion: the RRSIG has fewer labels than its owner name
+		for _, r := range m.Answer {
+			sig, ok := r.(*RR_RRSIG)
+			if !ok || int(sig.Labels) >= len(SplitLabels(sig.Hdr.Name)) {
+				continue
+			}
+			for _, n := range nsec {
+				if n.Cover(sig.Hdr.Name) {
+					return NSEC_WILDCARD, nil
+				}
+			}
+			return 0, ErrDenialName
+		}
+		return 0, nil
+	}
+	if len(nsec) == 0 {
+		return 0, ErrDenialNsec
+	}
 
-// Nsec3Verify verifies an denial of existence response with NSEC3s.
-// This function does not validate the NSEC3s.
-func (m *Msg) Nsec3Verify(q Question) (int, error) {
-	var (
-		nsec3    []*RR_NSEC3
-		ncdenied = false // next closer denied
-		sodenied = false // source of synthesis denied
-		ce       = ""    // closest encloser
-		nc       = ""    // next closer
-		so       = ""    // source of synthesis
-	)
-	if len(m.Answer) > 0 && len(m.Ns) > 0 {
-		// Wildcard expansion
-		// Closest encloser inferred from SIG in authority and qname
-		// println("EXPANDED WILDCARD PROOF or DNAME CNAME")
-		// println("NODATA")
-		// I need to check the type bitmap
-		// wildcard bit not set?
-		// MM: No need to check the wildcard bit here:
-		//     This response has only 1 NSEC4 and it does not match
-		//     the closest encloser (it covers next closer).
-	}
-	if len(m.Answer) == 0 && len(m.Ns) > 0 {
-		// Maybe an NXDOMAIN or NODATA, we only know when we check
-		for _, n := range m.Ns {
-			if n.Header().Rrtype == TypeNSEC3 {
-				nsec3 = append(nsec3, n.(*RR_NSEC3))
+	// A referral, the NSEC at the delegation must show there is no DS
+	referral, soa := "", false
+	for _, r := range m.Ns {
+		switch r.Header().Rrtype {
+		case TypeSOA:
+			soa = true
+		case TypeNS:
+			referral = strings.ToLower(r.Header().Name)
+		}
+	}
+	if referral != "" && !soa && q.Qtype != TypeDS {
+		for _, n := range nsec {
+			if strings.ToLower(n.Hdr.Name) == referral && bitmapHas(n.TypeBitMap, TypeNS) && !bitmapHas(n.TypeBitMap, TypeSOA) {
+				if bitmapHas(n.TypeBitMap, TypeDS) {
+					return 0, ErrDenialBit
+				}
+				return NSEC_NODS, nil
 			}
 		}
-		if len(nsec3) == 0 {
-			return 0, ErrDenialNsec3
+		return 0, ErrDenialDs
+	}
+
+	for _, n := range nsec {
+		if strings.ToLower(n.Hdr.Name) != qname {
+			continue
+		}
+		// The name exists, the type and a CNAME must not
+		if bitmapHas(n.TypeBitMap, q.Qtype) || bitmapHas(n.TypeBitMap, TypeCNAME) {
+			return 0, ErrDenialBit
+		}
+		// An NSEC from the parent side of a delegation only denies the DS
+		if bitmapHas(n.TypeBitMap, TypeNS) && !bitmapHas(n.TypeBitMap, TypeSOA) && q.Qtype != TypeDS {
+			return 0, ErrDenialBit
+		}
+		if m.MsgHdr.Rcode == RcodeNameError {
+			return 0, ErrDenialHdr
 		}
+		return NSEC_NODATA, nil
+	}
 
-		lastchopped := ""
-		labels := SplitLabels(q.Name)
+	var cover *RR_NSEC
+	for _, n := range nsec {
+		if n.Cover(qname) {
+			cover = n
+			break
+		}
+	}
+	if cover == nil {
+		return 0, ErrDenialName
+	}
+	if IsSubDomain(qname, strings.ToLower(cover.NextDomain)) {
+		// An empty non-terminal, names below it exist
+		if m.MsgHdr.Rcode == RcodeNameError {
+			return 0, ErrDenialHdr
+		}
+		return NSEC_NODATA, nil
+	}
 
-		// Find the closest encloser and create the next closer
-		for _, nsec := range nsec3 {
-			candidate := ""
-			for i := len(labels) - 1; i >= 0; i-- {
-				candidate = labels[i] + "." + candidate
-				if nsec.Match(candidate) {
-					ce = candidate
-				}
-				lastchopped = labels[i]
-			}
+	// The closest encloser is the longest ancestor the owner or the next
+	// name shares with qname, the wildcard at it must not exist either
+	labels := SplitLabels(qname)
+	ce := CompareLabels(qname, strings.ToLower(cover.Hdr.Name))
+	if ce1 := CompareLabels(qname, strings.ToLower(cover.NextDomain)); ce1 > ce {
+		ce = ce1
+	}
+	wildcard := "*."
+	if ce > 0 {
+		wildcard += strings.Join(labels[len(labels)-ce:], ".") + "."
+	}
+	for _, n := range nsec {
+		if strings.ToLower(n.Hdr.Name) != wildcard {
+			continue
+		}
+		// A wildcard NODATA
+		if bitmapHas(n.TypeBitMap, q.Qtype) || bitmapHas(n.TypeBitMap, TypeCNAME) {
+			return 0, ErrDenialBit
 		}
-		if ce == "" { // what about root label?
-			return 0, ErrDenialCe
+		if m.MsgHdr.Rcode == RcodeNameError {
+			return 0, ErrDenialHdr
 		}
-		nc = lastchopped + "." + ce
-		so = "*." + ce
-		// Check if the next closer is covered and thus denied
-		for _, nsec := range nsec3 {
-			if nsec.Cover(nc) {
-				ncdenied = true
-				break
+		return NSEC_NODATA, nil
+	}
+	for _, n := range nsec {
+		if n.Cover(wildcard) {
+			if m.MsgHdr.Rcode != RcodeNameError {
+				return 0, ErrDenialHdr
 			}
+			return NSEC_NXDOMAIN, nil
+		}
+	}
+	return 0, ErrDenialSo
+}
+
+// Nsec3Verify verifies a denial of existence response with NSEC3s, see RFC
+// 5155 section 8. It returns NSEC3_NXDOMAIN when the closest encloser
+// proof holds and the wildcard at the closest encloser is covered, and
+// NSEC3_NODATA when the name, or the matching wildcard, exists without the
+// type. For an answer synthesized from a wildcard it returns NSEC3_WILDCARD
+// when the next closer name is covered, for a referral NSEC3_NODS when the
+// delegation is proven to have no DS.
+//
+// When the next closer name is covered by an NSEC3 with the opt-out flag
+// set, an unsigned delegation may exist there. For such an NXDOMAIN, a DS
+// query without a matching NSEC3 or a referral, NSEC3_OPTOUT is returned:
+// the denial is insecure. A positive answer returns 0 and no error.
+// This function does not validate the NSEC3s.
+func (m *Msg) Nsec3Verify(q Question) (int, error) {
+	var nsec3 []*RR_NSEC3
+	for _, r := range m.Ns {
+		if n, ok := r.(*RR_NSEC3); ok {
+			nsec3 = append(nsec3, n)
 		}
-		if !ncdenied {
-			if m.MsgHdr.Rcode == RcodeNameError {
-				// For NXDOMAIN this is a problem
-				return 0, ErrDenialNc // add next closer name here
+	}
+	qname := strings.ToLower(q.Name)
+
+	if len(m.Answer) > 0 {
+		// Wildcard expansion: the RRSIG has fewer labels than its owner
+		// name, the next closer name must not exist
+		for _, r := range m.Answer {
+			sig, ok := r.(*RR_RRSIG)
+			if !ok {
+				continue
+			}
+			labels := SplitLabels(strings.ToLower(sig.Hdr.Name))
+			if int(sig.Labels) >= len(labels) {
+				continue
+			}
+			nc := strings.Join(labels[len(labels)-int(sig.Labels)-1:], ".") + "."
+			if nsec3Cover(nsec3, nc) == nil {
+				return 0, ErrDenialNc
 			}
-			goto NoData
+			return NSEC3_WILDCARD, nil
 		}
+		return 0, nil
+	}
+	if len(nsec3) == 0 {
+		return 0, ErrDenialNsec3
+	}
 
-		// Check if the source of synthesis is covered and thus also denied
-		for _, nsec := range nsec3 {
-			if nsec.Cover(so) {
-				sodenied = true
-				break
+	// A referral, the delegation must be shown to have no DS
+	referral, soa := "", false
+	for _, r := range m.Ns {
+		switch r.Header().Rrtype {
+		case TypeSOA:
+			soa = true
+		case TypeNS:
+			referral = strings.ToLower(r.Header().Name)
+		}
+	}
+	if referral != "" && !soa && q.Qtype != TypeDS {
+		if n := nsec3Match(nsec3, referral); n != nil {
+			if bitmapHas(n.TypeBitMap, TypeDS) || bitmapHas(n.TypeBitMap, TypeSOA) || !bitmapHas(n.TypeBitMap, TypeNS) {
+				return 0, ErrDenialBit
 			}
+			return NSEC3_NODS, nil
 		}
-		if !sodenied {
-			return 0, ErrDenialSo
+		if _, _, cover := closestEncloser(nsec3, referral); cover != nil && cover.Flags&1 == 1 {
+			return NSEC3_OPTOUT, nil
 		}
-		// The message headers claims something different!
-		if m.MsgHdr.Rcode != RcodeNameError {
+		return 0, ErrDenialDs
+	}
+
+	if n := nsec3Match(nsec3, qname); n != nil {
+		// The name exists, the type and a CNAME must not
+		if bitmapHas(n.TypeBitMap, q.Qtype) || bitmapHas(n.TypeBitMap, TypeCNAME) {
+			return 0, ErrDenialBit
+		}
+		// An NSEC3 from the parent side of a delegation only denies the DS
+		if bitmapHas(n.TypeBitMap, TypeNS) && !bitmapHas(n.TypeBitMap, TypeSOA) && q.Qtype != TypeDS {
+			return 0, ErrDenialBit
+		}
+		if m.MsgHdr.Rcode == RcodeNameError {
 			return 0, ErrDenialHdr
 		}
+		return NSEC3_NODATA, nil
+	}
 
-		return NSEC3_NXDOMAIN, nil
+	ce, _, cover := closestEncloser(nsec3, qname)
+	if ce == "" {
+		return 0, ErrDenialCe
 	}
-	return 0, nil
-NoData:
-	// For NODATA we need to to check if the matching nsec3 has to correct type bit map
-	// And we need to check that the wildcard does NOT exist
-	for _, nsec := range nsec3 {
-		if nsec.Cover(so) {
-			sodenied = true
-			break
+	if cover == nil {
+		return 0, ErrDenialNc
+	}
+	optout := cover.Flags&1 == 1
+	if q.Qtype == TypeDS && m.MsgHdr.Rcode == RcodeSuccess {
+		// No NSEC3 for the name, only an opt-out span can explain that
+		if !optout {
+			return 0, ErrDenialNc
 		}
+		return NSEC3_OPTOUT, nil
 	}
-	if sodenied {
-		// Whoa, the closest encloser is denied, but there does exist
-		// a wildcard a that level. That's not good
-		return 0, ErrDenialWc
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
 	}
-
-	// The closest encloser MUST be the query name
-	for _, nsec := range nsec3 {
-		if nsec.Match(nc) {
-			// This nsec3 must NOT have the type bitmap set of the qtype. If it does have it, return an error
-			for _, t := range nsec.TypeBitMap {
-				if t == q.Qtype {
-					return 0, ErrDenialBit
-				}
-			}
+	if n := nsec3Match(nsec3, wildcard); n != nil {
+		// A wildcard NODATA
+		if bitmapHas(n.TypeBitMap, q.Qtype) || bitmapHas(n.TypeBitMap, TypeCNAME) {
+			return 0, ErrDenialBit
+		}
+		if m.MsgHdr.Rcode == RcodeNameError {
+			return 0, ErrDenialHdr
 		}
+		return NSEC3_NODATA, nil
 	}
-	if m.MsgHdr.Rcode == RcodeNameError {
+	if nsec3Cover(nsec3, wildcard) == nil {
+		return 0, ErrDenialSo
+	}
+	if m.MsgHdr.Rcode != RcodeNameError {
 		return 0, ErrDenialHdr
 	}
-	return NSEC3_NODATA, nil
+	if optout {
+		return NSEC3_OPTOUT, nil
+	}
+	return NSEC3_NXDOMAIN, nil
+}
+
+// closestEncloser returns the closest encloser of name, the longest
+// ancestor of name with a matching NSEC3, and the next closer name, the
+// name one label longer. The NSEC3 covering the next closer is also
+// returned, it is nil when there is none. RFC 5155 section 8.3.
+func closestEncloser(nsec3 []*RR_NSEC3, name string) (ce, nc string, cover *RR_NSEC3) {
+	labels := SplitLabels(name)
+	for i := 1; i <= len(labels); i++ {
+		ce = strings.Join(labels[i:], ".") + "."
+		if i == len(labels) {
+			ce = "."
+		}
+		if nsec3Match(nsec3, ce) != nil {
+			nc = strings.Join(labels[i-1:], ".") + "."
+			return ce, nc, nsec3Cover(nsec3, nc)
+		}
+	}
+	return "", "", nil
+}
+
+// nsec3Match returns the NSEC3 matching name, or nil.
+func nsec3Match(nsec3 []*RR_NSEC3, name string) *RR_NSEC3 {
+	for _, n := range nsec3 {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsec3Cover returns the NSEC3 covering name, or nil.
+func nsec3Cover(nsec3 []*RR_NSEC3, name string) *RR_NSEC3 {
+	for _, n := range nsec3 {
+		if n.Cover(name) {
+			return n
+		}
+	}
+	return nil
 }
@@ -11,6 +11,7 @@ import (
 type scan struct {
 	src      *bufio.Reader
 	position scanner.Position
+	eol      bool // last byte was a newline
 }
 
 func scanInit(r io.Reader) *scan {
@@ -26,9 +27,14 @@ func (s *scan) tokenText() (byte, error) {
 	if err != nil {
 		return c, err
 	}
-	if c == '\n' {
+	// The newline itself still belongs to the current line
+	if s.eol {
 		s.position.Line++
 		s.position.Column = 0
+		s.eol = false
+	}
+	if c == '\n' {
+		s.eol = true
 	}
 	s.position.Column++
 	return c, nil
@@ -0,0 +1,231 @@
+package dns
+
+// Keeping a secondary zone up to date with its primary, see RFC 1034
+// section 4.3.5 and RFC 1996.
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a Secondary. It can be replaced
+// for testing.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the
+	// current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// The retry interval used when the zone has never been loaded.
+const secondaryRetry = 60 * time.Second
+
+// Secondary maintains a secondary copy of a zone. It polls the SOA of the
+// primary every refresh interval of the zone's SOA, and transfers the zone
+// when the serial of the primary is greater (RFC 1982). Ixfr is tried
+// first, Axfr is used when that fails or when the zone has not been loaded
+// yet. When the primary can not be reached, the SOA is polled again after
+// the retry interval. When the zone could not be refreshed for the expire
+// interval the zone expires and should no longer be served. A NOTIFY for
+// the zone triggers an immediate refresh.
+type Secondary struct {
+	Zone          *Zone    // the zone being maintained
+	Journal       *Journal // if not nil, the differences transferred are added to it
+	Primary       string   // address of the primary, host:port
+	Net           string   // network used for the SOA query, "udp" if empty; transfers always use "tcp"
+	TsigName      string   // if not empty, requests are signed with this key
+	TsigSecret    string   // secret of the TSIG key TsigName
+	TsigAlgorithm string   // algorithm of the TSIG key TsigName, HmacMD5 if empty
+	Clock         Clock    // if nil the system clock is used
+
+	mu      sync.Mutex
+	notify  chan bool // created when first needed
+	next    time.Time // time of the next refresh
+	expire  time.Time // time the zone expires
+	expired bool
+}
+
+// NewSecondary returns a Secondary for an empty zone origin, transferred
+// from primary.
+func NewSecondary(origin, primary string) *Secondary {
+	return &Secondary{Zone: NewZone(origin), Primary: primary}
+}
+
+// Notify schedules an immediate refresh of the zone. Call it when a NOTIFY
+// for the zone is received.
+func (s *Secondary) Notify() {
+	select {
+	case s.notifyChan() <- true:
+	default:
+	}
+}
+
+func (s *Secondary) notifyChan() chan bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notify == nil {
+		s.notify = make(chan bool, 1)
+	}
+	return s.notify
+}
+
+// Expired returns true when the zone has expired.
+func (s *Secondary) Expired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expired
+}
+
+// Run maintains the zone until quit is closed. It starts with a refresh.
+func (s *Secondary) Run(quit chan bool) {
+	notify := s.notifyChan()
+	s.Refresh()
+	for {
+		s.mu.Lock()
+		wait := s.next.Sub(s.clock().Now())
+		s.mu.Unlock()
+		select {
+		case <-quit:
+			return
+		case <-notify:
+		case <-s.clock().After(wait):
+		}
+		s.Refresh()
+	}
+}
+
+// Refresh queries the SOA of the primary, and transfers the zone when the
+// primary has a newer serial. It sets the time of the next refresh: after
+// the refresh interval when successful, after the retry interval when not.
+// It returns the error that made the refresh fail.
+func (s *Secondary) Refresh() error {
+	now := s.clock().Now()
+	err := s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	soa := s.Zone.Soa()
+	switch {
+	case err == nil:
+		s.expired = false
+		s.next = now.Add(time.Duration(soa.Refresh) * time.Second)
+		s.expire = now.Add(time.Duration(soa.Expire) * time.Second)
+	case soa == nil:
+		s.next = now.Add(secondaryRetry)
+	default:
+		if s.expire.IsZero() {
+			// Loaded from somewhere else, start counting now
+			s.expire = now.Add(time.Duration(soa.Expire) * time.Second)
+		}
+		if !now.Before(s.expire) {
+			s.expired = true
+		}
+		s.next = now.Add(time.Duration(soa.Retry) * time.Second)
+		if !s.expired && s.next.After(s.expire) {
+			s.next = s.expire
+		}
+	}
+	return err
+}
+
+func (s *Secondary) refresh() error {
+	soa := s.Zone.Soa()
+	m := new(Msg)
+	m.SetQuestion(s.Zone.Origin, TypeSOA)
+	m.RecursionDesired = false
+	in, err := s.exchange(m)
+	if err != nil {
+		return err
+	}
+	if in.Rcode != RcodeSuccess {
+		return &Error{Err: "dns: bad rcode: " + Rcode_str[in.Rcode], Name: s.Zone.Origin}
+	}
+	var primary *RR_SOA
+	for _, r := range in.Answer {
+		if p, ok := r.(*RR_SOA); ok {
+			primary = p
+		}
+	}
+	if primary == nil {
+		return ErrXfrSoa
+	}
+	if soa != nil && !SerialGreater(primary.Serial, soa.Serial) {
+		return nil
+	}
+	if soa != nil {
+		m := new(Msg)
+		m.SetIxfr(s.Zone.Origin, soa.Serial)
+		if err := s.transfer(m); err == nil {
+			return nil
+		}
+	}
+	m = new(Msg)
+	m.SetAxfr(s.Zone.Origin)
+	return s.transfer(m)
+}
+
+// exchange sends m to the primary and returns the reply, checking the TSIG.
+func (s *Secondary) exchange(m *Msg) (*Msg, error) {
+	c := s.client()
+	if s.Net != "" {
+		c.Net = s.Net
+	}
+	s.sign(m)
+	return c.exchangeTsig(m, s.Primary)
+}
+
+// transfer performs the transfer m and applies it to the zone.
+func (s *Secondary) transfer(m *Msg) error {
+	c := s.client()
+	c.Net = "tcp"
+	s.sign(m)
+	quit := make(chan bool)
+	defer close(quit)
+	ch, err := c.XfrIn(m, s.Primary, quit)
+	if err != nil {
+		return err
+	}
+	diffs, err := s.Zone.ApplyIxfr(ch)
+	if err != nil {
+		return err
+	}
+	if s.Journal != nil {
+		for _, d := range diffs {
+			if s.Journal.Add(d) != nil {
+				// Does not continue the journal, start a new one
+				s.Journal.Diffs = []*ZoneDiff{d}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Secondary) client() *Client {
+	c := NewClient()
+	if s.TsigName != "" {
+		c.TsigSecret = map[string]string{s.TsigName: s.TsigSecret}
+	}
+	return c
+}
+
+func (s *Secondary) sign(m *Msg) {
+	if s.TsigName != "" {
+		alg := s.TsigAlgorithm
+		if alg == "" {
+			alg = HmacMD5
+		}
+		m.SetTsig(s.TsigName, alg, 300, m.MsgHdr.Id, time.Now().Unix())
+	}
+}
+
+func (s *Secondary) clock() Clock {
+	if s.Clock == nil {
+		return systemClock{}
+	}
+	return s.Clock
+}
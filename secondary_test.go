@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After never fires, time only moves with Advance.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSecondary(t *testing.T) {
+	secret := map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}
+	primary := NewZone("miek.nl.")
+	for _, r := range xfrTestZone(1, 10) {
+		primary.Insert(r)
+	}
+	journal := new(Journal)
+	noIxfr := false
+	var mu sync.Mutex
+	update := func(serial uint32, n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		old := primary.RRs()
+		for _, r := range old {
+			primary.Remove(r)
+		}
+		for _, r := range xfrTestZone(serial, n) {
+			primary.Insert(r)
+		}
+		d, _ := Diff(old, primary.RRs())
+		journal.Add(d)
+	}
+
+	mux := NewServeMux()
+	mux.HandleFunc("miek.nl.", func(w ResponseWriter, r *Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Question[0].Qtype == TypeIXFR && noIxfr {
+			m := new(Msg)
+			m.SetRcode(r, RcodeNotImplemented)
+			w.Write(m)
+			return
+		}
+		if r.Question[0].Qtype == TypeAXFR || r.Question[0].Qtype == TypeIXFR {
+			XfrSendZone(w, r, primary.RRs(), journal)
+			return
+		}
+		m := new(Msg)
+		m.SetReply(r)
+		m.Answer = []RR{primary.Soa()}
+		if r.IsTsig() {
+			m.SetTsig("axfr.", r.Extra[len(r.Extra)-1].(*RR_TSIG).Algorithm, 300, m.MsgHdr.Id, time.Now().Unix())
+		}
+		w.Write(m)
+	})
+	go (&Server{Addr: "127.0.0.1:8057", Net: "tcp", Handler: mux, TsigSecret: secret}).ListenAndServe()
+	go (&Server{Addr: "127.0.0.1:8057", Net: "udp", Handler: mux, TsigSecret: secret}).ListenAndServe()
+	time.Sleep(2e8)
+
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	s := NewSecondary("miek.nl.", "127.0.0.1:8057")
+	s.Clock = clock
+	s.TsigName = "axfr."
+	s.TsigSecret = secret["axfr."]
+	s.Journal = new(Journal)
+
+	check := func(serial uint32) {
+		if err := s.Refresh(); err != nil {
+			t.Fatalf("Failed to refresh to serial %d: %s", serial, err)
+		}
+		if s.Zone.Soa().Serial != serial || zoneString(s.Zone) != zoneString(primary) {
+			t.Fatalf("Zone not transferred, serial %d", s.Zone.Soa().Serial)
+		}
+		if s.next != clock.Now().Add(14400*time.Second) {
+			t.Fatalf("Next refresh not set to the refresh interval")
+		}
+	}
+	check(1) // Axfr
+	update(2, 15)
+	check(2) // Ixfr
+	if len(s.Journal.Diffs) != 1 {
+		t.Fatalf("Expected the Ixfr in the journal")
+	}
+	noIxfr = true
+	update(3, 5)
+	check(3) // Axfr fallback
+	if len(s.Journal.Diffs) != 2 {
+		t.Fatalf("Expected the Axfr in the journal")
+	}
+	noIxfr = false
+
+	// Primary unreachable
+	s.Primary = "127.0.0.1:8059"
+	s.Net = "tcp"
+	clock.Advance(14400 * time.Second)
+	if err := s.Refresh(); err == nil || s.Expired() {
+		t.Fatalf("Refresh should fail without expiring the zone")
+	}
+	if s.next != clock.Now().Add(3600*time.Second) {
+		t.Fatalf("Next refresh not set to the retry interval")
+	}
+	clock.Advance(604800 * time.Second)
+	if err := s.Refresh(); err == nil || !s.Expired() {
+		t.Fatalf("Zone should have expired")
+	}
+
+	// Run, and react to a NOTIFY
+	s.Primary = "127.0.0.1:8057"
+	s.Net = ""
+	quit := make(chan bool)
+	go s.Run(quit)
+	defer close(quit)
+	for i := 0; s.Expired(); i++ {
+		if i == 20 {
+			t.Fatalf("Zone not refreshed after Run")
+		}
+		time.Sleep(1e8)
+	}
+	update(4, 20)
+	s.Notify()
+	for i := 0; ; i++ {
+		if soa := s.Zone.Soa(); soa.Serial == 4 && !s.Expired() {
+			break
+		}
+		if i == 20 {
+			t.Fatalf("Zone not refreshed after NOTIFY")
+		}
+		time.Sleep(1e8)
+	}
+
+	// A Secondary not made by NewSecondary, with an HMAC-SHA256 key
+	s1 := &Secondary{Zone: NewZone("miek.nl."), Primary: "127.0.0.1:8057", Clock: clock,
+		TsigName: "axfr.", TsigSecret: secret["axfr."], TsigAlgorithm: HmacSHA256}
+	if err := s1.Refresh(); err != nil {
+		t.Fatalf("Failed to refresh with HMAC-SHA256: %s", err)
+	}
+	if zoneString(s1.Zone) != zoneString(primary) {
+		t.Fatalf("Zone not transferred with HMAC-SHA256")
+	}
+	s1.Notify()
+	select {
+	case <-s1.notify:
+	default:
+		t.Fatalf("NOTIFY lost")
+	}
+}
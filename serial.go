@@ -0,0 +1,61 @@
+package dns
+
+// Serial number arithmetic, see RFC 1982. This is used for SOA serials
+// and for the inception and expiration times of RRSIGs.
+
+import (
+	"time"
+)
+
+// Schemes for NextSerial.
+const (
+	SerialIncrement = iota // increment the serial
+	SerialUnixTime         // the current time in seconds since the epoch
+	SerialDate             // the current date in the form YYYYMMDDnn
+)
+
+// SerialLess returns true if serial s1 is less than s2. When s1 and s2
+// are 2^31 apart the comparison is undefined and false is returned.
+func SerialLess(s1, s2 uint32) bool {
+	return s1 != s2 && int32(s2-s1) > 0
+}
+
+// SerialGreater returns true if serial s1 is greater than s2. When s1 and
+// s2 are 2^31 apart the comparison is undefined and false is returned.
+func SerialGreater(s1, s2 uint32) bool {
+	return SerialLess(s2, s1)
+}
+
+// SerialAdd adds n to the serial s. Only values of n up to 2^31-1 are
+// defined, SerialAdd panics on larger ones.
+func SerialAdd(s, n uint32) uint32 {
+	if n > 1<<31-1 {
+		panic("dns: serial addition out of range")
+	}
+	return s + n
+}
+
+// NextSerial returns the serial to use after s, according to scheme with
+// now as the current time. With SerialUnixTime and SerialDate the serial
+// derived from now is used when it is greater than s, otherwise s is
+// incremented.
+func NextSerial(s uint32, scheme int, now time.Time) uint32 {
+	var n uint32
+	switch scheme {
+	case SerialUnixTime:
+		n = uint32(now.Unix())
+	case SerialDate:
+		y, m, d := now.UTC().Date()
+		n = uint32(y*1000000 + int(m)*10000 + d*100)
+	}
+	if scheme != SerialIncrement && SerialGreater(n, s) {
+		return n
+	}
+	return SerialAdd(s, 1)
+}
+
+// serialTime returns the time in seconds since the epoch of the 32 bit
+// time t, taking it to be within 68 years of now.
+func serialTime(t uint32, now int64) int64 {
+	return now + int64(int32(t-uint32(now)))
+}
@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSerialCompare(t *testing.T) {
+	tests := []struct {
+		s1, s2  uint32
+		greater bool
+	}{
+		{2, 1, true},
+		{1, 2, false},
+		{1, 1, false},
+		{0, 0xffffffff, true},
+		{0xffffffff, 0, false},
+		{1 << 31, 0, false}, // undefined, not greater
+		{0, 1 << 31, false},
+	}
+	for _, tc := range tests {
+		if SerialGreater(tc.s1, tc.s2) != tc.greater {
+			t.Logf("SerialGreater(%d, %d) should be %v", tc.s1, tc.s2, tc.greater)
+			t.Fail()
+		}
+		if tc.s1 != tc.s2 && tc.s1-tc.s2 != 1<<31 && SerialLess(tc.s2, tc.s1) != tc.greater {
+			t.Logf("SerialLess(%d, %d) should be %v", tc.s2, tc.s1, tc.greater)
+			t.Fail()
+		}
+	}
+	if SerialAdd(0xffffffff, 2) != 1 {
+		t.Log("SerialAdd should wrap")
+		t.Fail()
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	now := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	tests := []struct {
+		s      uint32
+		scheme int
+		next   uint32
+	}{
+		{1, SerialIncrement, 2},
+		{0xffffffff, SerialIncrement, 0},
+		{1, SerialUnixTime, uint32(now.Unix())},
+		{uint32(now.Unix()), SerialUnixTime, uint32(now.Unix()) + 1},
+		{2012030100, SerialDate, 2012030400},
+		{2012030400, SerialDate, 2012030401},
+		{2012030499, SerialDate, 2012030500},
+	}
+	for _, tc := range tests {
+		if n := NextSerial(tc.s, tc.scheme, now); n != tc.next {
+			t.Logf("NextSerial(%d, %d) should be %d, got %d", tc.s, tc.scheme, tc.next, n)
+			t.Fail()
+		}
+	}
+}
+
+func TestRRSIGTimeWrap(t *testing.T) {
+	// Around the 2106 wrap of 32 bit time
+	now := int64(1<<32 - 3600)
+	later, _ := DateToTime("21060301000000")
+	if s := timeToDate(later, now); s != "21060301000000" {
+		t.Logf("Expected 21060301000000, got %s", s)
+		t.Fail()
+	}
+	earlier, _ := DateToTime("21060101000000")
+	sig := &RR_RRSIG{Inception: earlier, Expiration: later}
+	if !sig.validityPeriod(now) {
+		t.Log("Signature should be valid across the wrap")
+		t.Fail()
+	}
+	if sig.validityPeriod(now + 90*86400) {
+		t.Log("Signature should have expired")
+		t.Fail()
+	}
+	if s := timeToDate(1296534305, 1296534305); s != "20110201042505" {
+		t.Logf("Expected 20110201042505, got %s", s)
+		t.Fail()
+	}
+}
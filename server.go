@@ -7,6 +7,7 @@
 package dns
 
 import (
+	"encoding/hex"
 	"io"
 	"net"
 	"time"
@@ -29,20 +30,47 @@ type ResponseWriter interface {
 	Write(*Msg) error
 }
 
+// A TsigWriter is a ResponseWriter that also describes the TSIG of the
+// request and can sign multiple envelopes sent in response to one request,
+// such as a zone transfer. The ResponseWriter given to handlers by the
+// server implements it. It is separate from ResponseWriter so existing
+// implementations of that keep working; XfrSend checks for it with a type
+// assertion.
+type TsigWriter interface {
+	ResponseWriter
+	// TsigResult returns the key name, algorithm and TSIG error code of
+	// the TSIG of the request, or nil when it has no TSIG.
+	TsigResult() *TsigResult
+	// TsigTimersOnly sets the tsig timers only boolean, used when
+	// multiple envelopes are sent in response to one request.
+	TsigTimersOnly(bool)
+}
+
+// TsigResult describes the TSIG of a request. Requests whose TSIG does
+// not verify are answered by the server itself with a TSIG error response,
+// as RFC 8945 requires; they never reach the handler.
+type TsigResult struct {
+	Name      string // owner name of the TSIG, the name of the key
+	Algorithm string // algorithm of the TSIG
+	Rcode     int    // RcodeSuccess, or the TSIG error: RcodeBadSig, RcodeBadKey, RcodeBadTime or RcodeBadTrunc
+}
+
 type conn struct {
-	remoteAddr net.Addr          // address of remote side
-	handler    Handler           // request handler
-	request    []byte            // bytes read
-	_UDP       *net.UDPConn      // i/o connection if UDP was used
-	_TCP       *net.TCPConn      // i/o connection if TCP was used
-	hijacked   bool              // connection has been hijacked by hander TODO(mg)
-	tsigSecret map[string]string // the tsig secrets
+	remoteAddr   net.Addr          // address of remote side
+	handler      Handler           // request handler
+	request      []byte            // bytes read
+	_UDP         *net.UDPConn      // i/o connection if UDP was used
+	_TCP         *net.TCPConn      // i/o connection if TCP was used
+	hijacked     bool              // connection has been hijacked by hander TODO(mg)
+	tsigSecret   map[string]string // the tsig secrets
+	tsigProvider TsigProvider      // if not nil, used instead of the tsig secrets
 }
 
 type response struct {
 	conn           *conn
 	req            *Msg
 	tsigStatus     error
+	tsig           *TsigResult
 	tsigTimersOnly bool
 	tsigRequestMAC string
 }
@@ -166,6 +194,7 @@ type Server struct {
 	ReadTimeout  time.Duration     // the net.Conn.SetReadTimeout value for new connections
 	WriteTimeout time.Duration     // the net.Conn.SetWriteTimeout value for new connections
 	TsigSecret   map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>
+	TsigProvider TsigProvider      // if not nil, computes and verifies the Tsig MACs instead of TsigSecret
 }
 
 // ListenAndServe starts a nameserver on the configured addressin *Server.
@@ -243,7 +272,7 @@ forever:
 			i += j
 		}
 		n = i
-		d, err := newConn(rw, nil, rw.RemoteAddr(), m, handler, srv.TsigSecret)
+		d, err := newConn(rw, nil, rw.RemoteAddr(), m, handler, srv.TsigSecret, srv.TsigProvider)
 		if err != nil {
 			continue
 		}
@@ -278,7 +307,7 @@ func (srv *Server) ServeUDP(l *net.UDPConn) error {
 		if srv.WriteTimeout != 0 {
 			l.SetWriteDeadline(time.Now().Add(srv.WriteTimeout))
 		}
-		d, err := newConn(nil, l, a, m, handler, srv.TsigSecret)
+		d, err := newConn(nil, l, a, m, handler, srv.TsigSecret, srv.TsigProvider)
 		if err != nil {
 			continue
 		}
@@ -287,7 +316,7 @@ func (srv *Server) ServeUDP(l *net.UDPConn) error {
 	panic("not reached")
 }
 
-func newConn(t *net.TCPConn, u *net.UDPConn, a net.Addr, buf []byte, handler Handler, tsig map[string]string) (*conn, error) {
+func newConn(t *net.TCPConn, u *net.UDPConn, a net.Addr, buf []byte, handler Handler, tsig map[string]string, provider TsigProvider) (*conn, error) {
 	c := new(conn)
 	c.handler = handler
 	c._TCP = t
@@ -295,9 +324,18 @@ func newConn(t *net.TCPConn, u *net.UDPConn, a net.Addr, buf []byte, handler Han
 	c.remoteAddr = a
 	c.request = buf
 	c.tsigSecret = tsig
+	c.tsigProvider = provider
 	return c, nil
 }
 
+// provider returns the TSIG provider for the key name.
+func (c *conn) provider(name string) TsigProvider {
+	if c.tsigProvider != nil {
+		return c.tsigProvider
+	}
+	return tsigHMACProvider(c.tsigSecret[name])
+}
+
 // Close the connection.
 func (c *conn) close() {
 	switch {
@@ -326,16 +364,22 @@ func (c *conn) serve() {
 		}
 
 		w.tsigStatus = nil
+		w.req = req
 		if req.IsTsig() {
-			secret := req.Extra[len(req.Extra)-1].(*RR_TSIG).Hdr.Name
-			if _, ok := w.conn.tsigSecret[secret]; !ok {
-				w.tsigStatus = ErrKeyAlg
+			t := req.Extra[len(req.Extra)-1].(*RR_TSIG)
+			if _, ok := w.conn.tsigSecret[t.Hdr.Name]; !ok && w.conn.tsigProvider == nil {
+				w.tsigStatus = ErrKey
+			} else {
+				w.tsigStatus = TsigVerifyProvider(c.request, w.conn.provider(t.Hdr.Name), "", false)
 			}
-			w.tsigStatus = TsigVerify(c.request, w.conn.tsigSecret[secret], "", false)
 			w.tsigTimersOnly = false // Will this ever be true?
-			w.tsigRequestMAC = req.Extra[len(req.Extra)-1].(*RR_TSIG).MAC
+			w.tsigRequestMAC = t.MAC
+			w.tsig = &TsigResult{Name: t.Hdr.Name, Algorithm: t.Algorithm, Rcode: tsigRcode(w.tsigStatus)}
+			if w.tsig.Rcode != RcodeSuccess {
+				w.writeTsigError(t)
+				break
+			}
 		}
-		w.req = req
 		c.handler.ServeDNS(w, w.req) // this does the writing back to the client
 		if c.hijacked {
 			return
@@ -347,13 +391,54 @@ func (c *conn) serve() {
 	}
 }
 
+// tsigRcode returns the TSIG error code for the verification error err.
+func tsigRcode(err error) int {
+	switch err {
+	case nil:
+		return RcodeSuccess
+	case ErrKey, ErrKeyAlg:
+		return RcodeBadKey
+	case ErrTime:
+		return RcodeBadTime
+	case ErrTrunc:
+		return RcodeBadTrunc
+	}
+	return RcodeBadSig
+}
+
+// writeTsigError answers a request whose TSIG t did not verify, RFC 8945
+// section 5.3.2. The answer is unsigned, except for BADTIME which is signed
+// and carries the time of the server in the other data.
+func (w *response) writeTsigError(t *RR_TSIG) error {
+	m := new(Msg)
+	m.Id = w.req.Id
+	m.Response = true
+	m.Opcode = w.req.Opcode
+	m.Rcode = RcodeNotAuth
+	m.Question = w.req.Question
+	e := &RR_TSIG{Hdr: RR_Header{Name: t.Hdr.Name, Rrtype: TypeTSIG, Class: ClassANY},
+		Algorithm: t.Algorithm, TimeSigned: t.TimeSigned, Fudge: t.Fudge, OrigId: t.OrigId, Error: uint16(w.tsig.Rcode)}
+	m.Extra = []RR{e}
+	if w.tsig.Rcode == RcodeBadTime {
+		now := uint64(time.Now().Unix())
+		e.OtherLen = 6
+		e.OtherData = hex.EncodeToString([]byte{byte(now >> 40), byte(now >> 32), byte(now >> 24), byte(now >> 16), byte(now >> 8), byte(now)})
+		return w.Write(m)
+	}
+	data, ok := m.Pack()
+	if !ok {
+		return ErrPack
+	}
+	return w.write(data)
+}
+
 func (w *response) Write(m *Msg) (err error) {
 	var (
 		data []byte
 		ok   bool
 	)
 	if m.IsTsig() {
-		data, w.tsigRequestMAC, err = TsigGenerate(m, w.conn.tsigSecret[m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name], w.tsigRequestMAC, w.tsigTimersOnly)
+		data, w.tsigRequestMAC, err = TsigGenerateProvider(m, w.conn.provider(m.Extra[len(m.Extra)-1].(*RR_TSIG).Hdr.Name), w.tsigRequestMAC, w.tsigTimersOnly)
 		if err != nil {
 			return err
 		}
@@ -363,6 +448,11 @@ func (w *response) Write(m *Msg) (err error) {
 			return ErrPack
 		}
 	}
+	return w.write(data)
+}
+
+// write writes the packed message data to the client.
+func (w *response) write(data []byte) error {
 	switch {
 	case w.conn._UDP != nil:
 		_, err := w.conn._UDP.WriteTo(data, w.conn.remoteAddr)
@@ -406,3 +496,13 @@ func (w *response) RemoteAddr() net.Addr { return w.conn.remoteAddr }
 func (w *response) TsigStatus() error {
 	return w.tsigStatus
 }
+
+// TsigResult implements the TsigWriter.TsigResult method
+func (w *response) TsigResult() *TsigResult {
+	return w.tsig
+}
+
+// TsigTimersOnly implements the TsigWriter.TsigTimersOnly method
+func (w *response) TsigTimersOnly(b bool) {
+	w.tsigTimersOnly = b
+}
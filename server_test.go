@@ -43,3 +43,72 @@ func BenchmarkServing(b *testing.B) {
 		c.Exchange(m, "127.0.0.1:8053")
 	}
 }
+
+func TestServerTsigError(t *testing.T) {
+	secret := map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}
+	mux := NewServeMux()
+	mux.HandleFunc("miek.nl.", func(w ResponseWriter, r *Msg) {
+		m := new(Msg)
+		m.SetReply(r)
+		if res := w.(TsigWriter).TsigResult(); res == nil || res.Name != "axfr." || res.Algorithm != HmacSHA256 || res.Rcode != RcodeSuccess {
+			m.Rcode = RcodeServerFailure
+		}
+		m.SetTsig("axfr.", HmacSHA256, 300, m.MsgHdr.Id, time.Now().Unix())
+		w.Write(m)
+	})
+	go (&Server{Addr: "127.0.0.1:8063", Net: "udp", Handler: mux, TsigSecret: secret}).ListenAndServe()
+	time.Sleep(2e8)
+
+	tests := []struct {
+		key, secret string
+		signed      int64 // offset of the time signed
+		err         error
+	}{
+		{"axfr.", secret["axfr."], 0, nil},
+		{"other.", secret["axfr."], 0, ErrKey},
+		{"axfr.", "c28gc2VjcmV0", 0, ErrSig},
+		{"axfr.", secret["axfr."], -1000, ErrTime},
+	}
+	for i, tc := range tests {
+		c := NewClient()
+		c.TsigSecret = map[string]string{tc.key: tc.secret}
+		m := new(Msg)
+		m.SetQuestion("miek.nl.", TypeSOA)
+		m.SetTsig(tc.key, HmacSHA256, 300, m.Id, time.Now().Unix()+tc.signed)
+
+		w := &reply{client: c, addr: "127.0.0.1:8063", req: m}
+		if err := w.Dial(); err != nil {
+			t.Fatalf("Failed to dial: %s", err)
+		}
+		if err := w.Send(m); err != nil {
+			t.Fatalf("Failed to send: %s", err)
+		}
+		in, err := w.Receive()
+		w.Close()
+		if err != nil {
+			t.Fatalf("Test %d: failed to receive: %s", i, err)
+		}
+		if w.TsigStatus() != tc.err {
+			t.Logf("Test %d: expected %v, got %v", i, tc.err, w.TsigStatus())
+			t.Fail()
+		}
+		tsig := in.Extra[len(in.Extra)-1].(*RR_TSIG)
+		switch tc.err {
+		case nil:
+			if in.Rcode != RcodeSuccess {
+				t.Logf("Test %d: handler saw the wrong TSIG result", i)
+				t.Fail()
+			}
+		case ErrTime:
+			if in.Rcode != RcodeNotAuth || tsig.MACSize == 0 || tsig.OtherLen != 6 || tsig.TimeSigned != uint64(time.Now().Unix()-1000) && tsig.TimeSigned != uint64(time.Now().Unix()-1001) {
+				t.Logf("Test %d: bad BADTIME response: %s", i, in)
+				t.Fail()
+			}
+		default:
+			if in.Rcode != RcodeNotAuth || tsig.MACSize != 0 || tsig.MAC != "" {
+				t.Logf("Test %d: expected an unsigned error response: %s", i, in)
+				t.Fail()
+			}
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package dns
+
+// Keeping trust anchors up to date when the keys of a zone roll, see
+// RFC 5011.
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The states of a key of a TrustAnchor, RFC 5011 section 4.
+const (
+	_          = iota
+	KeyAddPend // seen, waiting for the add hold-down time
+	KeyValid   // trusted
+	KeyMissing // trusted, but not seen in the zone anymore
+	KeyRevoked // revoked, waiting for the remove hold-down time
+	KeyRemoved // no longer used
+)
+
+// Map of strings for each of the key states, as used in the file written
+// by TrustAnchor.Write.
+var KeyState_str = map[int]string{
+	KeyAddPend: "ADDPEND",
+	KeyValid:   "VALID",
+	KeyMissing: "MISSING",
+	KeyRevoked: "REVOKED",
+	KeyRemoved: "REMOVED",
+}
+
+// The hold-down times and the limits of the refresh intervals of RFC 5011.
+const (
+	trustAnchorHoldDown = 30 * 24 * time.Hour
+	trustAnchorMaxQuery = 15 * 24 * time.Hour
+	trustAnchorMaxRetry = 24 * time.Hour
+	trustAnchorMinQuery = time.Hour
+)
+
+// AnchorKey is a key tracked by a TrustAnchor.
+type AnchorKey struct {
+	DNSKEY *RR_DNSKEY
+	State  int       // one of the Key* states
+	Since  time.Time // when the key entered its state
+}
+
+// TrustAnchor maintains the trust anchor of a zone as its keys roll. It
+// periodically fetches the DNSKEY RRset of the zone and, when the RRset
+// is signed by one of the trusted keys, tracks the keys with the SEP flag:
+// a new key is trusted after it has been seen for AddHoldDown, a key with
+// the REVOKE bit that signs the RRset itself is no longer trusted and is
+// removed after RemoveHoldDown. A trusted key that disappears from the
+// zone stays trusted.
+type TrustAnchor struct {
+	Zone           string
+	Keys           []*AnchorKey
+	AddHoldDown    time.Duration // 30 days when zero
+	RemoveHoldDown time.Duration // 30 days when zero
+	File           string        // if not empty, the state is written to File after each change
+	Server         string        // address of the resolver used to fetch the DNSKEY RRset, host:port
+	Client         *Client       // the client used to query Server, NewClient() when nil
+	Clock          Clock         // if nil the system clock is used
+
+	mu sync.Mutex
+}
+
+// NewTrustAnchor returns a TrustAnchor for the zone of keys, which are
+// trusted.
+func NewTrustAnchor(keys []*RR_DNSKEY) *TrustAnchor {
+	a := new(TrustAnchor)
+	for _, k := range keys {
+		a.Zone = strings.ToLower(k.Hdr.Name)
+		a.Keys = append(a.Keys, &AnchorKey{DNSKEY: k, State: KeyValid, Since: a.clock().Now()})
+	}
+	return a
+}
+
+// Anchors returns the trusted keys, for use in Validator.Anchors.
+func (a *TrustAnchor) Anchors() []RR {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rrs []RR
+	for _, k := range a.Keys {
+		if k.State == KeyValid || k.State == KeyMissing {
+			rrs = append(rrs, k.DNSKEY)
+		}
+	}
+	return rrs
+}
+
+// Refresh fetches the DNSKEY RRset of the zone and updates the keys with
+// it. It returns the time after which Refresh should be called again: the
+// active refresh interval after success, the retry interval after a
+// failure, RFC 5011 section 2.3.
+func (a *TrustAnchor) Refresh() (time.Duration, error) {
+	m := new(Msg)
+	m.SetQuestion(a.Zone, TypeDNSKEY)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+	c := a.Client
+	if c == nil {
+		c = NewClient()
+	}
+	r, err := c.Exchange(m, a.Server)
+	if err != nil {
+		return trustAnchorMinQuery, err
+	}
+	var rrset []RR
+	var sigs []*RR_RRSIG
+	for _, rr := range r.Answer {
+		switch rr := rr.(type) {
+		case *RR_DNSKEY:
+			rrset = append(rrset, rr)
+		case *RR_RRSIG:
+			if rr.TypeCovered == TypeDNSKEY {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+	if err := a.Update(rrset, sigs); err != nil {
+		return a.interval(rrset, sigs, 10, trustAnchorMaxRetry), err
+	}
+	return a.interval(rrset, sigs, 2, trustAnchorMaxQuery), nil
+}
+
+// Run refreshes the trust anchor until quit is closed. After each refresh
+// that failed, f is called with the error.
+func (a *TrustAnchor) Run(quit chan bool, f func(error)) {
+	for {
+		wait, err := a.Refresh()
+		if err != nil && f != nil {
+			f(err)
+		}
+		select {
+		case <-quit:
+			return
+		case <-a.clock().After(wait):
+		}
+	}
+}
+
+// Update updates the keys with the DNSKEY RRset rrset of the zone, signed
+// by sigs. The RRset must be signed by a trusted key, or ErrAnchor is
+// returned and nothing changes. A revoked key must have signed the RRset
+// itself to be revoked.
+func (a *TrustAnchor) Update(rrset []RR, sigs []*RR_RRSIG) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.clock().Now()
+	trusted := false
+	for _, k := range a.Keys {
+		if k.State != KeyValid && k.State != KeyMissing {
+			continue
+		}
+		if signedBy(rrset, sigs, k.DNSKEY, now) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return ErrAnchor
+	}
+
+	seen := make(map[*AnchorKey]bool)
+	changed := false
+	for _, r := range rrset {
+		key, ok := r.(*RR_DNSKEY)
+		if !ok || key.Flags&SEP == 0 {
+			continue
+		}
+		if key.Flags&REVOKE != 0 {
+			// Find the key as it was before it was revoked
+			k := a.find(key, REVOKE)
+			if k == nil || !signedBy(rrset, sigs, key, now) {
+				continue
+			}
+			seen[k] = true
+			switch k.State {
+			case KeyAddPend:
+				k.State, k.Since, changed = KeyRemoved, now, true
+			case KeyValid, KeyMissing:
+				k.State, k.Since, changed = KeyRevoked, now, true
+			}
+			continue
+		}
+		k := a.find(key, 0)
+		if k == nil {
+			k = &AnchorKey{DNSKEY: key, State: KeyAddPend, Since: now}
+			a.Keys = append(a.Keys, k)
+			seen[k], changed = true, true
+			continue
+		}
+		seen[k] = true
+		switch k.State {
+		case KeyAddPend:
+			if now.Sub(k.Since) >= a.holdDown(a.AddHoldDown) {
+				k.State, k.Since, changed = KeyValid, now, true
+			}
+		case KeyMissing:
+			k.State, k.Since, changed = KeyValid, now, true
+		}
+	}
+
+	keys := a.Keys[:0]
+	for _, k := range a.Keys {
+		switch {
+		case k.State == KeyAddPend && !seen[k]:
+			// Gone before it was trusted
+			changed = true
+			continue
+		case k.State == KeyValid && !seen[k]:
+			k.State, k.Since, changed = KeyMissing, now, true
+		case k.State == KeyRevoked && now.Sub(k.Since) >= a.holdDown(a.RemoveHoldDown):
+			k.State, k.Since, changed = KeyRemoved, now, true
+		}
+		keys = append(keys, k)
+	}
+	a.Keys = keys
+	if changed && a.File != "" {
+		return a.save()
+	}
+	return nil
+}
+
+// find returns the tracked key that equals key, ignoring the flags in
+// ignore.
+func (a *TrustAnchor) find(key *RR_DNSKEY, ignore uint16) *AnchorKey {
+	for _, k := range a.Keys {
+		if k.DNSKEY.Flags&^ignore == key.Flags&^ignore && k.DNSKEY.Algorithm == key.Algorithm &&
+			k.DNSKEY.PublicKey == key.PublicKey {
+			return k
+		}
+	}
+	return nil
+}
+
+// signedBy checks if one of sigs is a valid signature of rrset made by key.
+func signedBy(rrset []RR, sigs []*RR_RRSIG, key *RR_DNSKEY, now time.Time) bool {
+	for _, sig := range sigs {
+		if sig.KeyTag == key.KeyTag() && sig.validityPeriod(now.Unix()) && sig.Verify(key, rrset) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// interval returns the refresh interval for the DNSKEY RRset: the
+// smallest of max, the TTL divided by div and the time until the first
+// signature expires divided by div, but at least an hour.
+func (a *TrustAnchor) interval(rrset []RR, sigs []*RR_RRSIG, div time.Duration, max time.Duration) time.Duration {
+	d := max
+	if len(rrset) > 0 {
+		if ttl := time.Duration(rrset[0].Header().Ttl) * time.Second / div; ttl < d {
+			d = ttl
+		}
+	}
+	now := a.clock().Now().Unix()
+	for _, sig := range sigs {
+		if exp := time.Duration(serialTime(sig.Expiration, now)-now) * time.Second / div; exp < d {
+			d = exp
+		}
+	}
+	if d < trustAnchorMinQuery {
+		d = trustAnchorMinQuery
+	}
+	return d
+}
+
+func (a *TrustAnchor) holdDown(d time.Duration) time.Duration {
+	if d == 0 {
+		return trustAnchorHoldDown
+	}
+	return d
+}
+
+func (a *TrustAnchor) clock() Clock {
+	if a.Clock == nil {
+		return systemClock{}
+	}
+	return a.Clock
+}
+
+// Write writes the state of the trust anchor to w, one key per line:
+// the DNSKEY followed by a comment with its state and the time it entered
+// the state, e.g.
+//
+//	miek.nl. 3600 IN DNSKEY 257 3 8 AwEAAc... ;state=VALID since=1350000000
+func (a *TrustAnchor) Write(w io.Writer) error {
+	for _, k := range a.Keys {
+		if _, err := io.WriteString(w, k.DNSKEY.String()+" ;state="+KeyState_str[k.State]+
+			" since="+strconv.FormatInt(k.Since.Unix(), 10)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save writes the state to File, replacing it only when it was written
+// completely.
+func (a *TrustAnchor) save() error {
+	f, err := os.Create(a.File + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = a.Write(f)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(a.File+".tmp", a.File)
+}
+
+// ReadTrustAnchor reads the state of a trust anchor written by Write.
+// Lines without a state are keys in the KeyValid state, so a file with
+// plain DNSKEY records can be used to start.
+func ReadTrustAnchor(r io.Reader) (*TrustAnchor, error) {
+	a := new(TrustAnchor)
+	b := bufio.NewReader(r)
+	for {
+		line, err := b.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if l := strings.TrimSpace(line); l != "" && l[0] != ';' {
+			k, err1 := readAnchorKey(l)
+			if err1 != nil {
+				return nil, err1
+			}
+			a.Zone = strings.ToLower(k.DNSKEY.Hdr.Name)
+			a.Keys = append(a.Keys, k)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(a.Keys) == 0 {
+		return nil, &Error{Err: "dns: no keys in trust anchor"}
+	}
+	return a, nil
+}
+
+// ReadTrustAnchorFile reads the trust anchor from file with ReadTrustAnchor
+// and sets File, so changes are written back to it.
+func ReadTrustAnchorFile(file string) (*TrustAnchor, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := ReadTrustAnchor(f)
+	if err != nil {
+		return nil, err
+	}
+	a.File = file
+	return a, nil
+}
+
+func readAnchorKey(line string) (*AnchorKey, error) {
+	k := &AnchorKey{State: KeyValid}
+	if i := strings.Index(line, ";state="); i >= 0 {
+		for _, f := range strings.Fields(line[i+1:]) {
+			switch {
+			case strings.HasPrefix(f, "state="):
+				k.State = 0
+				for s, str := range KeyState_str {
+					if str == f[6:] {
+						k.State = s
+					}
+				}
+				if k.State == 0 {
+					return nil, &Error{Err: "dns: bad trust anchor state", Name: f}
+				}
+			case strings.HasPrefix(f, "since="):
+				since, err := strconv.ParseInt(f[6:], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				k.Since = time.Unix(since, 0)
+			}
+		}
+		line = line[:i]
+	}
+	r, err := NewRR(line)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := r.(*RR_DNSKEY)
+	if !ok {
+		return nil, &Error{Err: "dns: trust anchor is not a DNSKEY", Name: line}
+	}
+	k.DNSKEY = key
+	return k, nil
+}
@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTrustAnchor(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	old, next := signTestKey(t, ZONE|SEP), signTestKey(t, ZONE|SEP)
+	revoked := &SigningKey{new(RR_DNSKEY), old.Private}
+	*revoked.DNSKEY = *old.DNSKEY
+	revoked.DNSKEY.Flags |= REVOKE
+
+	// The zone rolls its keys by changing the published keys
+	var mu sync.Mutex
+	var answer []RR
+	publishBy := func(signers []*SigningKey, keys ...*SigningKey) {
+		mu.Lock()
+		defer mu.Unlock()
+		var rrset []RR
+		for _, k := range keys {
+			rrset = append(rrset, k.DNSKEY)
+		}
+		s := &ZoneSigner{Clock: clock}
+		sigs, err := s.sign(signers, "miek.nl.", rrset)
+		if err != nil {
+			t.Fatalf("Failed to sign the DNSKEY RRset: %s", err)
+		}
+		answer = append(rrset, sigs...)
+	}
+	publish := func(keys ...*SigningKey) { publishBy(keys, keys...) }
+	mux := NewServeMux()
+	mux.HandleFunc("miek.nl.", func(w ResponseWriter, r *Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		m := new(Msg)
+		m.SetReply(r)
+		m.Answer = answer
+		w.Write(m)
+	})
+	go (&Server{Addr: "127.0.0.1:8064", Net: "udp", Handler: mux}).ListenAndServe()
+	time.Sleep(2e8)
+
+	dir, err := ioutil.TempDir("", "trustanchor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := NewTrustAnchor([]*RR_DNSKEY{old.DNSKEY})
+	a.Server, a.Clock, a.File = "127.0.0.1:8064", clock, filepath.Join(dir, "miek.nl.anchor")
+
+	refresh := func(step string, states ...int) {
+		if _, err := a.Refresh(); err != nil {
+			t.Fatalf("%s: failed to refresh: %s", step, err)
+		}
+		if len(a.Keys) != len(states) {
+			t.Fatalf("%s: expected %d keys, got %d", step, len(states), len(a.Keys))
+		}
+		for i, k := range a.Keys {
+			if k.State != states[i] {
+				t.Fatalf("%s: expected key %d to be %s, got %s", step, k.DNSKEY.KeyTag(), KeyState_str[states[i]], KeyState_str[k.State])
+			}
+		}
+		b, err := ReadTrustAnchorFile(a.File)
+		if err != nil {
+			t.Fatalf("%s: failed to read the state: %s", step, err)
+		}
+		for i, k := range b.Keys {
+			if k.State != states[i] || k.Since.Unix() != a.Keys[i].Since.Unix() || k.DNSKEY.String() != a.Keys[i].DNSKEY.String() {
+				t.Fatalf("%s: key %d not saved, got %s", step, i, KeyState_str[k.State])
+			}
+		}
+	}
+
+	publish(old)
+	if _, err := a.Refresh(); err != nil {
+		t.Fatalf("Failed to refresh: %s", err)
+	}
+	publish(old, next)
+	refresh("new key", KeyValid, KeyAddPend)
+	if len(a.Anchors()) != 1 {
+		t.Fatalf("Pending key is trusted")
+	}
+	clock.Advance(15 * 24 * time.Hour)
+	publish(old, next)
+	refresh("half the hold-down", KeyValid, KeyAddPend)
+	clock.Advance(15 * 24 * time.Hour)
+	publish(old, next)
+	refresh("after the hold-down", KeyValid, KeyValid)
+	if len(a.Anchors()) != 2 {
+		t.Fatalf("New key is not trusted")
+	}
+
+	// The new key disappears, but stays trusted
+	publish(old)
+	refresh("missing key", KeyValid, KeyMissing)
+	publish(old, next)
+	refresh("key is back", KeyValid, KeyValid)
+
+	// The old key is revoked, but only when it signs itself
+	publishBy([]*SigningKey{next}, revoked, next)
+	refresh("unsigned revocation", KeyMissing, KeyValid)
+	publish(revoked, next)
+	refresh("revoked", KeyRevoked, KeyValid)
+	if len(a.Anchors()) != 1 {
+		t.Fatalf("Revoked key is trusted")
+	}
+	clock.Advance(30 * 24 * time.Hour)
+	publish(revoked, next)
+	refresh("after the remove hold-down", KeyRemoved, KeyValid)
+
+	// An RRset signed by an unknown key changes nothing
+	other := signTestKey(t, ZONE|SEP)
+	publish(other)
+	if _, err := a.Refresh(); err != ErrAnchor {
+		t.Fatalf("Expected %s, got %v", ErrAnchor, err)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadTrustAnchor(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read the state: %s", err)
+	}
+	if b.Zone != "miek.nl." || len(b.Keys) != 2 || b.Keys[0].State != KeyRemoved || b.Keys[1].State != KeyValid {
+		t.Fatalf("State not read back: %v", b.Keys)
+	}
+}
@@ -1,7 +1,8 @@
 // TRANSACTION SIGNATURE (TSIG)
 // 
 // An TSIG or transaction signature adds a HMAC TSIG record to each message sent. 
-// The supported algorithm include: HmacMD5, HmacSHA1 and HmacSHA256.
+// The supported algorithm include: HmacMD5, HmacSHA1, HmacSHA224, HmacSHA256,
+// HmacSHA384 and HmacSHA512 (RFC 4635). MACs may be truncated.
 //
 // Basic use pattern when querying with a TSIG name "axfr." and the base64
 // secret "so6ZGir4GPAqINNh9U5c3A==":
@@ -37,16 +38,16 @@
 //		m := new(Msg)
 //		m.SetReply(r)
 //		if r.IsTsig() {
-//			if w.TsigStatus() == nil {
-//				// *Msg r has an TSIG record and it was validated
-//				m.SetTsig("axfr.", dns.HmacMD5, 300, r.MsgHdr.Id, time.Now().Unix())
-//			} else {
-//				// *Msg r has an TSIG records and it was not valided
-//			}
+//			// *Msg r has an TSIG record and it was validated
+//			m.SetTsig("axfr.", dns.HmacMD5, 300, r.MsgHdr.Id, time.Now().Unix())
 //		}
 //		w.Write(m)
 //	}
 //
+// Requests with a TSIG that does not validate never reach the handler, the
+// server answers them with a BADKEY, BADSIG, BADTIME or BADTRUNC error
+// response (RFC 8945).
+//
 package dns
 
 import (
@@ -54,9 +55,9 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"hash"
-	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -66,7 +67,10 @@ import (
 const (
 	HmacMD5    = "hmac-md5.sig-alg.reg.int."
 	HmacSHA1   = "hmac-sha1."
+	HmacSHA224 = "hmac-sha224."
 	HmacSHA256 = "hmac-sha256."
+	HmacSHA384 = "hmac-sha384."
+	HmacSHA512 = "hmac-sha512."
 )
 
 // RFC 2845.
@@ -138,6 +142,79 @@ type timerWireFmt struct {
 	Fudge      uint16
 }
 
+// TsigProvider computes and verifies TSIG MACs. Implement it to keep
+// the keys outside of the process, for instance in a hardware module.
+// The default provider, used by TsigGenerate and TsigVerify, computes
+// an HMAC with a base64 encoded secret.
+type TsigProvider interface {
+	// Generate returns the MAC over msg, the wire data covered by the
+	// TSIG record t, using the algorithm and key named in t.
+	Generate(msg []byte, t *RR_TSIG) ([]byte, error)
+	// Verify checks the MAC in t over msg. The MAC in t may be
+	// truncated, in that case only its first t.MACSize octets are
+	// compared.
+	Verify(msg []byte, t *RR_TSIG) error
+}
+
+// tsigHMACProvider is a TsigProvider using the base64 encoded secret.
+type tsigHMACProvider string
+
+func (key tsigHMACProvider) Generate(msg []byte, t *RR_TSIG) ([]byte, error) {
+	// If we barf here, the caller is to blame
+	rawsecret, err := packBase64([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	var h hash.Hash
+	switch strings.ToLower(t.Algorithm) {
+	case HmacMD5:
+		h = hmac.New(md5.New, []byte(rawsecret))
+	case HmacSHA1:
+		h = hmac.New(sha1.New, []byte(rawsecret))
+	case HmacSHA224:
+		h = hmac.New(sha256.New224, []byte(rawsecret))
+	case HmacSHA256:
+		h = hmac.New(sha256.New, []byte(rawsecret))
+	case HmacSHA384:
+		h = hmac.New(sha512.New384, []byte(rawsecret))
+	case HmacSHA512:
+		h = hmac.New(sha512.New, []byte(rawsecret))
+	default:
+		return nil, ErrKeyAlg
+	}
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+func (key tsigHMACProvider) Verify(msg []byte, t *RR_TSIG) error {
+	mac, err := key.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	m := hex.EncodeToString(mac)
+	if len(t.MAC) > len(m) || strings.ToUpper(m[:len(t.MAC)]) != strings.ToUpper(t.MAC) {
+		return ErrSig
+	}
+	return nil
+}
+
+// The size in octets of the MACs of the HMAC algorithms.
+var tsigMACSize = map[string]int{
+	HmacMD5:    md5.Size,
+	HmacSHA1:   sha1.Size,
+	HmacSHA224: sha256.Size224,
+	HmacSHA256: sha256.Size,
+	HmacSHA384: sha512.Size384,
+	HmacSHA512: sha512.Size,
+}
+
+// tsigTruncOK checks if a MAC of size octets is a valid truncation of a
+// MAC of full octets: at least 10 octets and at least half of the full MAC,
+// RFC 4635 section 3.1.
+func tsigTruncOK(size, full int) bool {
+	return size >= 10 && 2*size >= full
+}
+
 // TsigGenerate fills out the TSIG record attached to the message.
 // The message should contain
 // a "stub" TSIG RR with the algorithm, key name (owner name of the RR), 
@@ -146,15 +223,19 @@ type timerWireFmt struct {
 // When TsigGenerate is called for the first time requestMAC is set to the empty string and
 // timersOnly is false.                                            
 // If something goes wrong an error is returned, otherwise it is nil. 
+//
+// To send a truncated MAC (RFC 4635), set MACSize in the stub to the
+// number of octets wanted. It must be at least 10 and at least half the
+// size of the full MAC, otherwise ErrTrunc is returned.
 func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, string, error) {
+	return TsigGenerateProvider(m, tsigHMACProvider(secret), requestMAC, timersOnly)
+}
+
+// TsigGenerateProvider works like TsigGenerate, but lets p compute the MAC.
+func TsigGenerateProvider(m *Msg, p TsigProvider, requestMAC string, timersOnly bool) ([]byte, string, error) {
 	if !m.IsTsig() {
 		panic("TSIG not last RR in additional")
 	}
-	// If we barf here, the caller is to blame
-	rawsecret, err := packBase64([]byte(secret))
-	if err != nil {
-		return nil, "", err
-	}
 
 	rr := m.Extra[len(m.Extra)-1].(*RR_TSIG)
 	m.Extra = m.Extra[0 : len(m.Extra)-1] // kill the TSIG from the msg
@@ -165,19 +246,17 @@ func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, s
 	buf := tsigBuffer(mbuf, rr, requestMAC, timersOnly)
 
 	t := new(RR_TSIG)
-	var h hash.Hash
-	switch rr.Algorithm {
-	case HmacMD5:
-		h = hmac.New(md5.New, []byte(rawsecret))
-	case HmacSHA1:
-		h = hmac.New(sha1.New, []byte(rawsecret))
-	case HmacSHA256:
-		h = hmac.New(sha256.New, []byte(rawsecret))
-	default:
-		return nil, "", ErrKeyAlg
+	mac, err := p.Generate(buf, rr)
+	if err != nil {
+		return nil, "", err
 	}
-	io.WriteString(h, string(buf))
-	t.MAC = hex.EncodeToString(h.Sum(nil))
+	if rr.MACSize != 0 && int(rr.MACSize) < len(mac) {
+		if !tsigTruncOK(int(rr.MACSize), len(mac)) {
+			return nil, "", ErrTrunc
+		}
+		mac = mac[:rr.MACSize]
+	}
+	t.MAC = hex.EncodeToString(mac)
 	t.MACSize = uint16(len(t.MAC) / 2) // Size is half!
 
 	t.Hdr = RR_Header{Name: rr.Hdr.Name, Rrtype: TypeTSIG, Class: ClassANY, Ttl: 0}
@@ -185,6 +264,9 @@ func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, s
 	t.TimeSigned = rr.TimeSigned
 	t.Algorithm = rr.Algorithm
 	t.OrigId = m.MsgHdr.Id
+	t.Error = rr.Error
+	t.OtherLen = rr.OtherLen
+	t.OtherData = rr.OtherData
 
 	tbuf := make([]byte, t.Len())
 	if off, ok := packRR(t, tbuf, 0, nil, false); ok {
@@ -200,11 +282,18 @@ func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, s
 // TsigVerify verifies the TSIG on a message. 
 // If the signature does not validate err contains the
 // error, otherwise it is nil.
+// A truncated MAC that is shorter than 10 octets or shorter than half of
+// the full MAC results in ErrTrunc, the server should then answer with
+// RcodeBadTrunc. ErrTrunc is also returned when the message is such an
+// answer.
 func TsigVerify(msg []byte, secret, requestMAC string, timersOnly bool) error {
-	rawsecret, err := packBase64([]byte(secret))
-	if err != nil {
-		return err
-	}
+	return TsigVerifyProvider(msg, tsigHMACProvider(secret), requestMAC, timersOnly)
+}
+
+// TsigVerifyProvider works like TsigVerify, but lets p verify the MAC.
+// The truncation of MACs of algorithms not known to this package is
+// not checked, that is left to p.
+func TsigVerifyProvider(msg []byte, p TsigProvider, requestMAC string, timersOnly bool) error {
 	// Srtip the TSIG from the incoming msg
 	stripped, tsig, err := stripTsig(msg)
 	if err != nil {
@@ -213,26 +302,22 @@ func TsigVerify(msg []byte, secret, requestMAC string, timersOnly bool) error {
 
 	buf := tsigBuffer(stripped, tsig, requestMAC, timersOnly)
 
+	if size, ok := tsigMACSize[strings.ToLower(tsig.Algorithm)]; ok {
+		switch {
+		case int(tsig.MACSize) > size || int(tsig.MACSize) != len(tsig.MAC)/2:
+			return ErrSig
+		case int(tsig.MACSize) < size && !tsigTruncOK(int(tsig.MACSize), size):
+			return ErrTrunc
+		}
+	}
+	if err := p.Verify(buf, tsig); err != nil {
+		return err
+	}
+	// The time is only checked when the MAC is valid, RFC 8945 section 5.2.3
 	ti := uint64(time.Now().Unix()) - tsig.TimeSigned
 	if uint64(tsig.Fudge) < ti {
 		return ErrTime
 	}
-
-	var h hash.Hash
-	switch tsig.Algorithm {
-	case HmacMD5:
-		h = hmac.New(md5.New, []byte(rawsecret))
-	case HmacSHA1:
-		h = hmac.New(sha1.New, []byte(rawsecret))
-	case HmacSHA256:
-		h = hmac.New(sha256.New, []byte(rawsecret))
-	default:
-		return ErrKeyAlg
-	}
-	io.WriteString(h, string(buf))
-	if strings.ToUpper(hex.EncodeToString(h.Sum(nil))) != strings.ToUpper(tsig.MAC) {
-		return ErrSig
-	}
 	return nil
 }
 
@@ -302,10 +387,6 @@ func stripTsig(msg []byte) ([]byte, *RR_TSIG, error) {
 	if dh.Arcount == 0 {
 		return nil, nil, ErrNoSig
 	}
-	// Rcode, see msg.go Unpack()
-	if int(dh.Bits&0xF) == RcodeNotAuth {
-		return nil, nil, ErrAuth
-	}
 
 	// Arrays.
 	dns.Question = make([]Question, dh.Qdcount)
@@ -316,15 +397,16 @@ func stripTsig(msg []byte) ([]byte, *RR_TSIG, error) {
 	for i := 0; i < len(dns.Question); i++ {
 		off, ok = unpackStruct(&dns.Question[i], msg, off)
 	}
+	update := int(dh.Bits>>11)&0xF == OpcodeUpdate
 	for i := 0; i < len(dns.Answer); i++ {
-		dns.Answer[i], off, ok = unpackRR(msg, off)
+		dns.Answer[i], off, ok = unpackRR(msg, off, update)
 	}
 	for i := 0; i < len(dns.Ns); i++ {
-		dns.Ns[i], off, ok = unpackRR(msg, off)
+		dns.Ns[i], off, ok = unpackRR(msg, off, update)
 	}
 	for i := 0; i < len(dns.Extra); i++ {
 		tsigoff = off
-		dns.Extra[i], off, ok = unpackRR(msg, off)
+		dns.Extra[i], off, ok = unpackRR(msg, off, false)
 		if dns.Extra[i].Header().Rrtype == TypeTSIG {
 			rr = dns.Extra[i].(*RR_TSIG)
 			// Adjust Arcount.
@@ -333,6 +415,23 @@ func stripTsig(msg []byte) ([]byte, *RR_TSIG, error) {
 			break
 		}
 	}
+	// Rcode, see msg.go Unpack()
+	if int(dh.Bits&0xF) == RcodeNotAuth {
+		if ok {
+			// A TSIG error response from the server, RFC 8945 section 5.3.2
+			switch int(rr.Error) {
+			case RcodeBadSig:
+				return nil, nil, ErrSig
+			case RcodeBadKey:
+				return nil, nil, ErrKey
+			case RcodeBadTime:
+				return nil, nil, ErrTime
+			case RcodeBadTrunc:
+				return nil, nil, ErrTrunc
+			}
+		}
+		return nil, nil, ErrAuth
+	}
 	if !ok {
 		return nil, nil, ErrUnpack
 	}
@@ -0,0 +1,110 @@
+package dns
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+const tsigTestSecret = "so6ZGir4GPAqINNh9U5c3A=="
+
+func tsigTestMsg(algo string, size uint16) *Msg {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.SetTsig("axfr.", algo, 300, m.Id, time.Now().Unix())
+	m.Extra[0].(*RR_TSIG).MACSize = size
+	return m
+}
+
+func TestTsigAlgorithms(t *testing.T) {
+	for _, algo := range []string{HmacMD5, HmacSHA1, HmacSHA224, HmacSHA256, HmacSHA384, HmacSHA512} {
+		buf, mac, err := TsigGenerate(tsigTestMsg(algo, 0), tsigTestSecret, "", false)
+		if err != nil {
+			t.Fatalf("Failed to sign with %s: %s", algo, err)
+		}
+		if len(mac)/2 != tsigMACSize[algo] {
+			t.Logf("MAC of %s has the wrong size: %d", algo, len(mac)/2)
+			t.Fail()
+		}
+		if err := TsigVerify(buf, tsigTestSecret, "", false); err != nil {
+			t.Logf("Failed to verify %s: %s", algo, err)
+			t.Fail()
+		}
+		buf, _, _ = TsigGenerate(tsigTestMsg(algo, 0), tsigTestSecret, "", false)
+		if err := TsigVerify(buf, "c28gc2VjcmV0", "", false); err != ErrSig {
+			t.Logf("Expected ErrSig for %s with the wrong secret, got %v", algo, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestTsigTruncation(t *testing.T) {
+	tests := []struct {
+		algo string
+		size uint16
+		err  error
+	}{
+		{HmacMD5, 10, nil},
+		{HmacMD5, 9, ErrTrunc},
+		{HmacSHA256, 16, nil},
+		{HmacSHA256, 15, ErrTrunc},
+		{HmacSHA512, 32, nil},
+		{HmacSHA512, 64, nil},
+		{HmacSHA512, 100, nil}, // not truncated
+	}
+	for _, tc := range tests {
+		buf, mac, err := TsigGenerate(tsigTestMsg(tc.algo, tc.size), tsigTestSecret, "", false)
+		if err != tc.err {
+			t.Logf("Expected %v signing %s with a MAC of %d, got %v", tc.err, tc.algo, tc.size, err)
+			t.Fail()
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if size := len(mac) / 2; size != int(tc.size) && size != tsigMACSize[tc.algo] {
+			t.Logf("Expected a MAC of %d for %s, got %d", tc.size, tc.algo, size)
+			t.Fail()
+		}
+		if err := TsigVerify(buf, tsigTestSecret, "", false); err != nil {
+			t.Logf("Failed to verify %s with a MAC of %d: %s", tc.algo, tc.size, err)
+			t.Fail()
+		}
+	}
+}
+
+// shortProvider truncates the HMAC-SHA256 further than allowed.
+type shortProvider struct{}
+
+func (shortProvider) Generate(msg []byte, t *RR_TSIG) ([]byte, error) {
+	rawsecret, _ := packBase64([]byte(tsigTestSecret))
+	h := hmac.New(sha256.New, []byte(rawsecret))
+	h.Write(msg)
+	return h.Sum(nil)[:12], nil
+}
+
+func (shortProvider) Verify(msg []byte, t *RR_TSIG) error { return nil }
+
+func TestTsigProvider(t *testing.T) {
+	buf, _, err := TsigGenerateProvider(tsigTestMsg(HmacSHA256, 0), shortProvider{}, "", false)
+	if err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	if err := TsigVerifyProvider(buf, tsigHMACProvider(tsigTestSecret), "", false); err != ErrTrunc {
+		t.Logf("Expected ErrTrunc, got %v", err)
+		t.Fail()
+	}
+
+	// A BADTRUNC answer
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.Rcode = RcodeNotAuth
+	m.SetTsig("axfr.", HmacSHA256, 300, m.Id, time.Now().Unix())
+	m.Extra[0].(*RR_TSIG).Error = RcodeBadTrunc
+	buf, _ = m.Pack()
+	if err := TsigVerify(buf, tsigTestSecret, "", false); err != ErrTrunc {
+		t.Logf("Expected ErrTrunc for a BADTRUNC answer, got %v", err)
+		t.Fail()
+	}
+}
@@ -1098,17 +1098,21 @@ func (rr *RR_HIP) Len() int {
 
 // TimeToDate translates the RRSIG's incep. and expir. times to the
 // string representation used when printing the record.
-// It takes serial arithmetic (RFC 1982) into account. [TODO]
+// It takes serial arithmetic (RFC 1982) into account: t is taken to be
+// the time within 68 years of now.
 func TimeToDate(t uint32) string {
-	//	utc := time.Now().UTC().Unix()
-	//	mod := (int64(t) - utc) / Year68
-	ti := time.Unix(int64(t), 0).UTC()
+	return timeToDate(t, time.Now().Unix())
+}
+
+func timeToDate(t uint32, now int64) string {
+	ti := time.Unix(serialTime(t, now), 0).UTC()
 	return ti.Format("20060102150405")
 }
 
 // DateToTime translates the RRSIG's incep. and expir. times from 
 // string values like "20110403154150" to an 32 bit integer.
-// It takes serial arithmetic (RFC 1982) into account. [TODO]
+// It takes serial arithmetic (RFC 1982) into account: dates after
+// 2106 wrap around.
 func DateToTime(s string) (uint32, error) {
 	t, e := time.Parse("20060102150405", s)
 	if e != nil {
@@ -87,6 +87,7 @@ func (u *Msg) RRsetUsedRdata(rr []RR) {
 	for i, r := range rr {
 		u.Answer[i] = r
 		u.Answer[i].Header().Class = u.Question[0].Qclass
+		u.Answer[i].Header().Ttl = 0
 	}
 }
 
@@ -95,10 +96,7 @@ func (u *Msg) RRsetUsedRdata(rr []RR) {
 func (u *Msg) RRsetUsedNoRdata(rr []RR) {
 	u.Answer = make([]RR, len(rr))
 	for i, r := range rr {
-		u.Answer[i] = r
-		u.Answer[i].Header().Class = ClassANY
-		u.Answer[i].Header().Ttl = 0
-		u.Answer[i].Header().Rdlength = 0
+		u.Answer[i] = &RR_ANY{Hdr: RR_Header{Name: r.Header().Name, Ttl: 0, Rrtype: r.Header().Rrtype, Class: ClassANY}}
 	}
 }
 
@@ -107,10 +105,7 @@ func (u *Msg) RRsetUsedNoRdata(rr []RR) {
 func (u *Msg) RRsetNotUsed(rr []RR) {
 	u.Answer = make([]RR, len(rr))
 	for i, r := range rr {
-		u.Answer[i] = r
-		u.Answer[i].Header().Class = ClassNONE
-		u.Answer[i].Header().Rdlength = 0
-		u.Answer[i].Header().Ttl = 0
+		u.Answer[i] = &RR_ANY{Hdr: RR_Header{Name: r.Header().Name, Ttl: 0, Rrtype: r.Header().Rrtype, Class: ClassNONE}}
 	}
 }
 
@@ -141,10 +136,7 @@ func (u *Msg) RRsetAddRdata(rr []RR) {
 func (u *Msg) RRsetDelete(rr []RR) {
 	u.Ns = make([]RR, len(rr))
 	for i, r := range rr {
-		u.Ns[i] = r
-		u.Ns[i].Header().Class = ClassANY
-		u.Ns[i].Header().Rdlength = 0
-		u.Ns[i].Header().Ttl = 0
+		u.Ns[i] = &RR_ANY{Hdr: RR_Header{Name: r.Header().Name, Ttl: 0, Rrtype: r.Header().Rrtype, Class: ClassANY}}
 	}
 }
 
@@ -0,0 +1,211 @@
+package dns
+
+// Deciding which keys may update what, like the update-policy
+// statement of BIND.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// How an UpdateRule matches the owner names in an update.
+const (
+	_               = iota
+	PolicyName      // the name equals Name
+	PolicySubdomain // the name is Name or below it
+	PolicyWildcard  // the name matches the wildcard Name, e.g. *.example.org.
+	PolicySelf      // the name equals the name of the key
+	PolicyZonesub   // the name is in the zone, Name is not used
+)
+
+// Map of strings for each of the Policy* values, as used in the text
+// form of a rule.
+var Policy_str = map[int]string{
+	PolicyName:      "name",
+	PolicySubdomain: "subdomain",
+	PolicyWildcard:  "wildcard",
+	PolicySelf:      "self",
+	PolicyZonesub:   "zonesub",
+}
+
+// UpdateRule grants or denies a TSIG key the right to update names and
+// types.
+type UpdateRule struct {
+	Grant    bool     // grant when true, deny otherwise
+	Identity string   // name of the TSIG key, may be a wildcard such as *.example.org.
+	Match    int      // how names are matched, one of the Policy* values
+	Name     string   // the name to match, see Match
+	Types    []uint16 // the types that may be updated, see UpdatePolicy
+}
+
+// UpdatePolicy decides whether a dynamic update is allowed. Each RR in
+// the update section is checked against the rules in order and the first
+// rule that matches the key, the owner name and the type of the RR
+// decides. When no rule matches the RR is denied; updates without a valid
+// TSIG never match a rule.
+//
+// A rule without Types matches all types except SOA, NS, RRSIG, NSEC and
+// NSEC3. Deleting all RRsets of a name (type ANY) also deletes RRsets of
+// those types, so it only matches a rule with TypeANY among its Types.
+type UpdatePolicy struct {
+	Rules []*UpdateRule
+}
+
+// ParseUpdateRule parses a rule in the format of BIND's update-policy
+// statement, without the trailing semicolon:
+//
+//	grant|deny identity ruletype [name] [types]
+//
+// where ruletype is one of name, subdomain, wildcard, self or zonesub. The
+// name is required, except for zonesub where it must be left out.
+func ParseUpdateRule(s string) (*UpdateRule, error) {
+	f := strings.Fields(s)
+	if len(f) < 3 {
+		return nil, &Error{Err: "dns: bad update rule", Name: s}
+	}
+	r := new(UpdateRule)
+	switch strings.ToLower(f[0]) {
+	case "grant":
+		r.Grant = true
+	case "deny":
+	default:
+		return nil, &Error{Err: "dns: bad update rule grant or deny", Name: s}
+	}
+	r.Identity = Fqdn(f[1])
+	for m, str := range Policy_str {
+		if strings.ToLower(f[2]) == str {
+			r.Match = m
+		}
+	}
+	f = f[3:]
+	switch r.Match {
+	case 0:
+		return nil, &Error{Err: "dns: bad update rule type", Name: s}
+	case PolicyZonesub:
+	default:
+		if len(f) == 0 {
+			return nil, &Error{Err: "dns: bad update rule name", Name: s}
+		}
+		r.Name = Fqdn(f[0])
+		f = f[1:]
+	}
+	for _, t := range f {
+		k, ok := Str_rr[strings.ToUpper(t)]
+		if !ok {
+			return nil, &Error{Err: "dns: bad update rule rr type", Name: s}
+		}
+		r.Types = append(r.Types, k)
+	}
+	return r, nil
+}
+
+// String returns the rule in the format accepted by ParseUpdateRule.
+func (r *UpdateRule) String() string {
+	s := "deny"
+	if r.Grant {
+		s = "grant"
+	}
+	s += " " + r.Identity + " " + Policy_str[r.Match]
+	if r.Match != PolicyZonesub {
+		s += " " + r.Name
+	}
+	for _, t := range r.Types {
+		if _, ok := Rr_str[t]; ok {
+			s += " " + Rr_str[t]
+		} else {
+			s += " TYPE" + strconv.Itoa(int(t))
+		}
+	}
+	return s
+}
+
+// Check checks the update u, received on w, against the policy. The key
+// used is the owner name of the TSIG record of u, if the TSIG was verified
+// by the server. It returns RcodeSuccess when all RRs in the update section
+// are allowed, and RcodeRefused otherwise.
+func (p *UpdatePolicy) Check(w ResponseWriter, u *Msg) int {
+	if len(u.Question) != 1 {
+		return RcodeFormatError
+	}
+	key := ""
+	if u.IsTsig() && w.TsigStatus() == nil {
+		key = u.Extra[len(u.Extra)-1].Header().Name
+	}
+	for _, r := range u.Ns {
+		if !p.Allowed(key, u.Question[0].Name, r) {
+			return RcodeRefused
+		}
+	}
+	return RcodeSuccess
+}
+
+// Allowed checks if the key with name key may make the update r, an RR
+// from the update section of an update for zone.
+func (p *UpdatePolicy) Allowed(key, zone string, r RR) bool {
+	if key == "" {
+		return false
+	}
+	key = strings.ToLower(Fqdn(key))
+	zone = strings.ToLower(Fqdn(zone))
+	name := strings.ToLower(r.Header().Name)
+	for _, rule := range p.Rules {
+		if rule.matches(key, zone, name, r.Header().Rrtype) {
+			return rule.Grant
+		}
+	}
+	return false
+}
+
+func (r *UpdateRule) matches(key, zone, name string, t uint16) bool {
+	if !matchWildcard(strings.ToLower(r.Identity), key) {
+		return false
+	}
+	rname := strings.ToLower(r.Name)
+	switch r.Match {
+	case PolicyName:
+		if name != rname {
+			return false
+		}
+	case PolicySubdomain:
+		if !IsSubDomain(rname, name) {
+			return false
+		}
+	case PolicyWildcard:
+		if !matchWildcard(rname, name) {
+			return false
+		}
+	case PolicySelf:
+		if name != key {
+			return false
+		}
+	case PolicyZonesub:
+		if !IsSubDomain(zone, name) {
+			return false
+		}
+	default:
+		return false
+	}
+	if len(r.Types) == 0 {
+		switch t {
+		case TypeSOA, TypeNS, TypeRRSIG, TypeNSEC, TypeNSEC3, TypeANY:
+			return false
+		}
+		return true
+	}
+	for _, t1 := range r.Types {
+		if t1 == t {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard checks if name matches pattern. A pattern starting with
+// the label * matches all names below the rest of the pattern, other
+// patterns only match themselves.
+func matchWildcard(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return name != pattern[2:] && IsSubDomain(pattern[2:], name)
+	}
+	return name == pattern
+}
@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// tsigWriter is a ResponseWriter that only reports a TSIG status.
+type tsigWriter struct {
+	status error
+}
+
+func (w *tsigWriter) RemoteAddr() net.Addr { return nil }
+func (w *tsigWriter) TsigStatus() error    { return w.status }
+func (w *tsigWriter) Write(m *Msg) error   { return nil }
+
+func TestParseUpdateRule(t *testing.T) {
+	for _, s := range []string{
+		"grant host.miek.nl. self host.miek.nl. A AAAA",
+		"deny *.keys.miek.nl. subdomain secure.miek.nl.",
+		"grant admin. zonesub ANY",
+		"grant dhcp. wildcard *.dhcp.miek.nl. A TXT",
+	} {
+		r, err := ParseUpdateRule(s)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", s, err)
+		}
+		if r.String() != s {
+			t.Logf("Expected %q, got %q", s, r.String())
+			t.Fail()
+		}
+	}
+	for _, s := range []string{
+		"grant admin.",
+		"allow admin. zonesub",
+		"grant admin. everything",
+		"grant admin. name",
+		"grant admin. name miek.nl. BOGUS",
+	} {
+		if _, err := ParseUpdateRule(s); err == nil {
+			t.Logf("Expected an error parsing %q", s)
+			t.Fail()
+		}
+	}
+}
+
+func TestUpdatePolicy(t *testing.T) {
+	p := new(UpdatePolicy)
+	for _, s := range []string{
+		"deny admin. name secure.miek.nl.",
+		"grant admin. zonesub",
+		"grant *.hosts.miek.nl. self hosts.miek.nl. A AAAA",
+		"grant dhcp. wildcard *.dhcp.miek.nl. A TXT",
+		"grant ns. subdomain miek.nl. NS",
+	} {
+		r, err := ParseUpdateRule(s)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", s, err)
+		}
+		p.Rules = append(p.Rules, r)
+	}
+	tests := []struct {
+		key     string
+		rr      string
+		allowed bool
+	}{
+		{"", "www.miek.nl. IN A 127.0.0.1", false},
+		{"admin.", "www.miek.nl. IN A 127.0.0.1", true},
+		{"ADMIN.", "WWW.miek.nl. IN A 127.0.0.1", true},
+		{"admin.", "secure.miek.nl. IN A 127.0.0.1", false},
+		{"admin.", "miek.nl. IN NS ns.miek.nl.", false},
+		{"admin.", "www.example.org. IN A 127.0.0.1", false},
+		{"a.hosts.miek.nl.", "a.hosts.miek.nl. IN A 127.0.0.1", true},
+		{"a.hosts.miek.nl.", "a.hosts.miek.nl. IN MX 10 mx.miek.nl.", false},
+		{"a.hosts.miek.nl.", "b.hosts.miek.nl. IN A 127.0.0.1", false},
+		{"dhcp.", "x.dhcp.miek.nl. IN TXT \"x\"", true},
+		{"dhcp.", "dhcp.miek.nl. IN A 127.0.0.1", false},
+		{"ns.", "miek.nl. IN NS ns.miek.nl.", true},
+		{"ns.", "miek.nl. IN SOA ns.miek.nl. miek.miek.nl. 10 14400 3600 604800 86400", false},
+		{"other.", "www.miek.nl. IN A 127.0.0.1", false},
+	}
+	for i, tc := range tests {
+		if a := p.Allowed(tc.key, "miek.nl.", newRR(t, tc.rr)); a != tc.allowed {
+			t.Logf("Test %d: expected %v for %q by %q, got %v", i, tc.allowed, tc.rr, tc.key, a)
+			t.Fail()
+		}
+	}
+
+	// Deleting a name is type ANY
+	u := NewUpdate("miek.nl.", ClassINET)
+	u.NameDelete([]RR{newRR(t, "a.dhcp.miek.nl. IN A 127.0.0.1")})
+	u.SetTsig("dhcp.", HmacMD5, 300, u.Id, time.Now().Unix())
+	if rcode := p.Check(&tsigWriter{}, u); rcode != RcodeRefused {
+		t.Logf("Expected REFUSED deleting a name, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+	u.RRsetAddRdata([]RR{newRR(t, "a.dhcp.miek.nl. IN A 127.0.0.1")})
+	if rcode := p.Check(&tsigWriter{}, u); rcode != RcodeSuccess {
+		t.Logf("Expected NOERROR, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+	if rcode := p.Check(&tsigWriter{ErrSig}, u); rcode != RcodeRefused {
+		t.Logf("Expected REFUSED for a bad TSIG, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+
+	// Deleting a delegation would remove its NS and DS records, a rule
+	// without types does not allow it
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.NameDelete([]RR{newRR(t, "sub.miek.nl. IN NS ns.sub.miek.nl.")})
+	u.SetTsig("admin.", HmacMD5, 300, u.Id, time.Now().Unix())
+	if rcode := p.Check(&tsigWriter{}, u); rcode != RcodeRefused {
+		t.Logf("Expected REFUSED deleting a delegation, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+	r, _ := ParseUpdateRule("grant admin. zonesub ANY")
+	p.Rules = append([]*UpdateRule{r}, p.Rules...)
+	if rcode := p.Check(&tsigWriter{}, u); rcode != RcodeSuccess {
+		t.Logf("Expected NOERROR deleting a name with an ANY rule, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+}
@@ -0,0 +1,492 @@
+package dns
+
+// Validating responses by following the chain of trust from a trust
+// anchor, see RFC 4033 section 5 and RFC 4035 section 5.
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The security status of a response, RFC 4033 section 5.
+const (
+	_             = iota
+	Secure        // the chain of trust from an anchor to the data holds
+	Insecure      // there is proof that the data is in an unsigned zone
+	Bogus         // the data should be signed, but the signatures do not validate
+	Indeterminate // there is no anchor for the data, or the chain could not be fetched
+)
+
+// Map of strings for each security status.
+var Security_str = map[int]string{
+	Secure:        "secure",
+	Insecure:      "insecure",
+	Bogus:         "bogus",
+	Indeterminate: "indeterminate",
+}
+
+// Validator validates responses with DNSSEC. Starting at a trust anchor
+// it fetches the DNSKEY and DS RRsets of the zones down to the signer of
+// the data, and checks each link: a DNSKEY RRset is trusted when it is
+// signed by a key matching a trusted DS (or anchor), a DS RRset when it is
+// signed by a trusted key of the parent. A delegation without DS must be
+// proven with NSEC or NSEC3, everything below it is insecure.
+//
+// The zone keys found are cached until the TTL of their DNSKEY RRset
+// expires, names proven not to be a zone cut until the TTL of the NSEC or
+// NSEC3 records of the proof expires. The cache is only locked while it is
+// read or written, not while the RRsets are fetched.
+type Validator struct {
+	Anchors []RR    // the trust anchors, DS, TA or DNSKEY records
+	Server  string  // address of the resolver used to fetch DNSKEY and DS RRsets, host:port
+	Client  *Client // the client used to query Server, NewClient() when nil
+	// If not nil, Query is used to fetch the DNSKEY and DS RRsets instead of
+	// querying Server. The reply must include the RRSIGs and the NSEC or
+	// NSEC3 records.
+	Query func(name string, t uint16) (*Msg, error)
+	Clock Clock // if nil the system clock is used
+
+	mu    sync.Mutex
+	cache map[string]*zoneKeys
+}
+
+// zoneKeys are the keys of a zone, as far as the validator trusts them.
+type zoneKeys struct {
+	security int          // 0 when the name is not a zone cut
+	keys     []*RR_DNSKEY // the trusted keys when security is Secure
+	expire   time.Time
+}
+
+// Validate validates the response m. It returns the security status and
+// the trail of steps that led to it, the last step explains the status.
+//
+// All RRsets in the answer section must be signed by their zone, an
+// answer synthesized from a wildcard must come with proof that the name
+// itself does not exist. For a response without an answer the NSEC or
+// NSEC3 records in the authority section must prove the denial, see
+// NsecVerify and Nsec3Verify. Unsigned data is only insecure when the
+// chain of trust proves the zone is unsigned.
+func (v *Validator) Validate(m *Msg) (int, []string) {
+	var trail []string
+	if len(m.Question) != 1 {
+		return Indeterminate, append(trail, "no question in the response")
+	}
+	q := m.Question[0]
+
+	wildcard := false
+	rrsets, sigs := sortRRsets(m.Answer)
+	for _, k := range sortedKeys(rrsets) {
+		security, w := v.validateRRset(rrsets[k], sigs[k], &trail)
+		if security != Secure {
+			return security, trail
+		}
+		wildcard = wildcard || w
+	}
+	if len(rrsets) > 0 && !wildcard {
+		return Secure, trail
+	}
+
+	// A denial of existence, or the proof that the wildcard answer is
+	// not for an existing name
+	rrsets, sigs = sortRRsets(m.Ns)
+	nsec, nsec3, ds := false, false, false
+	for _, k := range sortedKeys(rrsets) {
+		switch rrsets[k][0].Header().Rrtype {
+		case TypeNS:
+			// Referral NS RRsets are not signed
+			if len(sigs[k]) == 0 {
+				continue
+			}
+		case TypeDS:
+			ds = true
+		case TypeNSEC:
+			nsec = true
+		case TypeNSEC3:
+			nsec3 = true
+		}
+		security, _ := v.validateRRset(rrsets[k], sigs[k], &trail)
+		if security != Secure {
+			return security, trail
+		}
+	}
+	if ds && !wildcard {
+		return Secure, append(trail, q.Name+" "+typeString(q.Qtype)+": secure referral")
+	}
+	if !nsec && !nsec3 {
+		// No proof, only acceptable when the zone is unsigned
+		zk := v.keys(strings.ToLower(q.Name), &trail)
+		if zk.security == Secure {
+			return Bogus, append(trail, q.Name+" "+typeString(q.Qtype)+": no NSEC or NSEC3 proof")
+		}
+		return zk.security, trail
+	}
+
+	var result int
+	var err error
+	var want []int
+	if nsec3 {
+		result, err = m.Nsec3Verify(q)
+		want = []int{NSEC3_NXDOMAIN, NSEC3_NODATA, NSEC3_NODS}
+		if wildcard {
+			want = []int{NSEC3_WILDCARD}
+		}
+	} else {
+		result, err = m.NsecVerify(q)
+		want = []int{NSEC_NXDOMAIN, NSEC_NODATA, NSEC_NODS}
+		if wildcard {
+			want = []int{NSEC_WILDCARD}
+		}
+	}
+	if err != nil {
+		return Bogus, append(trail, q.Name+" "+typeString(q.Qtype)+": denial of existence: "+err.Error())
+	}
+	if nsec3 && result == NSEC3_OPTOUT {
+		return Insecure, append(trail, q.Name+" "+typeString(q.Qtype)+": in an opt-out span")
+	}
+	for _, r := range want {
+		if r == result {
+			return Secure, append(trail, q.Name+" "+typeString(q.Qtype)+": denial of existence proven")
+		}
+	}
+	return Bogus, append(trail, q.Name+" "+typeString(q.Qtype)+": wrong denial of existence")
+}
+
+// validateRRset validates the RRset with its signatures. It also returns
+// true when the RRset was synthesized from a wildcard.
+func (v *Validator) validateRRset(rrset []RR, sigs []*RR_RRSIG, trail *[]string) (int, bool) {
+	name := strings.ToLower(rrset[0].Header().Name)
+	what := name + " " + typeString(rrset[0].Header().Rrtype)
+	if len(sigs) == 0 {
+		zk := v.keys(name, trail)
+		if zk.security == Secure {
+			*trail = append(*trail, what+": not signed")
+			return Bogus, false
+		}
+		return zk.security, false
+	}
+	signer := strings.ToLower(sigs[0].SignerName)
+	if !IsSubDomain(signer, name) {
+		*trail = append(*trail, what+": signer "+signer+" is not a parent")
+		return Bogus, false
+	}
+	zk := v.keys(signer, trail)
+	if zk.security != Secure {
+		return zk.security, false
+	}
+	sig, err := v.verify(rrset, sigs, zk.keys)
+	if err != nil {
+		*trail = append(*trail, what+": "+err.Error())
+		return Bogus, false
+	}
+	*trail = append(*trail, what+": secure, signed by "+signer+" key "+strconv.Itoa(int(sig.KeyTag)))
+	return Secure, int(sig.Labels) < len(SplitLabels(name))
+}
+
+// verify returns the signature of rrset that validates with one of keys.
+func (v *Validator) verify(rrset []RR, sigs []*RR_RRSIG, keys []*RR_DNSKEY) (*RR_RRSIG, error) {
+	err := ErrSig
+	now := v.clock().Now().Unix()
+	for _, sig := range sigs {
+		if !sig.validityPeriod(now) {
+			err = ErrTime
+			continue
+		}
+		for _, k := range keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err = sig.Verify(k, rrset); err == nil {
+				return sig, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// keys returns the keys of the zone name, or the security status of name
+// when there is no secure zone for it. It follows the chain of trust from
+// the closest trust anchor down to name.
+func (v *Validator) keys(name string, trail *[]string) *zoneKeys {
+	anchor := ""
+	for _, a := range v.Anchors {
+		n := strings.ToLower(a.Header().Name)
+		if IsSubDomain(n, name) && (anchor == "" || CompareLabels(n, name) > CompareLabels(anchor, name)) {
+			anchor = n
+		}
+	}
+	if anchor == "" {
+		*trail = append(*trail, name+": no trust anchor")
+		return &zoneKeys{security: Indeterminate}
+	}
+	zone, zk := anchor, v.cached(anchor, trail)
+	if zk == nil {
+		zk = v.anchorKeys(anchor, trail)
+		v.store(anchor, zk)
+	}
+	labels := SplitLabels(name)
+	for i := len(labels) - len(SplitLabels(anchor)) - 1; i >= 0 && zk.security == Secure; i-- {
+		child := strings.Join(labels[i:], ".") + "."
+		ck := v.cached(child, trail)
+		if ck == nil {
+			ck = v.delegation(zone, zk, child, trail)
+			v.store(child, ck)
+		}
+		if ck.security != 0 {
+			zone, zk = child, ck
+		}
+	}
+	return zk
+}
+
+// cached returns the keys of name from the cache, or nil when they are not
+// cached or expired.
+func (v *Validator) cached(name string, trail *[]string) *zoneKeys {
+	v.mu.Lock()
+	zk, ok := v.cache[name]
+	v.mu.Unlock()
+	if !ok || !v.clock().Now().Before(zk.expire) {
+		return nil
+	}
+	if zk.security == 0 {
+		*trail = append(*trail, name+": not a zone cut, cached")
+	} else {
+		*trail = append(*trail, name+": "+Security_str[zk.security]+", cached")
+	}
+	return zk
+}
+
+func (v *Validator) store(name string, zk *zoneKeys) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cache == nil {
+		v.cache = make(map[string]*zoneKeys)
+	}
+	v.cache[name] = zk
+}
+
+// anchorKeys fetches and checks the DNSKEY RRset of the trust anchor name.
+func (v *Validator) anchorKeys(name string, trail *[]string) *zoneKeys {
+	var ds []*RR_DS
+	var keys []*RR_DNSKEY
+	for _, a := range v.Anchors {
+		if strings.ToLower(a.Header().Name) != name {
+			continue
+		}
+		switch a := a.(type) {
+		case *RR_DS:
+			ds = append(ds, a)
+		case *RR_TA:
+			ds = append(ds, &RR_DS{Hdr: a.Hdr, KeyTag: a.KeyTag, Algorithm: a.Algorithm, DigestType: a.DigestType, Digest: a.Digest})
+		case *RR_DNSKEY:
+			keys = append(keys, a)
+		}
+	}
+	return v.dnskey(name, ds, keys, "the trust anchor", trail)
+}
+
+// dnskey fetches the DNSKEY RRset of the zone name and checks it is
+// signed by a key matching one of ds or equal to one of anchors.
+func (v *Validator) dnskey(name string, ds []*RR_DS, anchors []*RR_DNSKEY, from string, trail *[]string) *zoneKeys {
+	m, err := v.query(name, TypeDNSKEY)
+	if err != nil {
+		*trail = append(*trail, name+" DNSKEY: "+err.Error())
+		return &zoneKeys{security: Indeterminate}
+	}
+	rrsets, sigs := sortRRsets(m.Answer)
+	k := name + " " + typeString(TypeDNSKEY)
+	var keys, trusted []*RR_DNSKEY
+	for _, r := range rrsets[k] {
+		key := r.(*RR_DNSKEY)
+		if key.Flags&ZONE == 0 || key.Flags&REVOKE != 0 {
+			continue
+		}
+		keys = append(keys, key)
+		for _, a := range anchors {
+			if sameKey(key, a) {
+				trusted = append(trusted, key)
+			}
+		}
+		for _, d := range ds {
+			if d.KeyTag != key.KeyTag() || d.Algorithm != key.Algorithm {
+				continue
+			}
+			if kd := key.ToDS(int(d.DigestType)); kd != nil && strings.ToLower(kd.Digest) == strings.ToLower(d.Digest) {
+				trusted = append(trusted, key)
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		*trail = append(*trail, k+": no key matches "+from)
+		return &zoneKeys{security: Bogus}
+	}
+	sig, err := v.verify(rrsets[k], sigs[k], trusted)
+	if err != nil {
+		*trail = append(*trail, k+": "+err.Error())
+		return &zoneKeys{security: Bogus}
+	}
+	*trail = append(*trail, k+": secure, key "+strconv.Itoa(int(sig.KeyTag))+" matches "+from)
+	ttl := time.Duration(rrsets[k][0].Header().Ttl) * time.Second
+	return &zoneKeys{security: Secure, keys: keys, expire: v.clock().Now().Add(ttl)}
+}
+
+// delegation checks if child is a zone below the zone parent, with keys
+// pk. When child is not a zone cut the returned keys have security 0,
+// otherwise they are the keys of the child zone, which are insecure when
+// the delegation is proven to have no DS.
+func (v *Validator) delegation(parent string, pk *zoneKeys, child string, trail *[]string) *zoneKeys {
+	m, err := v.query(child, TypeDS)
+	if err != nil {
+		*trail = append(*trail, child+" DS: "+err.Error())
+		return &zoneKeys{security: Indeterminate}
+	}
+	k := child + " " + typeString(TypeDS)
+	rrsets, sigs := sortRRsets(m.Answer)
+	if ds := rrsets[k]; len(ds) > 0 {
+		if _, err := v.verify(ds, sigs[k], pk.keys); err != nil {
+			*trail = append(*trail, k+": "+err.Error())
+			return &zoneKeys{security: Bogus}
+		}
+		var supported []*RR_DS
+		for _, r := range ds {
+			switch d := r.(*RR_DS); d.DigestType {
+			case SHA1, SHA256, SHA384:
+				supported = append(supported, d)
+			}
+		}
+		if len(supported) == 0 {
+			// RFC 4035 section 5.2, treat as insecure
+			*trail = append(*trail, k+": no DS with a supported digest type")
+			return &zoneKeys{security: Insecure, expire: v.expire(ds)}
+		}
+		*trail = append(*trail, k+": secure, signed by "+parent)
+		return v.dnskey(child, supported, nil, "the DS of "+parent, trail)
+	}
+
+	// No DS, the denial must be signed by the parent
+	rrsets, sigs = sortRRsets(m.Ns)
+	var nsec []*RR_NSEC
+	var nsec3 []*RR_NSEC3
+	var proof []RR
+	for key, rrset := range rrsets {
+		switch rrset[0].Header().Rrtype {
+		case TypeNSEC, TypeNSEC3, TypeSOA:
+		default:
+			continue
+		}
+		if _, err := v.verify(rrset, sigs[key], pk.keys); err != nil {
+			*trail = append(*trail, key+": "+err.Error())
+			return &zoneKeys{security: Bogus}
+		}
+		for _, r := range rrset {
+			switch r := r.(type) {
+			case *RR_NSEC:
+				nsec = append(nsec, r)
+				proof = append(proof, r)
+			case *RR_NSEC3:
+				nsec3 = append(nsec3, r)
+				proof = append(proof, r)
+			}
+		}
+	}
+	m.Answer = nil
+	q := Question{child, TypeDS, ClassINET}
+	switch {
+	case len(nsec) > 0:
+		result, err := m.NsecVerify(q)
+		if err != nil {
+			break
+		}
+		for _, n := range nsec {
+			if strings.ToLower(n.Hdr.Name) == child && result == NSEC_NODATA && bitmapHas(n.TypeBitMap, TypeNS) {
+				*trail = append(*trail, k+": insecure delegation, no DS proven by NSEC")
+				return &zoneKeys{security: Insecure, expire: v.expire(m.Ns)}
+			}
+		}
+		return &zoneKeys{expire: v.expire(proof)}
+	case len(nsec3) > 0:
+		result, err := m.Nsec3Verify(q)
+		if err != nil {
+			break
+		}
+		switch result {
+		case NSEC3_OPTOUT:
+			*trail = append(*trail, k+": insecure delegation, in an opt-out span")
+			return &zoneKeys{security: Insecure, expire: v.expire(m.Ns)}
+		case NSEC3_NODATA:
+			if n := nsec3Match(nsec3, child); n != nil && bitmapHas(n.TypeBitMap, TypeNS) {
+				*trail = append(*trail, k+": insecure delegation, no DS proven by NSEC3")
+				return &zoneKeys{security: Insecure, expire: v.expire(m.Ns)}
+			}
+		}
+		return &zoneKeys{expire: v.expire(proof)}
+	}
+	*trail = append(*trail, k+": no proof that there is no DS")
+	return &zoneKeys{security: Bogus}
+}
+
+// expire returns the time the lowest TTL in rrs expires.
+func (v *Validator) expire(rrs []RR) time.Time {
+	ttl := uint32(0)
+	for i, r := range rrs {
+		if i == 0 || r.Header().Ttl < ttl {
+			ttl = r.Header().Ttl
+		}
+	}
+	return v.clock().Now().Add(time.Duration(ttl) * time.Second)
+}
+
+func (v *Validator) query(name string, t uint16) (*Msg, error) {
+	if v.Query != nil {
+		return v.Query(name, t)
+	}
+	c := v.Client
+	if c == nil {
+		c = NewClient()
+	}
+	m := new(Msg)
+	m.SetQuestion(name, t)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+	return c.Exchange(m, v.Server)
+}
+
+func (v *Validator) clock() Clock {
+	if v.Clock == nil {
+		return systemClock{}
+	}
+	return v.Clock
+}
+
+// sortRRsets sorts the RRs of a section per RRset, keyed by the lowercased
+// owner name and the type. The RRSIGs are sorted under the type they cover.
+func sortRRsets(section []RR) (map[string][]RR, map[string][]*RR_RRSIG) {
+	rrsets := make(map[string][]RR)
+	sigs := make(map[string][]*RR_RRSIG)
+	for _, r := range section {
+		name := strings.ToLower(r.Header().Name)
+		switch r := r.(type) {
+		case *RR_RRSIG:
+			k := name + " " + typeString(r.TypeCovered)
+			sigs[k] = append(sigs[k], r)
+		case *RR_OPT:
+		default:
+			k := name + " " + typeString(r.Header().Rrtype)
+			rrsets[k] = append(rrsets[k], r)
+		}
+	}
+	return rrsets, sigs
+}
+
+// sortedKeys returns the keys of rrsets in sorted order, so validation
+// always takes the same steps.
+func sortedKeys(rrsets map[string][]RR) []string {
+	keys := make([]string, 0, len(rrsets))
+	for k := range rrsets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAuthority answers queries from a set of zones, like a recursive
+// resolver would return them.
+type testAuthority map[string][]RR // origin -> RRs
+
+// zone returns the origin of the zone that answers name. The parent side
+// answers the DS queries.
+func (a testAuthority) zone(name string, t uint16) string {
+	best := ""
+	for origin := range a {
+		if !IsSubDomain(origin, name) || (t == TypeDS && origin == name) {
+			continue
+		}
+		if best == "" || CompareLabels(origin, name) > CompareLabels(best, name) {
+			best = origin
+		}
+	}
+	return best
+}
+
+func (a testAuthority) Query(name string, t uint16) (*Msg, error) {
+	m := new(Msg)
+	m.SetQuestion(name, t)
+	m.Response = true
+	name = strings.ToLower(name)
+	origin := a.zone(name, t)
+	rrs := a[origin]
+	// rrset returns the RRs of name and type t, with their RRSIGs
+	rrset := func(name string, t uint16) (set []RR) {
+		for _, r := range rrs {
+			if r.Header().Name != name {
+				continue
+			}
+			if sig, ok := r.(*RR_RRSIG); r.Header().Rrtype == t || ok && sig.TypeCovered == t {
+				set = append(set, r)
+			}
+		}
+		return
+	}
+	if set := rrset(name, t); len(set) > 0 {
+		m.Answer = set
+		return m, nil
+	}
+	// Wildcard expansion
+	labels := SplitLabels(name)
+	if set := rrset("*."+strings.Join(labels[1:], ".")+".", t); len(set) > 0 {
+		for _, r := range set {
+			r1, _ := NewRR(r.String())
+			r1.Header().Name = name
+			m.Answer = append(m.Answer, r1)
+		}
+	} else {
+		m.Rcode = RcodeNameError
+		for _, r := range rrs {
+			if IsSubDomain(name, r.Header().Name) {
+				m.Rcode = RcodeSuccess
+			}
+		}
+		m.Ns = rrset(origin, TypeSOA)
+	}
+	for _, r := range rrs {
+		switch r.Header().Rrtype {
+		case TypeNSEC, TypeNSEC3:
+			m.Ns = append(m.Ns, rrset(r.Header().Name, r.Header().Rrtype)...)
+		}
+	}
+	return m, nil
+}
+
+func TestValidator(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	nlKey, miekKey := signTestKey(t, ZONE|SEP), signTestKey(t, ZONE|SEP)
+	nlKey.DNSKEY.Hdr.Name = "nl."
+	a := make(testAuthority)
+
+	miek := &ZoneSigner{ZSK: []*SigningKey{miekKey}, NSEC3: &RR_NSEC3PARAM{Hash: SHA1, Iterations: 1, Salt: "-"}, Clock: clock}
+	rrs, err := miek.Sign("miek.nl.", parseZoneString(t, `@ 3600 IN SOA ns hostmaster 1 14400 3600 604800 86400
+@ 3600 IN NS ns
+ns 3600 IN A 127.0.0.1
+www 3600 IN A 127.0.0.2
+*.wild 3600 IN A 127.0.0.3
+`))
+	if err != nil {
+		t.Fatalf("Failed to sign miek.nl.: %s", err)
+	}
+	a["miek.nl."] = rrs
+
+	nl := &ZoneSigner{ZSK: []*SigningKey{nlKey}, Clock: clock}
+	ds := miekKey.DNSKEY.ToDS(SHA256)
+	rrs, err = nl.Sign("nl.", append(parseZoneString(t, `nl. 3600 IN SOA ns.nl. hostmaster.nl. 1 14400 3600 604800 86400
+nl. 3600 IN NS ns.nl.
+ns.nl. 3600 IN A 127.0.0.1
+miek.nl. 3600 IN NS ns.miek.nl.
+insecure.nl. 3600 IN NS ns.insecure.nl.
+`), ds))
+	if err != nil {
+		t.Fatalf("Failed to sign nl.: %s", err)
+	}
+	a["nl."] = rrs
+	a["insecure.nl."] = parseZoneString(t, `insecure.nl. 3600 IN SOA ns.insecure.nl. hostmaster.insecure.nl. 1 14400 3600 604800 86400
+insecure.nl. 3600 IN NS ns.insecure.nl.
+www.insecure.nl. 3600 IN A 127.0.0.4
+`)
+
+	anchor := nlKey.DNSKEY.ToDS(SHA1)
+	v := &Validator{Anchors: []RR{anchor}, Query: a.Query, Clock: clock}
+	tests := []struct {
+		name     string
+		t        uint16
+		security int
+	}{
+		{"www.miek.nl.", TypeA, Secure},
+		{"miek.nl.", TypeDNSKEY, Secure},
+		{"nx.miek.nl.", TypeA, Secure},
+		{"www.miek.nl.", TypeMX, Secure},
+		{"a.wild.miek.nl.", TypeA, Secure},
+		{"ns.nl.", TypeA, Secure},
+		{"nx.nl.", TypeA, Secure},
+		{"www.insecure.nl.", TypeA, Insecure},
+		{"nx.insecure.nl.", TypeA, Insecure},
+		{"www.example.org.", TypeA, Indeterminate},
+	}
+	validate := func(v *Validator, name string, qtype uint16) (int, []string) {
+		m, _ := a.Query(name, qtype)
+		if strings.HasSuffix(name, "example.org.") {
+			m.Answer = []RR{newRR(t, name+" 3600 IN A 127.0.0.1")}
+		}
+		return v.Validate(m)
+	}
+	for _, tc := range tests {
+		if security, trail := validate(v, tc.name, tc.t); security != tc.security {
+			t.Logf("%s %s: expected %s, got %s: %v", tc.name, typeString(tc.t), Security_str[tc.security], Security_str[security], trail)
+			t.Fail()
+		}
+	}
+
+	// A changed answer
+	m, _ := a.Query("www.miek.nl.", TypeA)
+	m.Answer[0] = newRR(t, "www.miek.nl. 3600 IN A 127.0.0.10")
+	if security, trail := v.Validate(m); security != Bogus {
+		t.Logf("Changed answer is %s: %v", Security_str[security], trail)
+		t.Fail()
+	}
+	// A missing denial
+	m, _ = a.Query("nx.miek.nl.", TypeA)
+	m.Ns = m.Ns[:2]
+	if security, trail := v.Validate(m); security != Bogus {
+		t.Logf("Missing denial is %s: %v", Security_str[security], trail)
+		t.Fail()
+	}
+	// A TA anchor for the other key
+	other := signTestKey(t, ZONE|SEP).DNSKEY.ToDS(SHA256)
+	ta := &RR_TA{Hdr: RR_Header{Name: "nl.", Rrtype: TypeTA, Class: ClassINET}, KeyTag: other.KeyTag, Algorithm: other.Algorithm,
+		DigestType: other.DigestType, Digest: other.Digest}
+	if security, trail := validate(&Validator{Anchors: []RR{ta}, Query: a.Query, Clock: clock}, "www.miek.nl.", TypeA); security != Bogus {
+		t.Logf("Wrong anchor is %s: %v", Security_str[security], trail)
+		t.Fail()
+	}
+	// A DNSKEY anchor
+	if security, trail := validate(&Validator{Anchors: []RR{nlKey.DNSKEY}, Query: a.Query, Clock: clock}, "www.miek.nl.", TypeA); security != Secure {
+		t.Logf("DNSKEY anchor is %s: %v", Security_str[security], trail)
+		t.Fail()
+	}
+	// The second time the chain comes from the cache, including the proof
+	// that www.nl. is not a zone cut
+	queries := 0
+	cv := &Validator{Anchors: []RR{anchor}, Clock: clock, Query: func(name string, t uint16) (*Msg, error) {
+		queries++
+		return a.Query(name, t)
+	}}
+	for i := 0; i < 2; i++ {
+		queries = 0
+		m := new(Msg)
+		m.SetQuestion("www.nl.", TypeA)
+		m.Answer = []RR{newRR(t, "www.nl. 3600 IN A 127.0.0.1")}
+		security, trail := cv.Validate(m)
+		if security != Bogus {
+			t.Logf("Unsigned answer in nl. is %s: %v", Security_str[security], trail)
+			t.Fail()
+		}
+		if i == 0 && queries != 2 || i == 1 && (queries != 0 || !strings.Contains(strings.Join(trail, "\n"), "www.nl.: not a zone cut, cached")) {
+			t.Logf("Validation %d made %d queries: %v", i, queries, trail)
+			t.Fail()
+		}
+	}
+	// Expired signatures
+	clock.Advance(31 * 24 * time.Hour)
+	if security, trail := validate(v, "www.miek.nl.", TypeA); security != Bogus {
+		t.Logf("Expired signatures are %s: %v", Security_str[security], trail)
+		t.Fail()
+	}
+}
@@ -1,10 +1,154 @@
 package dns
 
+import (
+	"time"
+)
+
+// Envelope holds the RRs of one message of a zone transfer, or the error
+// that ended the transfer.
+type Envelope struct {
+	RR    []RR
+	Error error
+}
+
+// XfrIn requests an incoming Ixfr or Axfr, depending on the type in
+// the question section of q. The envelopes are sent on the returned channel,
+// which is closed when the transfer ends. If the transfer fails the
+// last envelope has Error set. When q is signed with TSIG each envelope
+// must be signed and its TSIG must validate.
+// An Ixfr may be answered in the Axfr format, this is handled
+// transparently: the RRs in the envelopes then contain the whole zone.
+// If an Ixfr is answered with a single envelope holding only the SOA the
+// zone is up to date.
+// A receiver that stops reading before the channel is closed must close
+// quit, this ends the transfer and closes the connection. The channel is
+// then closed without a last envelope. When quit is nil the channel must be
+// read until it is closed.
+func (c *Client) XfrIn(q *Msg, a string, quit chan bool) (chan *Envelope, error) {
+	switch q.Question[0].Qtype {
+	case TypeAXFR, TypeIXFR:
+	default:
+		return nil, ErrXfrType
+	}
+	w := new(reply)
+	w.client = c
+	w.addr = a
+	w.req = q
+	if err := w.Dial(); err != nil {
+		return nil, err
+	}
+	if err := w.Send(q); err != nil {
+		w.Close()
+		return nil, err
+	}
+	ch := make(chan *Envelope)
+	go w.xfrIn(ch, quit)
+	return ch, nil
+}
+
+func (w *reply) xfrIn(c chan *Envelope, quit chan bool) {
+	defer close(c)
+	finished := make(chan bool)
+	defer close(finished)
+	// Closing the connection also stops a Receive that is waiting
+	go func() {
+		select {
+		case <-quit:
+		case <-finished:
+		}
+		w.Close()
+	}()
+	// send returns false when the receiver has gone away
+	send := func(e *Envelope) bool {
+		select {
+		case c <- e:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+	x := &xfrState{ixfr: w.req.Question[0].Qtype == TypeIXFR}
+	for {
+		in, err := w.Receive()
+		if err != nil {
+			send(&Envelope{nil, err})
+			return
+		}
+		if w.req.Id != in.Id {
+			send(&Envelope{in.Answer, ErrId})
+			return
+		}
+		if in.Rcode != RcodeSuccess {
+			send(&Envelope{in.Answer, &Error{Err: "dns: bad rcode: " + Rcode_str[in.Rcode], Name: w.req.Question[0].Name}})
+			return
+		}
+		if w.req.IsTsig() {
+			if !in.IsTsig() {
+				send(&Envelope{in.Answer, ErrNoSig})
+				return
+			}
+			if err := w.TsigStatus(); err != nil {
+				send(&Envelope{in.Answer, err})
+				return
+			}
+			w.tsigTimersOnly = true // Subsequent envelopes use this.
+		}
+		done, err := x.next(in.Answer)
+		if !send(&Envelope{in.Answer, err}) || done || err != nil {
+			return
+		}
+	}
+}
+
+// xfrState keeps track of the RRs seen in a transfer to find its end.
+type xfrState struct {
+	ixfr        bool
+	serial      uint32 // serial of the first SOA, the servers' current serial
+	n           int    // number of RRs seen
+	soa         int    // number of SOA RRs seen after the first
+	incremental bool   // the answer is in the Ixfr format
+}
+
+// next processes the RRs of an envelope, it returns true when the
+// transfer is complete.
+func (x *xfrState) next(rrs []RR) (bool, error) {
+	for _, r := range rrs {
+		x.n++
+		soa, ok := r.(*RR_SOA)
+		if x.n == 1 {
+			if !ok {
+				return false, ErrXfrSoa
+			}
+			x.serial = soa.Serial
+			continue
+		}
+		if x.n == 2 {
+			// In the Ixfr format the second RR is the SOA of the old version
+			x.incremental = x.ixfr && ok
+		}
+		if !ok {
+			continue
+		}
+		x.soa++
+		if !x.incremental {
+			return true, nil
+		}
+		// The SOAs alternate between old and new versions, the current
+		// serial in the place of an old version ends the transfer.
+		if x.soa%2 == 1 && soa.Serial == x.serial {
+			return true, nil
+		}
+	}
+	// Only the SOA: up to date
+	return x.ixfr && x.n == 1, nil
+}
+
 // XfrReceives requests an incoming Ixfr or Axfr. If the message q's question
 // section contains an AXFR type an Axfr is performed, if it is IXFR it does an Ixfr.
 // Each message will be send along the Client's reply channel as it is received. 
 // The last message send has Exchange.Error set to ErrXfrLast
-// to signal there is nothing more to come.
+// to signal there is nothing more to come. See XfrIn for an
+// API that does not use the Client's reply channel.
 func (c *Client) XfrReceive(q *Msg, a string) error {
 	w := new(reply)
 	w.client = c
@@ -110,68 +254,163 @@ func (w *reply) ixfrReceive() {
 	return
 }
 
-// XfrSend performs an outgoing Ixfr or Axfr. The function is xfr agnostic, it is
-// up to the caller to correctly send the sequence of messages.
-func XfrSend(w ResponseWriter, q *Msg, a string) error {
+// The size of the envelopes sent by XfrSend. This is well below
+// MaxMsgSize, leaving room for the TSIG and for RRs whose length is
+// underestimated.
+const xfrEnvelopeSize = 16384
+
+// XfrSend performs an outgoing Axfr in response to the request q. The RRs
+// of the zone are read from the channel c, the first one must be the SOA
+// record. The RRs are sent in envelopes of at most xfrEnvelopeSize bytes
+// and the SOA is repeated at the end. If q is an Ixfr request, the zone is
+// sent as Axfr, which is allowed by RFC 1995.
+// When q is signed with TSIG every envelope is signed, the ones after the
+// first with timersOnly set when w is a TsigWriter. If the TSIG of q did not validate, the
+// status is returned and nothing is sent. When an error is returned the
+// rest of c is read and discarded.
+func XfrSend(w ResponseWriter, q *Msg, c chan RR) (err error) {
+	defer func() {
+		if err != nil {
+			go func() {
+				for _ = range c {
+				}
+			}()
+		}
+	}()
 	switch q.Question[0].Qtype {
 	case TypeAXFR, TypeIXFR:
-		//		go d.xfrWrite(q, m, e)
 	default:
 		return ErrXfrType
 	}
-	return nil
-}
-
-/*
-// Just send the zone
-func (d *Conn) axfrWrite(q *Msg, m chan *Xfr, e chan os.Error) {
-	out := new(Msg)
-	out.Id = q.Id
-	out.Question = q.Question
-	out.Answer = make([]RR, 1001) // TODO(mg) look at this number
-	out.MsgHdr.Response = true
-	out.MsgHdr.Authoritative = true
-        first := true
-	var soa *RR_SOA
-	i := 0
-	for r := range m {
-		out.Answer[i] = r.RR
+	if q.IsTsig() && w.TsigStatus() != nil {
+		return w.TsigStatus()
+	}
+	x := newXfrWriter(w, q)
+	var soa RR
+	for r := range c {
 		if soa == nil {
-			if r.RR.Header().Rrtype != TypeSOA {
-				e <- ErrXfrSoa
-                                return
-			} else {
-				soa = r.RR.(*RR_SOA)
+			if r.Header().Rrtype != TypeSOA {
+				return ErrXfrSoa
 			}
+			soa = r
 		}
-		i++
-		if i > 1000 {
-			// Send it
-			err := d.WriteMsg(out)
-			if err != nil {
-				e <- err
-                                return
+		if err := x.add(r); err != nil {
+			return err
+		}
+	}
+	if soa == nil {
+		return ErrXfrSoa
+	}
+	if err := x.add(soa); err != nil {
+		return err
+	}
+	return x.flush()
+}
+
+// XfrSendZone performs an outgoing Axfr or Ixfr of zone in response to the
+// request q. An Ixfr is answered from the journal j, which may be nil.
+// When the serial of the client is not in the journal, it falls back to
+// sending the whole zone. The zone must contain one SOA record. See XfrSend
+// for the details on TSIG.
+func XfrSendZone(w ResponseWriter, q *Msg, zone []RR, j *Journal) error {
+	switch q.Question[0].Qtype {
+	case TypeAXFR, TypeIXFR:
+	default:
+		return ErrXfrType
+	}
+	if q.IsTsig() && w.TsigStatus() != nil {
+		return w.TsigStatus()
+	}
+	var soa RR
+	for _, r := range zone {
+		if r.Header().Rrtype == TypeSOA {
+			soa = r
+			break
+		}
+	}
+	if soa == nil {
+		return ErrXfrSoa
+	}
+	x := newXfrWriter(w, q)
+	if q.Question[0].Qtype == TypeIXFR && j != nil && len(q.Ns) > 0 {
+		if s, ok := q.Ns[0].(*RR_SOA); ok {
+			if rrs, err := j.Ixfr(s.Serial); err == nil {
+				for _, r := range rrs {
+					if err := x.add(r); err != nil {
+						return err
+					}
+				}
+				return x.flush()
 			}
-			i = 0
-			// Gaat dit goed?
-			out.Answer = out.Answer[:0]
-                        if first {
-                                if d.Tsig != nil {
-                                        d.Tsig.TimersOnly = true
-                                }
-                                first = !first
-                        }
-		}
-	}
-	// Everything is sent, only the closing soa is left.
-	out.Answer[i] = soa
-	out.Answer = out.Answer[:i+1]
-	err := d.WriteMsg(out)
-	if err != nil {
-		e <- err
-	}
-}
-*/
+		}
+	}
+	if err := x.add(soa); err != nil {
+		return err
+	}
+	for _, r := range zone {
+		if r == soa {
+			continue
+		}
+		if err := x.add(r); err != nil {
+			return err
+		}
+	}
+	if err := x.add(soa); err != nil {
+		return err
+	}
+	return x.flush()
+}
+
+// xfrWriter batches RRs into envelopes and sends them.
+type xfrWriter struct {
+	w    ResponseWriter
+	q    *Msg
+	out  *Msg
+	size int // size of out
+}
+
+func newXfrWriter(w ResponseWriter, q *Msg) *xfrWriter {
+	x := &xfrWriter{w: w, q: q}
+	x.reset()
+	return x
+}
+
+func (x *xfrWriter) reset() {
+	x.out = new(Msg)
+	x.out.SetReply(x.q)
+	x.size = x.out.Len()
+}
+
+// add adds r to the current envelope, sending it first when r does not fit.
+func (x *xfrWriter) add(r RR) error {
+	if len(x.out.Answer) > 0 && x.size+r.Len() > xfrEnvelopeSize {
+		if err := x.flush(); err != nil {
+			return err
+		}
+	}
+	x.out.Answer = append(x.out.Answer, r)
+	x.size += r.Len()
+	return nil
+}
+
+// flush sends the current envelope, if it is not empty.
+func (x *xfrWriter) flush() error {
+	if len(x.out.Answer) == 0 {
+		return nil
+	}
+	if x.q.IsTsig() {
+		t := x.q.Extra[len(x.q.Extra)-1].(*RR_TSIG)
+		x.out.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, x.out.Id, time.Now().Unix())
+	}
+	if err := x.w.Write(x.out); err != nil {
+		return err
+	}
+	if w, ok := x.w.(TsigWriter); ok {
+		w.TsigTimersOnly(true) // Subsequent envelopes use this.
+	}
+	x.reset()
+	return nil
+}
 
 // Check if he SOA record exists in the Answer section of 
 // the packet. If first is true the first RR must be a SOA
@@ -0,0 +1,196 @@
+package dns
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func xfrTestZone(serial uint32, n int) []RR {
+	zone := []RR{&RR_SOA{Hdr: RR_Header{"miek.nl.", TypeSOA, ClassINET, 3600, 0}, Ns: "ns.miek.nl.", Mbox: "miek.miek.nl.",
+		Serial: serial, Refresh: 14400, Retry: 3600, Expire: 604800, Minttl: 86400}}
+	for i := 0; i < n; i++ {
+		zone = append(zone, &RR_A{Hdr: RR_Header{"a" + strconv.Itoa(i) + ".miek.nl.", TypeA, ClassINET, 3600, 0}, A: net.IPv4(127, 0, 0, byte(i)).To4()})
+	}
+	return zone
+}
+
+// xfrTest performs the transfer q against a and returns the RRs received.
+func xfrTest(t *testing.T, q *Msg, a string) (rrs []RR) {
+	c := NewClient()
+	c.Net = "tcp"
+	c.TsigSecret = map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}
+	w := &reply{client: c, addr: a, req: q}
+	if err := w.Dial(); err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer w.Close()
+	if err := w.Send(q); err != nil {
+		t.Fatalf("Failed to send: %s", err)
+	}
+	envelopes := 0
+	for {
+		in, err := w.Receive()
+		if err != nil {
+			t.Fatalf("Failed to receive: %s", err)
+		}
+		if w.TsigStatus() != nil {
+			t.Fatalf("TSIG of envelope %d did not validate: %s", envelopes, w.TsigStatus())
+		}
+		envelopes++
+		w.tsigTimersOnly = true
+		rrs = append(rrs, in.Answer...)
+		if len(rrs) > 1 && rrs[len(rrs)-1].Header().Rrtype == TypeSOA && rrs[len(rrs)-1].String() == rrs[0].String() {
+			break
+		}
+		if len(rrs) == 1 && q.Question[0].Qtype == TypeIXFR {
+			break
+		}
+	}
+	t.Logf("Received %d RRs in %d envelopes", len(rrs), envelopes)
+	return
+}
+
+func TestXfrSend(t *testing.T) {
+	old := xfrTestZone(1, 1000)
+	zone := xfrTestZone(2, 1001)
+	d, _ := Diff(old, zone)
+	j := new(Journal)
+	j.Add(d)
+
+	mux := NewServeMux()
+	mux.HandleFunc("miek.nl.", func(w ResponseWriter, r *Msg) {
+		if err := XfrSendZone(w, r, zone, j); err != nil {
+			t.Logf("Failed to send zone: %s", err)
+		}
+	})
+	srv := &Server{Addr: "127.0.0.1:8054", Net: "tcp", Handler: mux,
+		TsigSecret: map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}}
+	go srv.ListenAndServe()
+	time.Sleep(2e8)
+
+	m := new(Msg)
+	m.SetAxfr("miek.nl.")
+	m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+	if rrs := xfrTest(t, m, "127.0.0.1:8054"); len(rrs) != len(zone)+1 {
+		t.Fatalf("Expected %d RRs in the AXFR, got %d", len(zone)+1, len(rrs))
+	}
+
+	m = new(Msg)
+	m.SetIxfr("miek.nl.", 1)
+	m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+	// SOA, old SOA, new SOA, added A, SOA
+	if rrs := xfrTest(t, m, "127.0.0.1:8054"); len(rrs) != 5 {
+		t.Fatalf("Expected 5 RRs in the IXFR, got %d", len(rrs))
+	}
+
+	m = new(Msg)
+	m.SetIxfr("miek.nl.", 2)
+	m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+	if rrs := xfrTest(t, m, "127.0.0.1:8054"); len(rrs) != 1 {
+		t.Fatalf("Expected only the SOA for an up to date IXFR, got %d", len(rrs))
+	}
+
+	// Unknown serial falls back to AXFR
+	m = new(Msg)
+	m.SetIxfr("miek.nl.", 100)
+	m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+	if rrs := xfrTest(t, m, "127.0.0.1:8054"); len(rrs) != len(zone)+1 {
+		t.Fatalf("Expected %d RRs in the AXFR fallback, got %d", len(zone)+1, len(rrs))
+	}
+}
+
+func TestXfrIn(t *testing.T) {
+	zone1 := xfrTestZone(1, 10)
+	zone2 := xfrTestZone(2, 1010)
+	zone3 := xfrTestZone(3, 1005)
+	j := new(Journal)
+	d, _ := Diff(zone1, zone2)
+	j.Add(d)
+	d, _ = Diff(zone2, zone3)
+	j.Add(d)
+
+	mux := NewServeMux()
+	mux.HandleFunc("miek.nl.", func(w ResponseWriter, r *Msg) {
+		XfrSendZone(w, r, zone3, j)
+	})
+	srv := &Server{Addr: "127.0.0.1:8056", Net: "tcp", Handler: mux,
+		TsigSecret: map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}}
+	go srv.ListenAndServe()
+	time.Sleep(2e8)
+
+	c := NewClient()
+	c.Net = "tcp"
+	c.TsigSecret = map[string]string{"axfr.": "so6ZGir4GPAqINNh9U5c3A=="}
+	xfr := func(m *Msg) (n int, err error) {
+		m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+		ch, err := c.XfrIn(m, "127.0.0.1:8056", nil)
+		if err != nil {
+			return 0, err
+		}
+		for e := range ch {
+			if e.Error != nil {
+				return n, e.Error
+			}
+			n += len(e.RR)
+		}
+		return n, nil
+	}
+
+	tests := []struct {
+		serial uint32 // 0 is AXFR
+		n      int
+	}{
+		{0, len(zone3) + 1},
+		{1, 1 + (2 + 1000) + (2 + 5) + 1}, // SOA, first diff, second diff, SOA
+		{2, 1 + (2 + 5) + 1},
+		{3, 1},
+		{100, len(zone3) + 1}, // AXFR fallback
+	}
+	for _, tc := range tests {
+		m := new(Msg)
+		if tc.serial == 0 {
+			m.SetAxfr("miek.nl.")
+		} else {
+			m.SetIxfr("miek.nl.", tc.serial)
+		}
+		n, err := xfr(m)
+		if err != nil {
+			t.Fatalf("Transfer from serial %d failed: %s", tc.serial, err)
+		}
+		if n != tc.n {
+			t.Fatalf("Expected %d RRs for the transfer from serial %d, got %d", tc.n, tc.serial, n)
+		}
+	}
+
+	m := new(Msg)
+	m.SetAxfr("example.org.")
+	if _, err := xfr(m); err == nil {
+		t.Fatal("Expected an error for a refused transfer")
+	}
+
+	// A receiver that stops early
+	m = new(Msg)
+	m.SetAxfr("miek.nl.")
+	m.SetTsig("axfr.", HmacMD5, 300, m.MsgHdr.Id, time.Now().Unix())
+	quit := make(chan bool)
+	ch, err := c.XfrIn(m, "127.0.0.1:8056", quit)
+	if err != nil {
+		t.Fatalf("Failed to start the transfer: %s", err)
+	}
+	<-ch
+	close(quit)
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("Transfer not stopped after quit was closed")
+		}
+		break
+	}
+}
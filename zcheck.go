@@ -0,0 +1,286 @@
+package dns
+
+// Check the contents of a zone, like named-checkzone does.
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problems found by CheckZone.
+const (
+	_                  = iota
+	CheckOutOfZone     // data outside of the zone
+	CheckNoSoa         // no SOA at the apex
+	CheckMultipleSoa   // more than one SOA, or a SOA not at the apex
+	CheckNoNs          // no NS records at the apex
+	CheckCnameAndOther // CNAME and other data, or more than one CNAME
+	CheckNoGlue        // NS target inside the zone without address records
+	CheckTtlMismatch   // RRs in an RRset with different TTLs
+	CheckCnameTarget   // MX or NS pointing to a CNAME
+	CheckDnssec        // DNSSEC inconsistencies
+)
+
+// Map of strings for each of the CheckZone problems.
+var Check_str = map[int]string{
+	CheckOutOfZone:     "OUTOFZONE",
+	CheckNoSoa:         "NOSOA",
+	CheckMultipleSoa:   "MULTIPLESOA",
+	CheckNoNs:          "NONS",
+	CheckCnameAndOther: "CNAMEANDOTHER",
+	CheckNoGlue:        "NOGLUE",
+	CheckTtlMismatch:   "TTLMISMATCH",
+	CheckCnameTarget:   "CNAMETARGET",
+	CheckDnssec:        "DNSSEC",
+}
+
+// CheckError describes a problem found by CheckZone.
+type CheckError struct {
+	Name string // owner name of the RRs with the problem
+	Type uint16 // type of these RRs, 0 if it's not about a specific type
+	Code int    // what kind of problem, one of the Check* values
+	Err  string // a description of the problem
+}
+
+func (e *CheckError) Error() string {
+	s := e.Name
+	if e.Type != 0 {
+		if _, ok := Rr_str[e.Type]; ok {
+			s += " " + Rr_str[e.Type]
+		} else {
+			s += " TYPE" + strconv.Itoa(int(e.Type))
+		}
+	}
+	return s + ": dns: " + e.Err
+}
+
+// CheckZone checks the RRs of the zone with apex origin for semantic
+// problems. It checks for:
+//
+// * RRs outside of the zone;
+// * a missing SOA or more than one SOA, a missing NS RRset at the apex;
+// * CNAMEs that coexist with other data;
+// * NS records pointing to names inside the zone that have no glue;
+// * RRsets in which the TTLs differ;
+// * MX and NS records pointing to a CNAME;
+// * DNSSEC consistency: when the apex has a DNSKEY all authoritative RRsets
+//   must be signed with one of those keys, within the validity period, and
+//   the NSEC type bitmaps must match the types present.
+//
+// All problems found are returned: first the out of zone data, then the
+// problems in the zone in canonical order of the owner names. The
+// signatures themselves are not verified.
+func CheckZone(origin string, rrs []RR) []*CheckError {
+	origin = strings.ToLower(Fqdn(origin))
+	var errs []*CheckError
+	zone := make(map[string]map[uint16][]RR)
+	for _, r := range rrs {
+		name := strings.ToLower(r.Header().Name)
+		if !IsSubDomain(origin, name) {
+			errs = append(errs, &CheckError{r.Header().Name, r.Header().Rrtype, CheckOutOfZone, "out of zone data"})
+			continue
+		}
+		if _, ok := zone[name]; !ok {
+			zone[name] = make(map[uint16][]RR)
+		}
+		zone[name][r.Header().Rrtype] = append(zone[name][r.Header().Rrtype], r)
+	}
+	names := make([]string, 0, len(zone))
+	for name := range zone {
+		names = append(names, name)
+	}
+	sort.Sort(nameSlice(names))
+
+	apex := zone[origin]
+	switch len(apex[TypeSOA]) {
+	case 0:
+		errs = append(errs, &CheckError{origin, TypeSOA, CheckNoSoa, "no SOA at the zone apex"})
+	case 1:
+	default:
+		errs = append(errs, &CheckError{origin, TypeSOA, CheckMultipleSoa, "more than one SOA at the zone apex"})
+	}
+	if len(apex[TypeNS]) == 0 {
+		errs = append(errs, &CheckError{origin, TypeNS, CheckNoNs, "no NS records at the zone apex"})
+	}
+	var keys []*RR_DNSKEY
+	for _, r := range apex[TypeDNSKEY] {
+		keys = append(keys, r.(*RR_DNSKEY))
+	}
+
+	for _, name := range names {
+		types := zone[name]
+		cut := delegation(zone, origin, name)
+		if name != origin && len(types[TypeSOA]) > 0 {
+			errs = append(errs, &CheckError{name, TypeSOA, CheckMultipleSoa, "SOA not at the zone apex"})
+		}
+		if cnames := types[TypeCNAME]; len(cnames) > 0 {
+			if len(cnames) > 1 {
+				errs = append(errs, &CheckError{name, TypeCNAME, CheckCnameAndOther, "more than one CNAME"})
+			}
+			for _, t := range sortedTypes(types) {
+				switch t {
+				case TypeCNAME, TypeRRSIG, TypeNSEC:
+				default:
+					errs = append(errs, &CheckError{name, t, CheckCnameAndOther, "CNAME and other data"})
+				}
+			}
+		}
+		for _, r := range types[TypeNS] {
+			target := strings.ToLower(r.(*RR_NS).Ns)
+			if !IsSubDomain(origin, target) {
+				continue
+			}
+			if _, ok := zone[target][TypeCNAME]; ok {
+				errs = append(errs, &CheckError{name, TypeNS, CheckCnameTarget, "NS target " + target + " is a CNAME"})
+				continue
+			}
+			if len(zone[target][TypeA]) == 0 && len(zone[target][TypeAAAA]) == 0 {
+				errs = append(errs, &CheckError{name, TypeNS, CheckNoGlue, "no address records (glue) for NS target " + target})
+			}
+		}
+		for _, r := range types[TypeMX] {
+			target := strings.ToLower(r.(*RR_MX).Mx)
+			if _, ok := zone[target][TypeCNAME]; ok {
+				errs = append(errs, &CheckError{name, TypeMX, CheckCnameTarget, "MX target " + target + " is a CNAME"})
+			}
+		}
+		for _, t := range sortedTypes(types) {
+			if t == TypeRRSIG {
+				// The TTLs of the signatures follow the RRset they cover
+				covered := make(map[uint16][]RR)
+				for _, r := range types[t] {
+					covered[r.(*RR_RRSIG).TypeCovered] = append(covered[r.(*RR_RRSIG).TypeCovered], r)
+				}
+				for _, c := range sortedTypes(covered) {
+					if !sameTtl(covered[c]) {
+						errs = append(errs, &CheckError{name, t, CheckTtlMismatch, "TTLs differ in RRSIGs covering " + typeString(c)})
+					}
+				}
+				continue
+			}
+			if !sameTtl(types[t]) {
+				errs = append(errs, &CheckError{name, t, CheckTtlMismatch, "TTLs differ in RRset"})
+			}
+		}
+		if len(keys) > 0 || len(types[TypeRRSIG]) > 0 {
+			errs = append(errs, checkDnssec(origin, name, types, keys, cut)...)
+		}
+	}
+	return errs
+}
+
+// checkDnssec checks the DNSSEC consistency of the RRs at name. The
+// string cut is the delegation point name falls under, if any.
+func checkDnssec(origin, name string, types map[uint16][]RR, keys []*RR_DNSKEY, cut string) (errs []*CheckError) {
+	signed := make(map[uint16]bool)
+	for _, r := range types[TypeRRSIG] {
+		sig := r.(*RR_RRSIG)
+		signed[sig.TypeCovered] = true
+		if _, ok := types[sig.TypeCovered]; !ok {
+			errs = append(errs, &CheckError{name, TypeRRSIG, CheckDnssec, "RRSIG covers " + typeString(sig.TypeCovered) + ", but there is no such RRset"})
+		}
+		if strings.ToLower(sig.SignerName) != origin {
+			errs = append(errs, &CheckError{name, TypeRRSIG, CheckDnssec, "RRSIG signer name " + sig.SignerName + " is not the zone apex"})
+		}
+		known := false
+		for _, k := range keys {
+			if k.KeyTag() == sig.KeyTag && k.Algorithm == sig.Algorithm {
+				known = true
+				break
+			}
+		}
+		if !known {
+			errs = append(errs, &CheckError{name, TypeRRSIG, CheckDnssec, "RRSIG covering " + typeString(sig.TypeCovered) + " made with a key not in the apex DNSKEY RRset, keytag " + strconv.Itoa(int(sig.KeyTag))})
+		}
+		if !sig.ValidityPeriod() {
+			errs = append(errs, &CheckError{name, TypeRRSIG, CheckDnssec, "RRSIG covering " + typeString(sig.TypeCovered) + " is not inside its validity period"})
+		}
+	}
+	if len(keys) > 0 {
+		for _, t := range sortedTypes(types) {
+			if t == TypeRRSIG || signed[t] {
+				continue
+			}
+			// At a delegation only the DS and NSEC are authoritative, below it
+			// nothing is.
+			if cut != "" && (cut != name || (t != TypeDS && t != TypeNSEC)) {
+				continue
+			}
+			errs = append(errs, &CheckError{name, t, CheckDnssec, "RRset is not signed"})
+		}
+	}
+	// At a delegation the glue is not in the bitmap, RFC 4034 section 4.1.2
+	present := make(map[uint16]bool)
+	for t := range types {
+		if cut != name || t == TypeNS || t == TypeDS || t == TypeNSEC || t == TypeRRSIG {
+			present[t] = true
+		}
+	}
+	for _, r := range types[TypeNSEC] {
+		bitmap := make(map[uint16]bool)
+		for _, t := range r.(*RR_NSEC).TypeBitMap {
+			bitmap[t] = true
+		}
+		match := len(bitmap) == len(present)
+		for t := range present {
+			match = match && bitmap[t]
+		}
+		if !match {
+			errs = append(errs, &CheckError{name, TypeNSEC, CheckDnssec, "NSEC type bitmap does not match the types present"})
+		}
+	}
+	return
+}
+
+// delegation returns the delegation point name is at or under, or the
+// empty string when name is authoritative data.
+func delegation(zone map[string]map[uint16][]RR, origin, name string) string {
+	labels := SplitLabels(name)
+	for i := len(labels) - CompareLabels(name, origin) - 1; i >= 0; i-- {
+		parent := strings.Join(labels[i:], ".") + "."
+		if _, ok := zone[parent][TypeNS]; ok {
+			return parent
+		}
+	}
+	return ""
+}
+
+// sameTtl checks if all the RRs in rrset have the same TTL.
+func sameTtl(rrset []RR) bool {
+	for _, r := range rrset {
+		if r.Header().Ttl != rrset[0].Header().Ttl {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedTypes returns the types in the map in numerical order.
+func sortedTypes(types map[uint16][]RR) []uint16 {
+	t := make([]int, 0, len(types))
+	for k := range types {
+		t = append(t, int(k))
+	}
+	sort.Ints(t)
+	t1 := make([]uint16, len(t))
+	for i, k := range t {
+		t1[i] = uint16(k)
+	}
+	return t1
+}
+
+// typeString returns the text representation of the type t.
+func typeString(t uint16) string {
+	if _, ok := Rr_str[t]; ok {
+		return Rr_str[t]
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// nameSlice sorts domain names in canonical order.
+type nameSlice []string
+
+func (p nameSlice) Len() int           { return len(p) }
+func (p nameSlice) Less(i, j int) bool { return compareNames(p[i], p[j]) < 0 }
+func (p nameSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
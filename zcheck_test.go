@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkZoneString(t *testing.T, origin, zone string) map[int]int {
+	var rrs []RR
+	for x := range ParseZone(strings.NewReader(zone), origin, "") {
+		if x.Error != nil {
+			t.Fatalf("Failed to parse the zone: %s", x.Error)
+		}
+		rrs = append(rrs, x.RR)
+	}
+	codes := make(map[int]int)
+	for _, e := range CheckZone(origin, rrs) {
+		t.Logf("%s %s", Check_str[e.Code], e.Error())
+		codes[e.Code]++
+	}
+	return codes
+}
+
+func TestCheckZone(t *testing.T) {
+	zone := `$TTL 3600
+@	IN	SOA	ns1 hostmaster 1 14400 3600 604800 86400
+@	IN	NS	ns1
+@	IN	NS	ns2
+@	IN	MX	10 mail
+ns1	IN	A	127.0.0.1
+www	IN	A	127.0.0.2
+www	300	IN	A	127.0.0.3
+mail	IN	CNAME	www
+mail	IN	TXT	"cname and other data"
+sub	IN	NS	ns.sub
+sub	IN	NS	ns.example.org.
+ns.sub	IN	A	127.0.0.4
+other.example.org.	IN	A	127.0.0.5
+`
+	codes := checkZoneString(t, "miek.nl.", zone)
+	expect := map[int]int{
+		CheckNoGlue:        1, // ns2
+		CheckTtlMismatch:   1, // www
+		CheckCnameAndOther: 1, // mail
+		CheckCnameTarget:   1, // MX to mail
+		CheckOutOfZone:     1, // other.example.org.
+	}
+	for c, n := range expect {
+		if codes[c] != n {
+			t.Logf("Expected %d %s problem(s), got %d", n, Check_str[c], codes[c])
+			t.Fail()
+		}
+	}
+	if len(codes) != len(expect) {
+		t.Logf("Unexpected problems found: %v", codes)
+		t.Fail()
+	}
+
+	codes = checkZoneString(t, "miek.nl.", "www.miek.nl. 3600 IN A 127.0.0.1\n")
+	if codes[CheckNoSoa] != 1 || codes[CheckNoNs] != 1 {
+		t.Logf("Missing SOA and NS not detected")
+		t.Fail()
+	}
+
+	// The problems are reported in the same order on every run
+	rrs := parseZoneString(t, "$ORIGIN miek.nl.\nmail 3600 IN CNAME www\nmail 3600 IN TXT \"txt\"\n"+
+		"mail 3600 IN MX 10 mx\nmail 3600 IN A 127.0.0.1\nmail 3600 IN AAAA ::1\n")
+	for i := 0; i < 10; i++ {
+		var types []uint16
+		for _, e := range CheckZone("miek.nl.", rrs) {
+			if e.Code == CheckCnameAndOther {
+				types = append(types, e.Type)
+			}
+		}
+		if len(types) != 4 || types[0] != TypeA || types[1] != TypeMX || types[2] != TypeTXT || types[3] != TypeAAAA {
+			t.Fatalf("CNAME and other data not reported in type order: %v", types)
+		}
+	}
+}
+
+func TestCheckZoneDnssec(t *testing.T) {
+	soa := &RR_SOA{Hdr: RR_Header{"miek.nl.", TypeSOA, ClassINET, 3600, 0}, Ns: "ns.miek.nl.", Mbox: "miek.miek.nl.",
+		Serial: 1, Refresh: 14400, Retry: 3600, Expire: 604800, Minttl: 86400}
+	ns := &RR_NS{Hdr: RR_Header{"miek.nl.", TypeNS, ClassINET, 3600, 0}, Ns: "ns.miek.nl."}
+	a := &RR_A{Hdr: RR_Header{"ns.miek.nl.", TypeA, ClassINET, 3600, 0}, A: []byte{127, 0, 0, 1}}
+	key := &RR_DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 257, Protocol: 3, Algorithm: RSASHA256}
+	priv, err := key.Generate(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	rrs := []RR{soa, ns, a, key}
+	sets := [][]RR{{soa}, {ns}, {a}, {key}}
+	for _, set := range sets {
+		sig := &RR_RRSIG{Hdr: RR_Header{set[0].Header().Name, TypeRRSIG, ClassINET, 3600, 0}, KeyTag: key.KeyTag(),
+			SignerName: "miek.nl.", Algorithm: RSASHA256, Inception: 1293942305, Expiration: 2000000000}
+		if err := sig.Sign(priv, set); err != nil {
+			t.Fatalf("Failed to sign: %s", err)
+		}
+		rrs = append(rrs, sig)
+	}
+	if errs := CheckZone("miek.nl.", rrs); len(errs) != 0 {
+		for _, e := range errs {
+			t.Logf("%s", e)
+		}
+		t.Fatal("Problems found in a consistently signed zone")
+	}
+	// An unsigned RRset and an NSEC with a wrong bitmap
+	rrs = append(rrs, &RR_A{Hdr: RR_Header{"www.miek.nl.", TypeA, ClassINET, 3600, 0}, A: []byte{127, 0, 0, 2}})
+	rrs = append(rrs, &RR_NSEC{Hdr: RR_Header{"ns.miek.nl.", TypeNSEC, ClassINET, 3600, 0}, NextDomain: "www.miek.nl.",
+		TypeBitMap: []uint16{TypeA, TypeNSEC}})
+	n := 0
+	for _, e := range CheckZone("miek.nl.", rrs) {
+		t.Logf("%s", e)
+		if e.Code == CheckDnssec {
+			n++
+		}
+	}
+	// www A not signed, ns NSEC not signed and the NSEC bitmap lacks RRSIG
+	if n != 3 {
+		t.Logf("Expected 3 DNSSEC problems, got %d", n)
+		t.Fail()
+	}
+}
+
+func TestCheckZoneDelegationNsec(t *testing.T) {
+	key := &RR_DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 257, Protocol: 3, Algorithm: RSASHA256}
+	priv, err := key.Generate(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	ns := &RR_NS{Hdr: RR_Header{"sub.miek.nl.", TypeNS, ClassINET, 3600, 0}, Ns: "sub.miek.nl."}
+	glue := &RR_A{Hdr: RR_Header{"sub.miek.nl.", TypeA, ClassINET, 3600, 0}, A: []byte{127, 0, 0, 1}}
+	for _, bitmap := range [][]uint16{{TypeNS, TypeRRSIG, TypeNSEC}, {TypeA, TypeNS, TypeRRSIG, TypeNSEC}} {
+		nsec := &RR_NSEC{Hdr: RR_Header{"sub.miek.nl.", TypeNSEC, ClassINET, 3600, 0}, NextDomain: "miek.nl.", TypeBitMap: bitmap}
+		sig := &RR_RRSIG{Hdr: RR_Header{"sub.miek.nl.", TypeRRSIG, ClassINET, 3600, 0}, KeyTag: key.KeyTag(),
+			SignerName: "miek.nl.", Algorithm: RSASHA256, Inception: 1293942305, Expiration: 2000000000}
+		if err := sig.Sign(priv, []RR{nsec}); err != nil {
+			t.Fatalf("Failed to sign: %s", err)
+		}
+		n := 0
+		for _, e := range CheckZone("miek.nl.", []RR{key, ns, glue, nsec, sig}) {
+			t.Logf("%s", e)
+			if e.Type == TypeNSEC {
+				n++
+			}
+		}
+		// The glue at the delegation must not be in the bitmap
+		if bitmap[0] == TypeA && n != 1 {
+			t.Logf("Expected a bitmap problem for %v, got %d", bitmap, n)
+			t.Fail()
+		}
+		if bitmap[0] != TypeA && n != 0 {
+			t.Logf("Expected no bitmap problem for %v, got %d", bitmap, n)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package dns
+
+// Differences between versions of a zone and a journal of
+// those differences to answer IXFR requests, see RFC 1995.
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ZoneDiff holds the difference between two versions of a zone.
+type ZoneDiff struct {
+	OldSoa  *RR_SOA // SOA of the old version of the zone
+	NewSoa  *RR_SOA // SOA of the new version of the zone
+	Removed []RR    // RRs in the old version, but not in the new one
+	Added   []RR    // RRs in the new version, but not in the old one
+}
+
+// Diff compares the old version of a zone, from, with the new version,
+// to, and returns the difference. Both versions must contain a SOA record,
+// the SOA records themselves are not part of Removed or Added. RRs are compared on their text representation
+// with the owner name in lower case; a changed TTL results in removing the
+// old RR and adding the new one. The serial of to must be greater than the
+// serial of from (RFC 1982), otherwise ErrSerial is returned.
+func Diff(from, to []RR) (*ZoneDiff, error) {
+	d := new(ZoneDiff)
+	oldrr := make(map[string]bool)
+	for _, r := range from {
+		if s, ok := r.(*RR_SOA); ok {
+			d.OldSoa = s
+			continue
+		}
+		oldrr[rrKey(r)] = true
+	}
+	newrr := make(map[string]bool)
+	for _, r := range to {
+		if s, ok := r.(*RR_SOA); ok {
+			d.NewSoa = s
+			continue
+		}
+		newrr[rrKey(r)] = true
+	}
+	if d.OldSoa == nil || d.NewSoa == nil {
+		return nil, ErrXfrSoa
+	}
+	if !SerialGreater(d.NewSoa.Serial, d.OldSoa.Serial) {
+		return nil, ErrSerial
+	}
+	for _, r := range from {
+		if _, ok := r.(*RR_SOA); ok {
+			continue
+		}
+		if k := rrKey(r); !newrr[k] {
+			d.Removed = append(d.Removed, r)
+			newrr[k] = true // only remove duplicates once
+		}
+	}
+	for _, r := range to {
+		if _, ok := r.(*RR_SOA); ok {
+			continue
+		}
+		if k := rrKey(r); !oldrr[k] {
+			d.Added = append(d.Added, r)
+			oldrr[k] = true
+		}
+	}
+	return d, nil
+}
+
+// RRs returns the difference sequence as used in an IXFR response: the
+// old SOA, the removed RRs, the new SOA and the added RRs.
+func (d *ZoneDiff) RRs() []RR {
+	rrs := make([]RR, 0, len(d.Removed)+len(d.Added)+2)
+	rrs = append(rrs, d.OldSoa)
+	rrs = append(rrs, d.Removed...)
+	rrs = append(rrs, d.NewSoa)
+	return append(rrs, d.Added...)
+}
+
+// rrKey returns the string used to compare RRs.
+func rrKey(r RR) string {
+	s := r.String()
+	return strings.ToLower(r.Header().Name) + s[len(r.Header().Name):]
+}
+
+// Journal holds successive differences of a zone, oldest first. Each
+// difference starts at the serial where the previous one ended.
+type Journal struct {
+	Diffs []*ZoneDiff
+}
+
+// Add appends the difference d to the journal. The old serial of d must
+// be the serial the journal ends with and the new serial of d must be
+// greater than its old serial, otherwise ErrJournal is returned.
+func (j *Journal) Add(d *ZoneDiff) error {
+	if !SerialGreater(d.NewSoa.Serial, d.OldSoa.Serial) {
+		return ErrJournal
+	}
+	if len(j.Diffs) > 0 && j.Diffs[len(j.Diffs)-1].NewSoa.Serial != d.OldSoa.Serial {
+		return ErrJournal
+	}
+	j.Diffs = append(j.Diffs, d)
+	return nil
+}
+
+// Trim removes the oldest differences from the journal, until at most n
+// are left.
+func (j *Journal) Trim(n int) {
+	if len(j.Diffs) > n {
+		j.Diffs = j.Diffs[len(j.Diffs)-n:]
+	}
+}
+
+// Ixfr returns the RRs of the answer section of an IXFR response to a
+// client having serial. This is the current SOA, all the differences
+// from serial onwards and the current SOA again. If the client is up to
+// date only the current SOA is returned. When serial is not in the
+// journal ErrJournalSoa is returned and the caller should fall back to
+// AXFR.
+func (j *Journal) Ixfr(serial uint32) ([]RR, error) {
+	if len(j.Diffs) == 0 {
+		return nil, ErrJournalSoa
+	}
+	cur := j.Diffs[len(j.Diffs)-1].NewSoa
+	if cur.Serial == serial {
+		return []RR{cur}, nil
+	}
+	for i, d := range j.Diffs {
+		if d.OldSoa.Serial != serial {
+			continue
+		}
+		rrs := []RR{cur}
+		for _, d1 := range j.Diffs[i:] {
+			rrs = append(rrs, d1.RRs()...)
+		}
+		return append(rrs, cur), nil
+	}
+	return nil, ErrJournalSoa
+}
+
+// WriteJournal writes the journal to w. The format is a zone file with
+// one RR per line, consisting of the difference sequences of all the
+// differences in the journal.
+func (j *Journal) WriteJournal(w io.Writer) error {
+	for _, d := range j.Diffs {
+		for _, r := range d.RRs() {
+			if _, err := io.WriteString(w, r.String()+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadJournal reads a journal written by WriteJournal from r. The string
+// origin is used as the origin when parsing, file is only used in error
+// reporting.
+func ReadJournal(r io.Reader, origin, file string) (*Journal, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	j := new(Journal)
+	// An empty journal is written as an empty file, which is not a zone
+	if journalEmpty(buf) {
+		return j, nil
+	}
+	var rrs []RR
+	zp := NewZoneParser(bytes.NewReader(buf), origin, file)
+	for {
+		rr, err := zp.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	diffs, err := diffSequence(rrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diffs {
+		if err := j.Add(d); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// journalEmpty returns true when buf only holds blank and comment lines.
+func journalEmpty(buf []byte) bool {
+	for _, l := range bytes.Split(buf, []byte{'\n'}) {
+		l = bytes.TrimSpace(l)
+		if len(l) > 0 && l[0] != ';' {
+			return false
+		}
+	}
+	return true
+}
+
+// diffSequence splits rrs, a list of difference sequences as found in an
+// IXFR response, into differences.
+func diffSequence(rrs []RR) ([]*ZoneDiff, error) {
+	var (
+		diffs []*ZoneDiff
+		d     *ZoneDiff
+	)
+	for _, rr := range rrs {
+		soa, ok := rr.(*RR_SOA)
+		switch {
+		case d == nil && !ok:
+			return nil, ErrXfrSoa
+		case d == nil:
+			d = &ZoneDiff{OldSoa: soa}
+		case ok && d.NewSoa == nil:
+			d.NewSoa = soa
+		case ok:
+			diffs = append(diffs, d)
+			d = &ZoneDiff{OldSoa: soa}
+		case d.NewSoa == nil:
+			d.Removed = append(d.Removed, rr)
+		default:
+			d.Added = append(d.Added, rr)
+		}
+	}
+	if d == nil || d.NewSoa == nil {
+		return nil, ErrXfrSoa
+	}
+	return append(diffs, d), nil
+}
@@ -0,0 +1,117 @@
+package dns
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func parseZoneString(t *testing.T, zone string) (rrs []RR) {
+	for x := range ParseZone(strings.NewReader(zone), "miek.nl.", "") {
+		if x.Error != nil {
+			t.Fatalf("Failed to parse the zone: %s", x.Error)
+		}
+		rrs = append(rrs, x.RR)
+	}
+	return
+}
+
+func TestDiffJournal(t *testing.T) {
+	zone1 := parseZoneString(t, `@ 3600 IN SOA ns hostmaster 1 14400 3600 604800 86400
+@ 3600 IN NS ns
+ns 3600 IN A 127.0.0.1
+www 3600 IN A 127.0.0.2
+`)
+	zone2 := parseZoneString(t, `@ 3600 IN SOA ns hostmaster 2 14400 3600 604800 86400
+@ 3600 IN NS ns
+NS 3600 IN A 127.0.0.1
+www 3600 IN A 127.0.0.3
+`)
+	zone3 := parseZoneString(t, `@ 3600 IN SOA ns hostmaster 3 14400 3600 604800 86400
+@ 3600 IN NS ns
+ns 3600 IN A 127.0.0.1
+www 300 IN A 127.0.0.3
+mail 3600 IN A 127.0.0.4
+`)
+	d1, err := Diff(zone1, zone2)
+	if err != nil {
+		t.Fatalf("Failed to diff: %s", err)
+	}
+	if len(d1.Removed) != 1 || len(d1.Added) != 1 || d1.Added[0].String() != zone2[3].String() {
+		t.Fatalf("Wrong diff: %v", d1.RRs())
+	}
+	d2, _ := Diff(zone2, zone3)
+	if len(d2.Removed) != 1 || len(d2.Added) != 2 {
+		t.Fatalf("Wrong diff: %v", d2.RRs())
+	}
+	if _, err := Diff(zone2, zone1); err != ErrSerial {
+		t.Fatalf("Expected ErrSerial for a lower serial, got %v", err)
+	}
+	if _, err := Diff(zone1, zone1); err != ErrSerial {
+		t.Fatalf("Expected ErrSerial for an equal serial, got %v", err)
+	}
+
+	j := new(Journal)
+	if err := j.Add(d2); err != nil {
+		t.Fatalf("Failed to add diff: %s", err)
+	}
+	if err := j.Add(d2); err != ErrJournal {
+		t.Fatalf("Added a diff that does not continue the journal")
+	}
+	if err := new(Journal).Add(&ZoneDiff{OldSoa: d2.NewSoa, NewSoa: d2.OldSoa}); err != ErrJournal {
+		t.Fatalf("Added a diff that lowers the serial")
+	}
+	j = new(Journal)
+	j.Add(d1)
+	j.Add(d2)
+
+	buf := new(bytes.Buffer)
+	if err := j.WriteJournal(buf); err != nil {
+		t.Fatalf("Failed to write the journal: %s", err)
+	}
+	j1, err := ReadJournal(buf, "miek.nl.", "")
+	if err != nil {
+		t.Fatalf("Failed to read the journal: %s", err)
+	}
+	if len(j1.Diffs) != 2 {
+		t.Fatalf("Expected 2 diffs, got %d", len(j1.Diffs))
+	}
+
+	rrs, err := j1.Ixfr(1)
+	if err != nil {
+		t.Fatalf("Failed to create IXFR: %s", err)
+	}
+	// SOA, 2x (SOA, removed, SOA, added), SOA
+	if len(rrs) != 11 || rrs[0].(*RR_SOA).Serial != 3 || rrs[10].(*RR_SOA).Serial != 3 || rrs[1].(*RR_SOA).Serial != 1 {
+		t.Fatalf("Wrong IXFR: %v", rrs)
+	}
+	if rrs, _ := j1.Ixfr(3); len(rrs) != 1 {
+		t.Fatalf("Expected only the SOA for an up to date serial: %v", rrs)
+	}
+	if _, err := j1.Ixfr(0); err != ErrJournalSoa {
+		t.Fatalf("Expected ErrJournalSoa for an unknown serial")
+	}
+	j1.Trim(1)
+	if _, err := j1.Ixfr(1); err != ErrJournalSoa {
+		t.Fatalf("Serial 1 should have been trimmed")
+	}
+
+	// An empty journal
+	buf.Reset()
+	if err := new(Journal).WriteJournal(buf); err != nil {
+		t.Fatalf("Failed to write the empty journal: %s", err)
+	}
+	j1, err = ReadJournal(buf, "miek.nl.", "")
+	if err != nil {
+		t.Fatalf("Failed to read the empty journal: %s", err)
+	}
+	if len(j1.Diffs) != 0 {
+		t.Fatalf("Expected no diffs, got %d", len(j1.Diffs))
+	}
+	if _, err := ReadJournal(strings.NewReader("; only a comment\n"), "miek.nl.", ""); err != nil {
+		t.Fatalf("Failed to read a journal without diffs: %s", err)
+	}
+	if _, err := ReadJournal(strings.NewReader("miek.nl. IN BOGUS\n"), "miek.nl.", ""); err == nil {
+		t.Fatalf("Read a broken journal")
+	}
+}
@@ -0,0 +1,301 @@
+package dns
+
+// An in-memory zone, and applying incremental zone transfers to it.
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Zone holds the RRs of a zone in memory. It is safe for concurrent use.
+type Zone struct {
+	Origin string
+	mu     sync.RWMutex
+	names  map[string]map[uint16][]RR // lower case owner name -> type -> RRs
+	size   int
+	sorted []string // the owner names in canonical order, nil until ordered is called
+}
+
+// NewZone returns an empty zone with apex origin.
+func NewZone(origin string) *Zone {
+	return &Zone{Origin: Fqdn(origin), names: make(map[string]map[uint16][]RR)}
+}
+
+// Insert adds r to the zone. Adding an RR that is already in the zone is
+// a no-op, adding a SOA replaces the current SOA of the zone.
+func (z *Zone) Insert(r RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.insert(r)
+}
+
+// Remove removes r from the zone, it returns false if r was not in the zone.
+func (z *Zone) Remove(r RR) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.remove(r)
+}
+
+// Soa returns the SOA record of the zone, or nil if it has none.
+func (z *Zone) Soa() *RR_SOA {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return z.soa()
+}
+
+// RRset returns the RRs in the zone with owner name and type t.
+func (z *Zone) RRset(name string, t uint16) []RR {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	rrset := z.names[strings.ToLower(name)][t]
+	r := make([]RR, len(rrset))
+	copy(r, rrset)
+	return r
+}
+
+// Len returns the number of RRs in the zone.
+func (z *Zone) Len() int {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return z.size
+}
+
+// RRs returns all the RRs of the zone in the order used by WriteZone: the
+// SOA first and the other RRs in canonical order.
+func (z *Zone) RRs() []RR {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return z.rrs()
+}
+
+func (z *Zone) rrs() []RR {
+	rrs := make([]RR, 0, z.size)
+	for _, types := range z.names {
+		for _, rrset := range types {
+			rrs = append(rrs, rrset...)
+		}
+	}
+	sort.Sort(&zoneSorter{rrs, z.Origin})
+	return rrs
+}
+
+func (z *Zone) soa() *RR_SOA {
+	if s := z.names[strings.ToLower(z.Origin)][TypeSOA]; len(s) > 0 {
+		return s[0].(*RR_SOA)
+	}
+	return nil
+}
+
+// insert adds r and returns true if the zone changed.
+func (z *Zone) insert(r RR) bool {
+	name := strings.ToLower(r.Header().Name)
+	t := r.Header().Rrtype
+	if _, ok := z.names[name]; !ok {
+		z.names[name] = make(map[uint16][]RR)
+		z.addName(name)
+	}
+	if t == TypeSOA {
+		z.size += 1 - len(z.names[name][t])
+		z.names[name][t] = []RR{r}
+		return true
+	}
+	k := rrKey(r)
+	for _, r1 := range z.names[name][t] {
+		if rrKey(r1) == k {
+			return false
+		}
+	}
+	z.names[name][t] = append(z.names[name][t], r)
+	z.size++
+	return true
+}
+
+// remove removes r and returns true if the zone changed.
+func (z *Zone) remove(r RR) bool {
+	name := strings.ToLower(r.Header().Name)
+	t := r.Header().Rrtype
+	rrset := z.names[name][t]
+	k := rrKey(r)
+	for i, r1 := range rrset {
+		if rrKey(r1) != k {
+			continue
+		}
+		if len(rrset) == 1 {
+			delete(z.names[name], t)
+			if len(z.names[name]) == 0 {
+				delete(z.names, name)
+				z.removeName(name)
+			}
+		} else {
+			z.names[name][t] = append(rrset[:i], rrset[i+1:]...)
+		}
+		z.size--
+		return true
+	}
+	return false
+}
+
+// ordered returns the owner names of the zone in canonical order. The
+// order is built on the first call, from then on insert and remove keep
+// it up to date.
+func (z *Zone) ordered() []string {
+	if z.sorted == nil {
+		z.sorted = make([]string, 0, len(z.names))
+		for name := range z.names {
+			z.sorted = append(z.sorted, name)
+		}
+		sort.Sort(nameSlice(z.sorted))
+	}
+	return z.sorted
+}
+
+// search returns the index in the ordered names of the first name that
+// is not smaller than name.
+func (z *Zone) search(name string) int {
+	names := z.ordered()
+	return sort.Search(len(names), func(i int) bool { return compareNames(names[i], name) >= 0 })
+}
+
+func (z *Zone) addName(name string) {
+	if z.sorted == nil {
+		return
+	}
+	i := z.search(name)
+	z.sorted = append(z.sorted, "")
+	copy(z.sorted[i+1:], z.sorted[i:])
+	z.sorted[i] = name
+}
+
+func (z *Zone) removeName(name string) {
+	if z.sorted == nil {
+		return
+	}
+	if i := z.search(name); i < len(z.sorted) && z.sorted[i] == name {
+		z.sorted = append(z.sorted[:i], z.sorted[i+1:]...)
+	}
+}
+
+// zoneChange records a change made to a zone, to be able to roll it back.
+type zoneChange struct {
+	r   RR
+	add bool
+}
+
+// applyDiff applies d to the zone, recording the changes in log. Removing
+// an RR that is not in the zone is an error.
+func (z *Zone) applyDiff(d *ZoneDiff, log *[]zoneChange) error {
+	for _, r := range d.Removed {
+		if !z.remove(r) {
+			return ErrXfrDelete
+		}
+		*log = append(*log, zoneChange{r, false})
+	}
+	for _, r := range d.Added {
+		if z.insert(r) {
+			*log = append(*log, zoneChange{r, true})
+		}
+	}
+	z.insert(d.NewSoa)
+	return nil
+}
+
+// rollback undoes the changes in log and restores the SOA soa.
+func (z *Zone) rollback(log []zoneChange, soa *RR_SOA) {
+	for i := len(log) - 1; i >= 0; i-- {
+		if log[i].add {
+			z.remove(log[i].r)
+		} else {
+			z.insert(log[i].r)
+		}
+	}
+	z.insert(soa)
+}
+
+// ApplyIxfr reads the envelopes of an incoming Ixfr, as returned by
+// Client.XfrIn, from c and applies them to the zone. The whole transfer is
+// read before the zone is changed, if the transfer fails the zone is left
+// as it was.
+//
+// Both the condensed form, with one difference sequence from the zone's
+// serial to the servers' serial, and the uncondensed form, with one
+// sequence per version, are accepted. The serials of the sequences must
+// follow each other, starting with the serial of the zone and ending with
+// the servers' serial, otherwise ErrXfrSerial is returned. If a sequence
+// removes an RR that is not in the zone, ErrXfrDelete is returned and all
+// changes are rolled back. A response in the Axfr format replaces the
+// contents of the zone.
+//
+// The differences applied are returned, these can be added to a Journal.
+// For an Axfr formatted response this is a single difference between the
+// old and new contents. When the zone is up to date, or was empty, nothing
+// is returned.
+func (z *Zone) ApplyIxfr(c chan *Envelope) ([]*ZoneDiff, error) {
+	var rrs []RR
+	for e := range c {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		rrs = append(rrs, e.RR...)
+	}
+	if len(rrs) == 0 {
+		return nil, ErrXfrSoa
+	}
+	cur, ok := rrs[0].(*RR_SOA)
+	if !ok {
+		return nil, ErrXfrSoa
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	soa := z.soa()
+	if len(rrs) == 1 {
+		if soa != nil && soa.Serial == cur.Serial {
+			return nil, nil
+		}
+		return nil, ErrXfrSerial
+	}
+	if end, ok := rrs[len(rrs)-1].(*RR_SOA); !ok || end.Serial != cur.Serial || len(rrs) < 3 {
+		return nil, ErrXfrSoa
+	}
+
+	if _, ok := rrs[1].(*RR_SOA); !ok {
+		// Axfr format
+		var diffs []*ZoneDiff
+		if soa != nil {
+			d, err := Diff(z.rrs(), rrs[:len(rrs)-1])
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+		}
+		z.names = make(map[string]map[uint16][]RR)
+		z.sorted = nil
+		z.size = 0
+		for _, r := range rrs[:len(rrs)-1] {
+			z.insert(r)
+		}
+		return diffs, nil
+	}
+
+	diffs, err := diffSequence(rrs[1 : len(rrs)-1])
+	if err != nil {
+		return nil, err
+	}
+	if soa == nil || diffs[0].OldSoa.Serial != soa.Serial || diffs[len(diffs)-1].NewSoa.Serial != cur.Serial {
+		return nil, ErrXfrSerial
+	}
+	for i := 1; i < len(diffs); i++ {
+		if diffs[i].OldSoa.Serial != diffs[i-1].NewSoa.Serial {
+			return nil, ErrXfrSerial
+		}
+	}
+	var log []zoneChange
+	for _, d := range diffs {
+		if err := z.applyDiff(d, &log); err != nil {
+			z.rollback(log, soa)
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
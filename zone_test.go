@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"testing"
+)
+
+// envelopes returns a channel that delivers rrs in envelopes of two RRs,
+// followed by an envelope with err if it is not nil.
+func envelopes(rrs []RR, err error) chan *Envelope {
+	c := make(chan *Envelope)
+	go func() {
+		for i := 0; i < len(rrs); i += 2 {
+			j := i + 2
+			if j > len(rrs) {
+				j = len(rrs)
+			}
+			c <- &Envelope{RR: rrs[i:j]}
+		}
+		if err != nil {
+			c <- &Envelope{Error: err}
+		}
+		close(c)
+	}()
+	return c
+}
+
+func zoneString(z *Zone) (s string) {
+	for _, r := range z.RRs() {
+		s += r.String() + "\n"
+	}
+	return
+}
+
+func TestZoneApplyIxfr(t *testing.T) {
+	zone1 := xfrTestZone(1, 4)
+	zone2 := xfrTestZone(2, 6)
+	zone3 := xfrTestZone(3, 3)
+	d1, _ := Diff(zone1, zone2)
+	d2, _ := Diff(zone2, zone3)
+	d13, _ := Diff(zone1, zone3)
+
+	z := NewZone("miek.nl.")
+	axfr := append(append([]RR{}, zone1...), zone1[0])
+	if diffs, err := z.ApplyIxfr(envelopes(axfr, nil)); err != nil || diffs != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+	if z.Len() != len(zone1) || z.Soa().Serial != 1 {
+		t.Fatalf("Zone not loaded: %d RRs", z.Len())
+	}
+	before := zoneString(z)
+
+	uncondensed := append(append(append([]RR{zone3[0]}, d1.RRs()...), d2.RRs()...), zone3[0])
+	condensed := append(append([]RR{zone3[0]}, d13.RRs()...), zone3[0])
+	gap := append(append([]RR{zone3[0]}, d2.RRs()...), zone3[0])
+	// The second sequence removes an RR that is not there
+	bad := &ZoneDiff{OldSoa: d2.OldSoa, NewSoa: d2.NewSoa, Removed: xfrTestZone(3, 100)[99:]}
+	baddelete := append(append(append([]RR{zone3[0]}, d1.RRs()...), bad.RRs()...), zone3[0])
+
+	tests := []struct {
+		rrs   []RR
+		err   error
+		diffs int
+	}{
+		{uncondensed, nil, 2},
+		{condensed, nil, 1},
+		{[]RR{zone3[0]}, ErrXfrSerial, 0},
+		{gap, ErrXfrSerial, 0},
+		{baddelete, ErrXfrDelete, 0},
+	}
+	for i, tc := range tests {
+		diffs, err := z.ApplyIxfr(envelopes(tc.rrs, nil))
+		if err != tc.err {
+			t.Fatalf("Test %d: expected error %v, got %v", i, tc.err, err)
+		}
+		if len(diffs) != tc.diffs {
+			t.Fatalf("Test %d: expected %d diffs, got %d", i, tc.diffs, len(diffs))
+		}
+		if err == nil {
+			if z.Soa().Serial != 3 || z.Len() != len(zone3) {
+				t.Fatalf("Test %d: zone not updated: serial %d, %d RRs", i, z.Soa().Serial, z.Len())
+			}
+			// Up to date now
+			if diffs, err := z.ApplyIxfr(envelopes([]RR{zone3[0]}, nil)); err != nil || diffs != nil {
+				t.Fatalf("Test %d: expected zone to be up to date: %v", i, err)
+			}
+			// Reset for the next test
+			z = NewZone("miek.nl.")
+			z.ApplyIxfr(envelopes(axfr, nil))
+			continue
+		}
+		if zoneString(z) != before {
+			t.Fatalf("Test %d: zone changed after a failed transfer:\n%s", i, zoneString(z))
+		}
+	}
+
+	if _, err := z.ApplyIxfr(envelopes(uncondensed[:5], ErrShortRead)); err != ErrShortRead {
+		t.Fatalf("Expected the error of the transfer, got %v", err)
+	}
+	if zoneString(z) != before {
+		t.Fatalf("Zone changed after an aborted transfer")
+	}
+}
@@ -0,0 +1,536 @@
+package dns
+
+// Keeping a signed zone signed: signing the changes made to it and
+// refreshing the signatures before they expire.
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// SignDiff signs the changes d made to the signed zone z, such as the
+// difference returned by Zone.Update. The signatures of the changed
+// RRsets and of the SOA are replaced and the NSEC or NSEC3 chain is
+// brought up to date: only the records of the changed names, of the empty
+// non-terminals above them and of their predecessors in the chain change
+// and are signed again. When a delegation is added or removed the
+// signatures of the names below it are removed or added. When the chain
+// of the zone was made with other parameters, or another SOA minimum TTL,
+// it is made anew.
+//
+// The returned difference is d together with the changes made by
+// signing, it can be added to a Journal. If signing fails the changes made
+// by signing are undone, d itself is not.
+func (s *ZoneSigner) SignDiff(z *Zone, d *ZoneDiff) (*ZoneDiff, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	origin := strings.ToLower(z.Origin)
+
+	var log []zoneChange
+	changed := make(map[string]map[uint16]bool)
+	for _, r := range d.Removed {
+		log = append(log, zoneChange{r, false})
+		markChanged(changed, z, origin, r)
+	}
+	for _, r := range d.Added {
+		log = append(log, zoneChange{r, true})
+		markChanged(changed, z, origin, r)
+	}
+	if z.soa() != nil {
+		markChanged(changed, z, origin, z.soa())
+	}
+
+	var slog []zoneChange
+	err := s.resign(z, changed, &slog)
+	if err == nil {
+		err = s.updateChain(z, changed, &slog)
+	}
+	if err != nil {
+		z.rollback(slog, z.soa())
+		return nil, err
+	}
+	sd := netChanges(append(log, slog...))
+	sd.OldSoa, sd.NewSoa = d.OldSoa, d.NewSoa
+	return sd, nil
+}
+
+// Resign re-signs the RRsets in the signed zone z whose signatures expire
+// within the Refresh duration. When signatures are replaced the serial of
+// the zone is increased using the Serial scheme, and the difference made
+// is returned. When nothing needs to be re-signed nil is returned.
+func (s *ZoneSigner) Resign(z *Zone) (*ZoneDiff, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	origin := strings.ToLower(z.Origin)
+	old := z.soa()
+	if old == nil {
+		return nil, ErrSoa
+	}
+	refresh := s.Refresh
+	if refresh == 0 {
+		refresh = s.Validity / 4
+		if refresh == 0 {
+			refresh = 30 * 24 * time.Hour / 4
+		}
+	}
+	now := s.clock().Now()
+	changed := make(map[string]map[uint16]bool)
+	for name, types := range z.names {
+		for _, r := range types[TypeRRSIG] {
+			sig := r.(*RR_RRSIG)
+			if serialTime(sig.Expiration, now.Unix())-now.Unix() < int64(refresh/time.Second) {
+				if _, ok := changed[name]; !ok {
+					changed[name] = make(map[uint16]bool)
+				}
+				changed[name][sig.TypeCovered] = true
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	soa := *old
+	soa.Serial = NextSerial(old.Serial, s.Serial, now)
+	z.insert(&soa)
+	if _, ok := changed[origin]; !ok {
+		changed[origin] = make(map[uint16]bool)
+	}
+	changed[origin][TypeSOA] = true
+
+	var log []zoneChange
+	if err := s.resign(z, changed, &log); err != nil {
+		z.rollback(log, old)
+		return nil, err
+	}
+	d := netChanges(log)
+	d.OldSoa, d.NewSoa = old, &soa
+	return d, nil
+}
+
+// Run calls Resign for z every interval until quit is closed. After each
+// call that changed the zone or failed, f is called with the result.
+func (s *ZoneSigner) Run(z *Zone, interval time.Duration, quit chan bool, f func(*ZoneDiff, error)) {
+	for {
+		select {
+		case <-quit:
+			return
+		case <-s.clock().After(interval):
+		}
+		d, err := s.Resign(z)
+		if (d != nil || err != nil) && f != nil {
+			f(d, err)
+		}
+	}
+}
+
+// unsigned returns the names and RRsets of the zone without the DNSSEC
+// records made by signing.
+func (z *Zone) unsigned() map[string]map[uint16][]RR {
+	view := make(map[string]map[uint16][]RR)
+	for name, types := range z.names {
+		for t, rrset := range types {
+			switch t {
+			case TypeRRSIG, TypeNSEC, TypeNSEC3, TypeNSEC3PARAM:
+				continue
+			}
+			if _, ok := view[name]; !ok {
+				view[name] = make(map[uint16][]RR)
+			}
+			view[name][t] = rrset
+		}
+	}
+	return view
+}
+
+// markChanged records that the RRset of r changed. When r is an NS record
+// below the apex a delegation changed, and all RRsets at and below it are
+// marked too.
+func markChanged(changed map[string]map[uint16]bool, z *Zone, origin string, r RR) {
+	mark := func(name string, t uint16) {
+		if _, ok := changed[name]; !ok {
+			changed[name] = make(map[uint16]bool)
+		}
+		changed[name][t] = true
+	}
+	name := strings.ToLower(r.Header().Name)
+	mark(name, r.Header().Rrtype)
+	if r.Header().Rrtype != TypeNS || name == origin {
+		return
+	}
+	names := z.ordered()
+	for i := z.search(name); i < len(names) && IsSubDomain(name, names[i]); i++ {
+		for t := range unsignedTypes(z.names[names[i]]) {
+			mark(names[i], t)
+		}
+	}
+}
+
+// resign replaces the signatures of the changed RRsets, recording the
+// changes in log.
+func (s *ZoneSigner) resign(z *Zone, changed map[string]map[uint16]bool, log *[]zoneChange) error {
+	origin := strings.ToLower(z.Origin)
+	signer := z.soa().Hdr.Name
+	for name, types := range changed {
+		for t := range types {
+			z.removeSigs(name, t, log)
+			rrset := z.names[name][t]
+			if len(rrset) == 0 || !signable(z.names, origin, name, t) {
+				continue
+			}
+			sigs, err := s.sign(s.keys(t), signer, rrset)
+			if err != nil {
+				return err
+			}
+			for _, sig := range sigs {
+				z.insert(sig)
+				*log = append(*log, zoneChange{sig, true})
+			}
+		}
+	}
+	return nil
+}
+
+// updateChain brings the NSEC or NSEC3 chain of the zone up to date for
+// the changed names, signing the chain records that changed.
+func (s *ZoneSigner) updateChain(z *Zone, changed map[string]map[uint16]bool, log *[]zoneChange) error {
+	origin := strings.ToLower(z.Origin)
+	if ok, err := s.chainCurrent(z, origin); err != nil || !ok {
+		if err != nil {
+			return err
+		}
+		return s.rebuildChain(z, log)
+	}
+	// With NSEC3 the empty non-terminals above the changed names may come
+	// or go
+	affected := make(map[string]bool)
+	for name := range changed {
+		affected[name] = true
+		if s.NSEC3 == nil {
+			continue
+		}
+		labels := SplitLabels(name)
+		for i := 1; i < len(labels)-CompareLabels(name, origin); i++ {
+			affected[strings.Join(labels[i:], ".")+"."] = true
+		}
+	}
+	t := s.chainType()
+	dirty := make(map[string]bool) // owner names of the chain records to sign
+	var add []RR
+	for name := range affected {
+		want, owner, err := s.chainRecord(z, origin, name)
+		if err != nil {
+			return err
+		}
+		have := chainRR(z, owner, t)
+		switch {
+		case want == nil && have != nil:
+			s.unlink(z, origin, have, log, dirty)
+		case want != nil && have == nil:
+			add = append(add, want)
+		case want != nil:
+			want = setNext(want, nextOf(have))
+			if rrKey(want) != rrKey(have) {
+				z.replace(have, want, log)
+				dirty[owner] = true
+			}
+		}
+	}
+	for _, r := range add {
+		s.link(z, origin, r, log, dirty)
+	}
+	signer := z.soa().Hdr.Name
+	for owner := range dirty {
+		z.removeSigs(owner, t, log)
+		r := chainRR(z, owner, t)
+		if r == nil {
+			continue
+		}
+		sigs, err := s.sign(s.keys(t), signer, []RR{r})
+		if err != nil {
+			return err
+		}
+		for _, sig := range sigs {
+			z.insert(sig)
+			*log = append(*log, zoneChange{sig, true})
+		}
+	}
+	return nil
+}
+
+// chainType returns the type of the records of the chain.
+func (s *ZoneSigner) chainType() uint16 {
+	if s.NSEC3 != nil {
+		return TypeNSEC3
+	}
+	return TypeNSEC
+}
+
+// chainCurrent checks if the chain of the zone is made with the
+// parameters of s, by looking at the chain record of the apex.
+func (s *ZoneSigner) chainCurrent(z *Zone, origin string) (bool, error) {
+	want, owner, err := s.chainRecord(z, origin, origin)
+	if err != nil || want == nil {
+		return false, err
+	}
+	have := chainRR(z, owner, s.chainType())
+	if have == nil || have.Header().Ttl != want.Header().Ttl || have.Header().Class != want.Header().Class {
+		return false, nil
+	}
+	param := z.names[origin][TypeNSEC3PARAM]
+	if s.NSEC3 == nil {
+		return len(param) == 0, nil
+	}
+	h, w := have.(*RR_NSEC3), want.(*RR_NSEC3)
+	if h.Flags != w.Flags || h.Iterations != w.Iterations || h.Hash != w.Hash || !strings.EqualFold(h.Salt, w.Salt) {
+		return false, nil
+	}
+	return len(param) == 1 && rrKey(param[0]) == rrKey(s.nsec3param(z.soa())), nil
+}
+
+// member checks if name is in the chain because of its own RRsets: it is
+// authoritative data or a delegation, not left out by opt-out.
+func (s *ZoneSigner) member(z *Zone, origin, name string) bool {
+	types := z.names[name]
+	if len(unsignedTypes(types)) == 0 || !authoritative(z.names, origin, name) {
+		return false
+	}
+	return !(s.NSEC3 != nil && s.OptOut && name != origin && len(types[TypeNS]) > 0 && len(types[TypeDS]) == 0)
+}
+
+// chainRecord returns the chain record name should have, without the next
+// name set, and its owner name. The record is nil when name is not in the
+// chain.
+func (s *ZoneSigner) chainRecord(z *Zone, origin, name string) (RR, string, error) {
+	soa := z.soa()
+	types := unsignedTypes(z.names[name])
+	if s.NSEC3 == nil {
+		if !s.member(z, origin, name) {
+			return nil, name, nil
+		}
+		bitmap := append(signedTypes(types, name != origin), TypeRRSIG, TypeNSEC)
+		sort.Sort(uint16Slice(bitmap))
+		return &RR_NSEC{Hdr: RR_Header{Name: name, Rrtype: TypeNSEC, Class: soa.Hdr.Class, Ttl: soa.Minttl},
+			TypeBitMap: bitmap}, name, nil
+	}
+	salt := s.nsec3param(soa).Salt
+	h := HashName(name, s.NSEC3.Hash, s.NSEC3.Iterations, salt)
+	if h == "" {
+		return nil, "", ErrAlg
+	}
+	owner := strings.ToLower(h) + "." + origin
+	// An empty non-terminal is in the chain when a member is below it
+	if !s.member(z, origin, name) && (len(types) > 0 || !s.memberBelow(z, origin, name)) {
+		return nil, owner, nil
+	}
+	bitmap := signedTypes(types, name != origin)
+	if len(bitmap) > 0 && (name == origin || len(types[TypeNS]) == 0 || len(types[TypeDS]) > 0) {
+		bitmap = append(bitmap, TypeRRSIG)
+	}
+	if name == origin {
+		bitmap = append(bitmap, TypeNSEC3PARAM)
+	}
+	sort.Sort(uint16Slice(bitmap))
+	flags := uint8(0)
+	if s.OptOut {
+		flags = 1
+	}
+	return &RR_NSEC3{Hdr: RR_Header{Name: owner, Rrtype: TypeNSEC3, Class: soa.Hdr.Class, Ttl: soa.Minttl},
+		Hash: s.NSEC3.Hash, Flags: flags, Iterations: s.NSEC3.Iterations, SaltLength: uint8(len(salt) / 2), Salt: salt,
+		HashLength: 20, NextDomain: h, TypeBitMap: bitmap}, owner, nil
+}
+
+// memberBelow checks if there is a member of the chain below name. The
+// names below name follow it in canonical order.
+func (s *ZoneSigner) memberBelow(z *Zone, origin, name string) bool {
+	names := z.ordered()
+	for i := z.search(name); i < len(names) && IsSubDomain(name, names[i]); i++ {
+		if names[i] != name && s.member(z, origin, names[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pred returns the chain record before owner in the chain, or nil when
+// there is no other chain record.
+func (s *ZoneSigner) pred(z *Zone, origin, owner string) RR {
+	t := s.chainType()
+	names := z.ordered()
+	start := z.search(owner)
+	wrapped := false
+	for i := start - 1; ; i-- {
+		if i < 0 {
+			if wrapped {
+				return nil
+			}
+			i, wrapped = len(names)-1, true
+		}
+		if wrapped && i < start {
+			return nil
+		}
+		if r := chainRR(z, names[i], t); r != nil && names[i] != owner {
+			return r
+		}
+		// The NSEC3 records are all just below the apex: skip the rest of
+		// the names under the same name below the apex
+		labels := SplitLabels(names[i])
+		if k := len(labels) - CompareLabels(names[i], origin); t == TypeNSEC3 && k > 1 {
+			top := strings.Join(labels[k-1:], ".") + "."
+			if j := z.search(top); j < i {
+				i = j + 1
+			}
+		}
+	}
+}
+
+// unlink removes the chain record r, the record before it then points to
+// the record after it.
+func (s *ZoneSigner) unlink(z *Zone, origin string, r RR, log *[]zoneChange, dirty map[string]bool) {
+	owner := strings.ToLower(r.Header().Name)
+	z.remove(r)
+	*log = append(*log, zoneChange{r, false})
+	z.removeSigs(owner, r.Header().Rrtype, log)
+	delete(dirty, owner)
+	if p := s.pred(z, origin, owner); p != nil {
+		z.replace(p, setNext(p, nextOf(r)), log)
+		dirty[strings.ToLower(p.Header().Name)] = true
+	}
+}
+
+// link adds the chain record r after the record before it.
+func (s *ZoneSigner) link(z *Zone, origin string, r RR, log *[]zoneChange, dirty map[string]bool) {
+	owner := strings.ToLower(r.Header().Name)
+	next := chainName(r)
+	if p := s.pred(z, origin, owner); p != nil {
+		next = nextOf(p)
+		z.replace(p, setNext(p, chainName(r)), log)
+		dirty[strings.ToLower(p.Header().Name)] = true
+	}
+	r = setNext(r, next)
+	z.insert(r)
+	*log = append(*log, zoneChange{r, true})
+	dirty[owner] = true
+}
+
+// rebuildChain replaces the NSEC or NSEC3 chain of the zone with one made
+// anew, signing the new chain records.
+func (s *ZoneSigner) rebuildChain(z *Zone, log *[]zoneChange) error {
+	chain, err := s.chain(z.unsigned(), strings.ToLower(z.Origin))
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool)
+	for _, r := range chain {
+		want[rrKey(r)] = true
+	}
+	have := make(map[string]bool)
+	var obsolete []RR
+	for _, types := range z.names {
+		for _, t := range []uint16{TypeNSEC, TypeNSEC3, TypeNSEC3PARAM} {
+			for _, r := range types[t] {
+				have[rrKey(r)] = true
+				if !want[rrKey(r)] {
+					obsolete = append(obsolete, r)
+				}
+			}
+		}
+	}
+	for _, r := range obsolete {
+		z.remove(r)
+		*log = append(*log, zoneChange{r, false})
+		z.removeSigs(strings.ToLower(r.Header().Name), r.Header().Rrtype, log)
+	}
+	signer := z.soa().Hdr.Name
+	for _, r := range chain {
+		if have[rrKey(r)] {
+			continue
+		}
+		z.insert(r)
+		*log = append(*log, zoneChange{r, true})
+		sigs, err := s.sign(s.keys(r.Header().Rrtype), signer, []RR{r})
+		if err != nil {
+			return err
+		}
+		for _, sig := range sigs {
+			z.insert(sig)
+			*log = append(*log, zoneChange{sig, true})
+		}
+	}
+	return nil
+}
+
+// chainRR returns the NSEC or NSEC3 record, t, at name or nil.
+func chainRR(z *Zone, name string, t uint16) RR {
+	if rrs := z.names[name][t]; len(rrs) > 0 {
+		return rrs[0]
+	}
+	return nil
+}
+
+// chainName returns how the chain record r is named in the next field of
+// the record before it: the owner name for NSEC, the hash for NSEC3.
+func chainName(r RR) string {
+	name := strings.ToLower(r.Header().Name)
+	if r.Header().Rrtype == TypeNSEC3 {
+		return strings.ToUpper(SplitLabels(name)[0])
+	}
+	return name
+}
+
+func nextOf(r RR) string {
+	switch x := r.(type) {
+	case *RR_NSEC:
+		return x.NextDomain
+	case *RR_NSEC3:
+		return x.NextDomain
+	}
+	return ""
+}
+
+// setNext returns a copy of the chain record r with next as next name.
+func setNext(r RR, next string) RR {
+	switch x := r.(type) {
+	case *RR_NSEC:
+		y := *x
+		y.NextDomain = next
+		return &y
+	case *RR_NSEC3:
+		y := *x
+		y.NextDomain = next
+		return &y
+	}
+	return r
+}
+
+// unsignedTypes returns types without the DNSSEC records made by signing.
+func unsignedTypes(types map[uint16][]RR) map[uint16][]RR {
+	u := make(map[uint16][]RR, len(types))
+	for t, rrset := range types {
+		switch t {
+		case TypeRRSIG, TypeNSEC, TypeNSEC3, TypeNSEC3PARAM:
+			continue
+		}
+		u[t] = rrset
+	}
+	return u
+}
+
+// replace replaces r with r1, recording the changes in log.
+func (z *Zone) replace(r, r1 RR, log *[]zoneChange) {
+	z.remove(r)
+	*log = append(*log, zoneChange{r, false})
+	z.insert(r1)
+	*log = append(*log, zoneChange{r1, true})
+}
+
+// removeSigs removes the RRSIGs covering type t at name, recording the
+// changes in log.
+func (z *Zone) removeSigs(name string, t uint16, log *[]zoneChange) {
+	for _, r := range append([]RR{}, z.names[name][TypeRRSIG]...) {
+		if r.(*RR_RRSIG).TypeCovered == t {
+			z.remove(r)
+			*log = append(*log, zoneChange{r, false})
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package dns
+
+import (
+	"testing"
+	"time"
+)
+
+// signedTestZone signs signTestZone with s and loads it in a Zone.
+func signedTestZone(t *testing.T, s *ZoneSigner) *Zone {
+	rrs, err := s.Sign("miek.nl.", parseZoneString(t, signTestZone))
+	if err != nil {
+		t.Fatalf("Failed to sign the zone: %s", err)
+	}
+	z := NewZone("miek.nl.")
+	for _, r := range rrs {
+		z.Insert(r)
+	}
+	return z
+}
+
+// checkResigned checks that z is signed like a fresh signing of its
+// unsigned contents.
+func checkResigned(t *testing.T, s *ZoneSigner, z *Zone) {
+	rrs := z.RRs()
+	if errs := CheckZone("miek.nl.", rrs); len(errs) != 0 {
+		t.Fatalf("Re-signed zone has problems: %v", errs)
+	}
+	var unsigned []RR
+	for _, types := range z.unsigned() {
+		for _, rrset := range types {
+			unsigned = append(unsigned, rrset...)
+		}
+	}
+	fresh, err := s.Sign("miek.nl.", unsigned)
+	if err != nil {
+		t.Fatalf("Failed to sign the zone: %s", err)
+	}
+	zone, want := signedZone(rrs), signedZone(fresh)
+	for k, rrset := range want {
+		if k.sig {
+			if len(zone[k]) != len(rrset) {
+				t.Logf("Expected %d RRSIGs for %s %s, got %d", len(rrset), k.name, typeString(k.t), len(zone[k]))
+				t.Fail()
+			}
+			continue
+		}
+		switch k.t {
+		case TypeNSEC, TypeNSEC3, TypeNSEC3PARAM:
+			if len(zone[k]) != 1 || rrKey(zone[k][0]) != rrKey(rrset[0]) {
+				t.Logf("Expected %s, got %v", rrset[0], zone[k])
+				t.Fail()
+			}
+		}
+	}
+	for k, rrset := range zone {
+		if len(want[k]) == 0 {
+			t.Logf("Unexpected %s", rrset[0])
+			t.Fail()
+			continue
+		}
+		if !k.sig {
+			continue
+		}
+		for _, sig := range rrset {
+			key := s.ZSK[0].DNSKEY
+			if k.t == TypeDNSKEY && len(s.KSK) > 0 {
+				key = s.KSK[0].DNSKEY
+			}
+			if err := sig.(*RR_RRSIG).Verify(key, zone[signedKey{k.name, k.t, false}]); err != nil {
+				t.Logf("Failed to verify the RRSIG of %s %s: %s", k.name, typeString(k.t), err)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestZoneSignerSignDiff(t *testing.T) {
+	ksk, zsk := signTestKey(t, ZONE|SEP), signTestKey(t, ZONE)
+	signers := []*ZoneSigner{
+		{KSK: []*SigningKey{ksk}, ZSK: []*SigningKey{zsk}},
+		{KSK: []*SigningKey{ksk}, ZSK: []*SigningKey{zsk}, NSEC3: &RR_NSEC3PARAM{Hash: SHA1, Iterations: 1, Salt: "AABBCCDD"}},
+	}
+	for i, s := range signers {
+		z := signedTestZone(t, s)
+		u := NewUpdate("miek.nl.", ClassINET)
+		u.RRsetAddRdata([]RR{newRR(t, "new.miek.nl. 3600 IN A 127.0.0.5")})
+		add := u.Ns
+		u.RRsetDeleteRR([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.3")})
+		u.Ns = append(add, u.Ns...)
+		rcode, d := z.Update(wire(t, u), SerialIncrement)
+		if rcode != RcodeSuccess || d == nil {
+			t.Fatalf("Update failed: %s", Rcode_str[rcode])
+		}
+		sd, err := s.SignDiff(z, d)
+		if err != nil {
+			t.Fatalf("Failed to sign the difference: %s", err)
+		}
+		if sd.OldSoa != d.OldSoa || sd.NewSoa != d.NewSoa {
+			t.Fatal("SOAs of the difference changed")
+		}
+		if i == 0 {
+			// new A, www A, the SOA and the NSECs of new and insecure
+			n := 0
+			for _, r := range sd.Added {
+				if _, ok := r.(*RR_RRSIG); ok {
+					n++
+				}
+			}
+			if n != 5 {
+				t.Logf("Expected 5 new RRSIGs, got %d", n)
+				t.Fail()
+			}
+		}
+		checkResigned(t, s, z)
+
+		// Make www a delegation, its A record becomes glue
+		u = NewUpdate("miek.nl.", ClassINET)
+		u.RRsetAddRdata([]RR{newRR(t, "www.miek.nl. 3600 IN NS ns.example.org.")})
+		if _, d = z.Update(wire(t, u), SerialIncrement); d == nil {
+			t.Fatal("Update failed")
+		}
+		if _, err := s.SignDiff(z, d); err != nil {
+			t.Fatalf("Failed to sign the difference: %s", err)
+		}
+		for _, r := range z.RRset("www.miek.nl.", TypeRRSIG) {
+			if r.(*RR_RRSIG).TypeCovered != TypeNSEC {
+				t.Logf("Delegation is signed: %s", r)
+				t.Fail()
+			}
+		}
+		checkResigned(t, s, z)
+	}
+}
+
+// chainChanges counts the NSEC and NSEC3 records added and removed by d.
+func chainChanges(d *ZoneDiff) (added, removed int) {
+	for _, r := range d.Added {
+		if t := r.Header().Rrtype; t == TypeNSEC || t == TypeNSEC3 {
+			added++
+		}
+	}
+	for _, r := range d.Removed {
+		if t := r.Header().Rrtype; t == TypeNSEC || t == TypeNSEC3 {
+			removed++
+		}
+	}
+	return
+}
+
+func TestZoneSignerSignDiffChain(t *testing.T) {
+	ksk, zsk := signTestKey(t, ZONE|SEP), signTestKey(t, ZONE)
+	signers := []*ZoneSigner{
+		{KSK: []*SigningKey{ksk}, ZSK: []*SigningKey{zsk}},
+		{KSK: []*SigningKey{ksk}, ZSK: []*SigningKey{zsk}, NSEC3: &RR_NSEC3PARAM{Hash: SHA1, Iterations: 1, Salt: "AABBCCDD"}},
+	}
+	for i, s := range signers {
+		z := signedTestZone(t, s)
+		// The names in the chain: a.b.c.new and, for NSEC3, the empty
+		// non-terminals b.c.new, c.new and new
+		names := 1
+		if i == 1 {
+			names = 4
+		}
+		for _, c := range []struct {
+			add  bool
+			rr   string
+			grow int // the number of names the chain grows by
+		}{
+			{true, "a.b.c.new.miek.nl. 3600 IN A 127.0.0.5", names},
+			{true, "a.b.c.new.miek.nl. 3600 IN TXT \"bitmap\"", 0},
+			{false, "a.b.c.new.miek.nl. 3600 IN TXT \"bitmap\"", 0},
+			{false, "a.b.c.new.miek.nl. 3600 IN A 127.0.0.5", -names},
+		} {
+			u := NewUpdate("miek.nl.", ClassINET)
+			if c.add {
+				u.RRsetAddRdata([]RR{newRR(t, c.rr)})
+			} else {
+				u.RRsetDeleteRR([]RR{newRR(t, c.rr)})
+			}
+			_, d := z.Update(wire(t, u), SerialIncrement)
+			if d == nil {
+				t.Fatal("Update failed")
+			}
+			sd, err := s.SignDiff(z, d)
+			if err != nil {
+				t.Fatalf("Failed to sign the difference: %s", err)
+			}
+			// A new or removed name changes its own record and the one
+			// before it, a new type only the bitmap of its name
+			added, removed := chainChanges(sd)
+			ok := added-removed == c.grow && (added <= names || removed <= names)
+			if c.grow == 0 {
+				ok = added == 1 && removed == 1
+			}
+			if !ok {
+				t.Logf("%d: %v %s: %d chain records added, %d removed", i, c.add, c.rr, added, removed)
+				t.Fail()
+			}
+			checkResigned(t, s, z)
+		}
+	}
+}
+
+func TestZoneSignerResign(t *testing.T) {
+	zsk := signTestKey(t, ZONE|SEP)
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	s := &ZoneSigner{ZSK: []*SigningKey{zsk}, Clock: clock, Validity: 10 * 24 * time.Hour, Refresh: 2 * 24 * time.Hour}
+	z := signedTestZone(t, s)
+	if d, err := s.Resign(z); d != nil || err != nil {
+		t.Fatalf("Nothing should be re-signed, got %v %v", d, err)
+	}
+	sigs := make(map[string]bool)
+	for _, r := range z.RRs() {
+		if _, ok := r.(*RR_RRSIG); ok {
+			sigs[rrKey(r)] = true
+		}
+	}
+
+	clock.Advance(9 * 24 * time.Hour)
+	d, err := s.Resign(z)
+	if err != nil || d == nil {
+		t.Fatalf("Failed to re-sign: %v", err)
+	}
+	if d.NewSoa.Serial != d.OldSoa.Serial+1 || z.Soa().Serial != d.NewSoa.Serial {
+		t.Fatalf("Serial not increased: %d -> %d", d.OldSoa.Serial, d.NewSoa.Serial)
+	}
+	if len(d.Removed) != len(sigs) || len(d.Added) != len(sigs) {
+		t.Fatalf("Expected %d RRSIGs to be replaced, removed %d added %d", len(sigs), len(d.Removed), len(d.Added))
+	}
+	for _, r := range z.RRs() {
+		if sigs[rrKey(r)] {
+			t.Fatalf("Old signature still in the zone: %s", r)
+		}
+		if sig, ok := r.(*RR_RRSIG); ok && int64(sig.Expiration) != clock.Now().Unix()-3600+10*24*3600 {
+			t.Fatalf("Signature not refreshed: %s", r)
+		}
+	}
+	if d, err := s.Resign(z); d != nil || err != nil {
+		t.Fatalf("Nothing should be re-signed, got %v %v", d, err)
+	}
+}
@@ -60,14 +60,19 @@ const (
 // ParseError contains the parse error and the location in the io.Reader
 // where the error occured.
 type ParseError struct {
-	file string
-	err  string
-	lex  lex
+	file    string
+	err     string
+	lex     lex
+	include string // where file was $INCLUDE'd from: "file:line, ..."
 }
 
 func (e *ParseError) Error() (s string) {
 	if e.file != "" {
-		s = e.file + ": "
+		s = e.file
+		if e.include != "" {
+			s += " (included from " + e.include + ")"
+		}
+		s += ": "
 	}
 	s += "dns: " + e.err + ": " + strconv.QuoteToASCII(e.lex.token) + " at line: " +
 		strconv.Itoa(e.lex.line) + ":" + strconv.Itoa(e.lex.column)
@@ -102,11 +107,11 @@ func NewRR(s string) (RR, error) {
 // ReadRR reads the RR contained in q. Only the first RR is returned.
 // The class defaults to IN and TTL defaults to DefaultTtl.
 func ReadRR(q io.Reader, filename string) (RR, error) {
-	r := <-ParseZone(q, ".", filename)
-	if r.Error != nil {
-		return nil, r.Error
+	r, e := NewZoneParser(q, ".", filename).Next()
+	if e != nil {
+		return nil, e
 	}
-	return r.RR, nil
+	return r, nil
 }
 
 // ParseZone reads a RFC 1035 zone from r. It returns Tokens on the 
@@ -115,22 +120,141 @@ func ReadRR(q io.Reader, filename string) (RR, error) {
 // in error reporting. The string origin is used as the initial origin, as
 // if the file would start with: $ORIGIN origin 
 // The channel t is closed by ParseZone when the end of r is reached.
+// The channel must be read until it is closed, use a ZoneParser when
+// you want to stop early.
 func ParseZone(r io.Reader, origin, file string) chan Token {
 	t := make(chan Token)
-	go parseZone(r, origin, file, t, 0)
+	go parseZone(r, origin, file, t)
 	return t
 }
 
-func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
-	defer func() {
-		if include == 0 {
-			close(t)
+func parseZone(r io.Reader, origin, f string, t chan Token) {
+	defer close(t)
+	zp := NewZoneParser(r, origin, f)
+	for {
+		rr, e := zp.next()
+		if e != nil {
+			t <- Token{Error: e}
+			return
 		}
-	}()
-	s := scanInit(r)
-	c := make(chan lex)
-	// Start the lexer
-	go zlexer(s, c)
+		if rr == nil {
+			return
+		}
+		t <- Token{RR: rr}
+	}
+}
+
+// ZoneParser is a synchronous RFC 1035 zone parser. It parses one RR on
+// each call to Next, in the goroutine of the caller. Use it like:
+//
+//      zp := NewZoneParser(r, "miek.nl.", "")
+//      for rr, err := zp.Next(); err == nil; rr, err = zp.Next() {
+//              // Do something with rr
+//      }
+//
+// Unlike ParseZone, there is nothing to clean up when you stop early.
+//
+// By default parsing stops at the first error. When error recovery is
+// switched on with SetErrorRecovery the parser skips the offending line and
+// continues with the next one. All errors are then available from Errors.
+type ZoneParser struct {
+	c        *zlexer
+	f        string // file name, only used in error reporting
+	origin   string
+	st       int       // the state of the grammar
+	h        RR_Header // header of the RR being parsed
+	defttl   uint32
+	prevName string
+	include  int         // $INCLUDE nesting depth
+	from     string      // where we were $INCLUDE'd from: "file:line, ..."
+	sub      *ZoneParser // parser of the $INCLUDE'd file
+	subFile  *os.File
+	err      *ParseError // sticky error, once set parsing has stopped
+	errs     []*ParseError
+	recover  bool
+	seen     bool // true once an RR has been returned
+	done     bool
+}
+
+// NewZoneParser returns a ZoneParser that reads from r. The string file is
+// only used in error reporting. The string origin is used as the initial
+// origin, as if the file would start with: $ORIGIN origin
+func NewZoneParser(r io.Reader, origin, file string) *ZoneParser {
+	zp := &ZoneParser{c: newZLexer(scanInit(r)), f: file, st: _EXPECT_OWNER_DIR, defttl: DefaultTtl}
+	if origin == "" {
+		origin = "."
+	}
+	if !IsFqdn(origin) {
+		zp.err = &ParseError{file, "bad initial origin name", lex{}, ""}
+	}
+	if _, _, ok := IsDomainName(origin); !ok {
+		zp.err = &ParseError{file, "bad initial origin name", lex{}, ""}
+	}
+	zp.origin = origin
+	return zp
+}
+
+// SetErrorRecovery switches error recovery on or off. It should be called
+// before the first call to Next.
+func (zp *ZoneParser) SetErrorRecovery(b bool) {
+	zp.recover = b
+}
+
+// Errors returns the errors seen while parsing with error recovery switched on.
+// Errors in $INCLUDE'd files mention the file and line of the $INCLUDE.
+func (zp *ZoneParser) Errors() []*ParseError {
+	return zp.errs
+}
+
+// Next returns the next RR from the zone. When the zone is exhausted, io.EOF
+// is returned. Any other error is a *ParseError, after which parsing
+// stops: each following call returns the same error. With error recovery
+// switched on, Next only returns RRs and io.EOF.
+func (zp *ZoneParser) Next() (RR, error) {
+	rr, e := zp.next()
+	if e != nil {
+		return nil, e
+	}
+	if rr == nil {
+		return nil, io.EOF
+	}
+	return rr, nil
+}
+
+// next does the work for Next; the end of the zone is signaled with nil, nil.
+func (zp *ZoneParser) next() (RR, *ParseError) {
+	for {
+		rr, e := zp.parse()
+		if e == nil || !zp.recover {
+			return rr, e
+		}
+		zp.errs = append(zp.errs, e)
+		zp.err = nil
+		zp.resync()
+	}
+}
+
+// parse parses the next RR.
+func (zp *ZoneParser) parse() (RR, *ParseError) {
+	if zp.err != nil {
+		return nil, zp.err
+	}
+	if zp.done {
+		return nil, nil
+	}
+	if zp.sub != nil {
+		rr, e := zp.sub.next()
+		if e != nil {
+			zp.closeInclude()
+			zp.err = e
+			return nil, e
+		}
+		if rr != nil {
+			zp.seen = true
+			return rr, nil
+		}
+		zp.closeInclude()
+	}
 	// 6 possible beginnings of a line, _ is a space
 	// 0. _RRTYPE                              -> all omitted until the rrtype
 	// 1. _OWNER _ _RRTYPE                     -> class/ttl omitted
@@ -140,263 +264,266 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 	// 5. _OWNER _ _CLASS  _ _STRING _ _RRTYPE -> class/ttl (reversed)
 	// After detecting these, we know the _RRTYPE so we can jump to functions
 	// handling the rdata for each of these types.
-
-	if origin == "" {
-		origin = "."
-	}
-	if !IsFqdn(origin) {
-		t <- Token{Error: &ParseError{f, "bad initial origin name", lex{}}}
-		return
-	}
-	if _, _, ok := IsDomainName(origin); !ok {
-		t <- Token{Error: &ParseError{f, "bad initial origin name", lex{}}}
-		return
-	}
-	st := _EXPECT_OWNER_DIR // initial state
-	var h RR_Header
-	var defttl uint32 = DefaultTtl
-	var prevName string
-	for l := range c {
+	f := zp.f
+	h := &zp.h
+	for l, ok := zp.c.Next(); ok; l, ok = zp.c.Next() {
 		if _DEBUG {
 			fmt.Printf("[%v]\n", l)
 		}
 		// Lexer spotted an error already
 		if l.err == true {
-			t <- Token{Error: &ParseError{f, l.token, l}}
-			return
-
+			return zp.fail(&ParseError{f, l.token, l, ""})
 		}
-		switch st {
+		switch zp.st {
 		case _EXPECT_OWNER_DIR:
 			// We can also expect a directive, like $TTL or $ORIGIN
-			h.Ttl = defttl
+			h.Ttl = zp.defttl
 			h.Class = ClassINET
 			switch l.value {
 			case _NEWLINE: // Empty line
-				st = _EXPECT_OWNER_DIR
+				zp.st = _EXPECT_OWNER_DIR
 			case _OWNER:
 				h.Name = l.token
 				if l.token[0] == '@' {
-					h.Name = origin
-					prevName = h.Name
-					st = _EXPECT_OWNER_BL
+					h.Name = zp.origin
+					zp.prevName = h.Name
+					zp.st = _EXPECT_OWNER_BL
 					break
 				}
 				_, ld, ok := IsDomainName(l.token)
 				if !ok {
-					t <- Token{Error: &ParseError{f, "bad owner name", l}}
-					return
+					return zp.fail(&ParseError{f, "bad owner name", l, ""})
 				}
 				if h.Name[ld-1] != '.' {
-					h.Name = appendOrigin(h.Name, origin)
+					h.Name = appendOrigin(h.Name, zp.origin)
 				}
-				prevName = h.Name
-				st = _EXPECT_OWNER_BL
+				zp.prevName = h.Name
+				zp.st = _EXPECT_OWNER_BL
 			case _DIRTTL:
-				st = _EXPECT_DIRTTL_BL
+				zp.st = _EXPECT_DIRTTL_BL
 			case _DIRORIGIN:
-				st = _EXPECT_DIRORIGIN_BL
+				zp.st = _EXPECT_DIRORIGIN_BL
 			case _DIRINCLUDE:
-				st = _EXPECT_DIRINCLUDE_BL
+				zp.st = _EXPECT_DIRINCLUDE_BL
 			case _RRTYPE: // Everthing has been omitted, this is the first thing on the line
-				h.Name = prevName
+				h.Name = zp.prevName
 				h.Rrtype = l.torc
-				st = _EXPECT_RDATA
+				zp.st = _EXPECT_RDATA
 			case _CLASS: // First thing on the line is the class
-				h.Name = prevName
+				h.Name = zp.prevName
 				h.Class = l.torc
-				st = _EXPECT_ANY_NOCLASS_BL
+				zp.st = _EXPECT_ANY_NOCLASS_BL
 			case _BLANK:
 				// Discard, can happen when there is nothing on the
 				// line except the RR type
 			case _STRING: // First thing on the is the ttl
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
-					return
+					return zp.fail(&ParseError{f, "not a TTL", l, ""})
 				} else {
 					h.Ttl = ttl
-					defttl = ttl
+					zp.defttl = ttl
 				}
-				st = _EXPECT_ANY_NOTTL_BL
+				zp.st = _EXPECT_ANY_NOTTL_BL
 
 			default:
-				t <- Token{Error: &ParseError{f, "syntax error at beginning", l}}
-				return
+				return zp.fail(&ParseError{f, "syntax error at beginning", l, ""})
 			}
 		case _EXPECT_DIRINCLUDE_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $INCLUDE-directive", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank after $INCLUDE-directive", l, ""})
 			}
-			st = _EXPECT_DIRINCLUDE
+			zp.st = _EXPECT_DIRINCLUDE
 		case _EXPECT_DIRINCLUDE:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $INCLUDE value, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting $INCLUDE value, not this...", l, ""})
+			}
+			if e := slurpRemainder(zp.c, f); e != nil {
+				return zp.fail(e)
 			}
-			if e := slurpRemainder(c, f); e != nil {
-				t <- Token{Error: e}
+			if zp.include+1 > 7 {
+				return zp.fail(&ParseError{f, "too deeply nested $INCLUDE", l, ""})
 			}
 			// Start with the new file
 			r1, e1 := os.Open(l.token)
 			if e1 != nil {
-				t <- Token{Error: &ParseError{f, "failed to open `" + l.token + "'", l}}
-				return
+				return zp.fail(&ParseError{f, "failed to open `" + l.token + "'", l, ""})
 			}
-			if include+1 > 7 {
-				t <- Token{Error: &ParseError{f, "too deeply nested $INCLUDE", l}}
-				return
+			zp.st = _EXPECT_OWNER_DIR
+			zp.subFile = r1
+			zp.sub = NewZoneParser(r1, zp.origin, l.token)
+			zp.sub.include = zp.include + 1
+			zp.sub.recover = zp.recover
+			zp.sub.from = strconv.Itoa(l.line)
+			if f != "" {
+				zp.sub.from = f + ":" + zp.sub.from
+			}
+			if zp.from != "" {
+				zp.sub.from += ", " + zp.from
 			}
-			parseZone(r1, l.token, origin, t, include+1)
-			st = _EXPECT_OWNER_DIR
+			return zp.parse()
 		case _EXPECT_DIRTTL_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $TTL-directive", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank after $TTL-directive", l, ""})
 			}
-			st = _EXPECT_DIRTTL
+			zp.st = _EXPECT_DIRTTL
 		case _EXPECT_DIRTTL:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $TTL value, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting $TTL value, not this...", l, ""})
 			}
-			if e := slurpRemainder(c, f); e != nil {
-				t <- Token{Error: e}
-				return
+			if e := slurpRemainder(zp.c, f); e != nil {
+				return zp.fail(e)
 			}
 			if ttl, ok := stringToTtl(l.token); !ok {
-				t <- Token{Error: &ParseError{f, "expecting $TTL value, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting $TTL value, not this...", l, ""})
 			} else {
-				defttl = ttl
+				zp.defttl = ttl
 			}
-			st = _EXPECT_OWNER_DIR
+			zp.st = _EXPECT_OWNER_DIR
 		case _EXPECT_DIRORIGIN_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $ORIGIN-directive", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank after $ORIGIN-directive", l, ""})
 			}
-			st = _EXPECT_DIRORIGIN
+			zp.st = _EXPECT_DIRORIGIN
 		case _EXPECT_DIRORIGIN:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $ORIGIN value, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting $ORIGIN value, not this...", l, ""})
 			}
-			if e := slurpRemainder(c, f); e != nil {
-				t <- Token{Error: e}
+			if e := slurpRemainder(zp.c, f); e != nil {
+				return zp.fail(e)
 			}
 			if !IsFqdn(l.token) {
-				if origin != "." { // Prevent .. endings
-					origin = l.token + "." + origin
+				if zp.origin != "." { // Prevent .. endings
+					zp.origin = l.token + "." + zp.origin
 				} else {
-					origin = l.token + origin
+					zp.origin = l.token + zp.origin
 				}
 			} else {
-				origin = l.token
+				zp.origin = l.token
 			}
-			st = _EXPECT_OWNER_DIR
+			zp.st = _EXPECT_OWNER_DIR
 		case _EXPECT_OWNER_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after owner", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank after owner", l, ""})
 			}
-			st = _EXPECT_ANY
+			zp.st = _EXPECT_ANY
 		case _EXPECT_ANY:
 			switch l.value {
 			case _RRTYPE:
 				h.Rrtype = l.torc
-				st = _EXPECT_RDATA
+				zp.st = _EXPECT_RDATA
 			case _CLASS:
 				h.Class = l.torc
-				st = _EXPECT_ANY_NOCLASS_BL
+				zp.st = _EXPECT_ANY_NOCLASS_BL
 			case _STRING: // TTL is this case
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
-					return
+					return zp.fail(&ParseError{f, "not a TTL", l, ""})
 				} else {
 					h.Ttl = ttl
-					defttl = ttl
+					zp.defttl = ttl
 				}
-				st = _EXPECT_ANY_NOTTL_BL
+				zp.st = _EXPECT_ANY_NOTTL_BL
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type, TTL or class, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting RR type, TTL or class, not this...", l, ""})
 			}
 		case _EXPECT_ANY_NOCLASS_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before class", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank before class", l, ""})
 			}
-			st = _EXPECT_ANY_NOCLASS
+			zp.st = _EXPECT_ANY_NOCLASS
 		case _EXPECT_ANY_NOTTL_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before TTL", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank before TTL", l, ""})
 			}
-			st = _EXPECT_ANY_NOTTL
+			zp.st = _EXPECT_ANY_NOTTL
 		case _EXPECT_ANY_NOTTL:
 			switch l.value {
 			case _CLASS:
 				h.Class = l.torc
-				st = _EXPECT_RRTYPE_BL
+				zp.st = _EXPECT_RRTYPE_BL
 			case _RRTYPE:
 				h.Rrtype = l.torc
-				st = _EXPECT_RDATA
+				zp.st = _EXPECT_RDATA
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type or class, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting RR type or class, not this...", l, ""})
 			}
 		case _EXPECT_ANY_NOCLASS:
 			switch l.value {
 			case _STRING: // TTL
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
-					return
+					return zp.fail(&ParseError{f, "not a TTL", l, ""})
 				} else {
 					h.Ttl = ttl
-					defttl = ttl
+					zp.defttl = ttl
 				}
-				st = _EXPECT_RRTYPE_BL
+				zp.st = _EXPECT_RRTYPE_BL
 			case _RRTYPE:
 				h.Rrtype = l.torc
-				st = _EXPECT_RDATA
+				zp.st = _EXPECT_RDATA
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type or TTL, not this...", l}}
-				return
+				return zp.fail(&ParseError{f, "expecting RR type or TTL, not this...", l, ""})
 			}
 		case _EXPECT_RRTYPE_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before RR type", l}}
-				return
+				return zp.fail(&ParseError{f, "no blank before RR type", l, ""})
 			}
-			st = _EXPECT_RRTYPE
+			zp.st = _EXPECT_RRTYPE
 		case _EXPECT_RRTYPE:
 			if l.value != _RRTYPE {
-				t <- Token{Error: &ParseError{f, "unknown RR type", l}}
-				return
+				return zp.fail(&ParseError{f, "unknown RR type", l, ""})
 			}
 			h.Rrtype = l.torc
-			st = _EXPECT_RDATA
+			zp.st = _EXPECT_RDATA
 		case _EXPECT_RDATA:
 			// I could save my token here...? l
-			r, e := setRR(h, c, origin, f)
+			r, e := setRR(*h, zp.c, zp.origin, f)
 			if e != nil {
 				// If e.lex is nil than we have encounter a unknown RR type
 				// in that case we substitute our current lex token
 				if e.lex.token == "" && e.lex.value == 0 {
 					e.lex = l // Uh, dirty
 				}
-				t <- Token{Error: e}
-				return
+				return zp.fail(e)
 			}
-			t <- Token{RR: r}
-			st = _EXPECT_OWNER_DIR
+			zp.st = _EXPECT_OWNER_DIR
+			zp.seen = true
+			return r, nil
 		}
 	}
-	// If we get here, we and the h.Rrtype is still zero, we haven't parsed anything
-	if h.Rrtype == 0 {
-		t <- Token{Error: &ParseError{f, "nothing made sense", lex{}}}
+	zp.done = true
+	// If we get here and haven't seen a single RR (or error), we haven't parsed anything
+	if !zp.seen && len(zp.errs) == 0 {
+		return zp.fail(&ParseError{f, "nothing made sense", lex{}, ""})
 	}
+	return nil, nil
+}
+
+// fail records e as the sticky error of the parser and returns it.
+func (zp *ZoneParser) fail(e *ParseError) (RR, *ParseError) {
+	e.include = zp.from
+	zp.err = e
+	return nil, e
+}
+
+// resync skips the remainder of the current line (which may span multiple
+// lines when braces are used), so parsing can continue on the next one.
+func (zp *ZoneParser) resync() {
+	zp.st = _EXPECT_OWNER_DIR
+	if zp.c.nl {
+		return
+	}
+	for l, ok := zp.c.Next(); ok; l, ok = zp.c.Next() {
+		if l.value == _NEWLINE && !l.err {
+			return
+		}
+	}
+}
+
+// closeInclude closes the file opened for an $INCLUDE.
+func (zp *ZoneParser) closeInclude() {
+	zp.errs = append(zp.errs, zp.sub.errs...)
+	zp.subFile.Close()
+	zp.subFile = nil
+	zp.sub = nil
 }
 
 func (l lex) _string() string {
@@ -425,257 +552,296 @@ func (l lex) _string() string {
 	return "**"
 }
 
-// zlexer scans the sourcefile and returns tokens on the channel c.
-func zlexer(s *scan, c chan lex) {
-	var l lex
-	str := make([]byte, maxTok) // Should be enough for any token
-	stri := 0                   // Offset in str (0 means empty)
-	quote := false
-	escape := false
-	space := false
-	commt := false
-	rrtype := false
-	owner := true
-	brace := 0
-	x, err := s.tokenText()
-	defer close(c)
-	for err == nil {
-		l.column = s.position.Column
-		l.line = s.position.Line
-		if stri > maxTok {
-			l.token = "tok length insufficient for parsing"
-			l.err = true
-			c <- l
-			return
+// zlexer scans the sourcefile and hands out the tokens one by one.
+type zlexer struct {
+	s      *scan
+	l      lex    // the token being built
+	str    []byte // Should be enough for any token
+	stri   int    // Offset in str (0 means empty)
+	quote  bool
+	escape bool
+	space  bool
+	commt  bool
+	rrtype bool
+	owner  bool
+	brace  int
+	tok    []lex // tokens ready to be handed out
+	toki   int   // offset of the next token in tok
+	eof    bool  // nothing more to read from s
+	nl     bool  // the last token handed out was a _NEWLINE
+}
+
+func newZLexer(s *scan) *zlexer {
+	return &zlexer{s: s, str: make([]byte, maxTok), owner: true, tok: make([]lex, 0, 4)}
+}
+
+// Next returns the next token. When the input is exhausted, false is returned.
+func (zl *zlexer) Next() (lex, bool) {
+	for zl.toki == len(zl.tok) {
+		if zl.eof {
+			return lex{}, false
 		}
+		zl.tok = zl.tok[:0]
+		zl.toki = 0
+		x, err := zl.s.tokenText()
+		if err != nil {
+			zl.eof = true
+			// Hmm.
+			if zl.stri > 0 {
+				// Send remainder
+				zl.l.token = string(zl.str[:zl.stri])
+				zl.l.value = _STRING
+				zl.emit()
+			}
+			continue
+		}
+		zl.lexByte(x)
+	}
+	zl.toki++
+	zl.nl = zl.tok[zl.toki-1].value == _NEWLINE && !zl.tok[zl.toki-1].err
+	return zl.tok[zl.toki-1], true
+}
 
-		switch x {
-		case ' ', '\t':
-			if quote {
-				// Inside quotes this is legal
-				str[stri] = x
-				stri++
-				break
-			}
-			escape = false
-			if commt {
-				break
-			}
-			if stri == 0 {
-				// Space directly as the beginnin, handled in the grammar
-			} else if owner {
-				// If we have a string and its the first, make it an owner
-				l.value = _OWNER
-				l.token = string(str[:stri])
-				// escape $... start with a \ not a $, so this will work
-				switch l.token {
-				case "$TTL":
-					l.value = _DIRTTL
-				case "$ORIGIN":
-					l.value = _DIRORIGIN
-				case "$INCLUDE":
-					l.value = _DIRINCLUDE
-				}
-				c <- l
-			} else {
-				l.value = _STRING
-				l.token = string(str[:stri])
+// emit queues the current token.
+func (zl *zlexer) emit() {
+	zl.tok = append(zl.tok, zl.l)
+	zl.l.err = false
+}
 
-				if !rrtype {
-					if t, ok := Str_rr[strings.ToUpper(l.token)]; ok {
-						l.value = _RRTYPE
-						l.torc = t
-						rrtype = true
-					} else {
-						if strings.HasPrefix("TYPE", l.token) {
-							if t, ok := typeToInt(l.token); !ok {
-								l.token = "unknown RR type"
-								l.err = true
-								c <- l
-								return
-							} else {
-								l.value = _RRTYPE
-								l.torc = t
-							}
-						}
-					}
-					if t, ok := Str_class[strings.ToUpper(l.token)]; ok {
-						l.value = _CLASS
-						l.torc = t
-					} else {
-						if strings.HasPrefix("CLASS", l.token) {
-							if t, ok := classToInt(l.token); !ok {
-								l.token = "unknown class"
-								l.err = true
-								c <- l
-								return
-							} else {
-								l.value = _CLASS
-								l.torc = t
-							}
+// lexByte feeds x to the lexer. When the lexer spots an error, an error
+// token is queued and lexing continues with the next token.
+func (zl *zlexer) lexByte(x byte) {
+	l := &zl.l
+	l.column = zl.s.position.Column
+	l.line = zl.s.position.Line
+	if zl.stri >= maxTok {
+		l.token = "tok length insufficient for parsing"
+		l.err = true
+		zl.emit()
+		zl.stri = 0
+		return
+	}
+
+	switch x {
+	case ' ', '\t':
+		if zl.quote {
+			// Inside quotes this is legal
+			zl.str[zl.stri] = x
+			zl.stri++
+			break
+		}
+		zl.escape = false
+		if zl.commt {
+			break
+		}
+		if zl.stri == 0 {
+			// Space directly as the beginnin, handled in the grammar
+		} else if zl.owner {
+			// If we have a string and its the first, make it an owner
+			l.value = _OWNER
+			l.token = string(zl.str[:zl.stri])
+			// escape $... start with a \ not a $, so this will work
+			switch l.token {
+			case "$TTL":
+				l.value = _DIRTTL
+			case "$ORIGIN":
+				l.value = _DIRORIGIN
+			case "$INCLUDE":
+				l.value = _DIRINCLUDE
+			}
+			zl.emit()
+		} else {
+			l.value = _STRING
+			l.token = string(zl.str[:zl.stri])
+
+			if !zl.rrtype {
+				if t, ok := Str_rr[strings.ToUpper(l.token)]; ok {
+					l.value = _RRTYPE
+					l.torc = t
+					zl.rrtype = true
+				} else {
+					if strings.HasPrefix("TYPE", l.token) {
+						if t, ok := typeToInt(l.token); !ok {
+							l.token = "unknown RR type"
+							l.err = true
+							zl.emit()
+							zl.stri = 0
+							return
+						} else {
+							l.value = _RRTYPE
+							l.torc = t
 						}
 					}
 				}
-				c <- l
-			}
-			stri = 0
-			// I reverse space stuff here
-			if !space && !commt {
-				l.value = _BLANK
-				l.token = " "
-				c <- l
-			}
-			owner = false
-			space = true
-		case ';':
-			if quote {
-				// Inside quotes this is legal
-				str[stri] = x
-				stri++
-				break
-			}
-			if escape {
-				escape = false
-				str[stri] = x
-				stri++
-				break
-			}
-			if stri > 0 {
-				l.value = _STRING
-				l.token = string(str[:stri])
-				c <- l
-				stri = 0
-			}
-			commt = true
-		case '\r':
-			// discard
-			// this means it can also not be used as rdata
-		case '\n':
-			// Escaped newline
-			if quote {
-				str[stri] = x
-				stri++
-				break
-			}
-			// inside quotes this is legal
-			escape = false
-			if commt {
-				// Reset a comment
-				commt = false
-				rrtype = false
-				stri = 0
-				// If not in a brace this ends the comment AND the RR
-				if brace == 0 {
-					owner = true
-					owner = true
-					l.value = _NEWLINE
-					l.token = "\n"
-					c <- l
-				}
-				break
-			}
-
-			if brace == 0 {
-				// If there is previous text, we should output it here
-				if stri != 0 {
-					l.value = _STRING
-					l.token = string(str[:stri])
-					if !rrtype {
-						if _, ok := Str_rr[strings.ToUpper(l.token)]; ok {
-							l.value = _RRTYPE
-							rrtype = true
+				if t, ok := Str_class[strings.ToUpper(l.token)]; ok {
+					l.value = _CLASS
+					l.torc = t
+				} else {
+					if strings.HasPrefix("CLASS", l.token) {
+						if t, ok := classToInt(l.token); !ok {
+							l.token = "unknown class"
+							l.err = true
+							zl.emit()
+							zl.stri = 0
+							return
+						} else {
+							l.value = _CLASS
+							l.torc = t
 						}
 					}
-					c <- l
 				}
+			}
+			zl.emit()
+		}
+		zl.stri = 0
+		// I reverse space stuff here
+		if !zl.space && !zl.commt {
+			l.value = _BLANK
+			l.token = " "
+			zl.emit()
+		}
+		zl.owner = false
+		zl.space = true
+	case ';':
+		if zl.quote {
+			// Inside quotes this is legal
+			zl.str[zl.stri] = x
+			zl.stri++
+			break
+		}
+		if zl.escape {
+			zl.escape = false
+			zl.str[zl.stri] = x
+			zl.stri++
+			break
+		}
+		if zl.stri > 0 {
+			l.value = _STRING
+			l.token = string(zl.str[:zl.stri])
+			zl.emit()
+			zl.stri = 0
+		}
+		zl.commt = true
+	case '\r':
+		// discard
+		// this means it can also not be used as rdata
+	case '\n':
+		// Escaped newline
+		if zl.quote {
+			zl.str[zl.stri] = x
+			zl.stri++
+			break
+		}
+		// inside quotes this is legal
+		zl.escape = false
+		if zl.commt {
+			// Reset a comment
+			zl.commt = false
+			zl.rrtype = false
+			zl.stri = 0
+			// If not in a brace this ends the comment AND the RR
+			if zl.brace == 0 {
+				zl.owner = true
 				l.value = _NEWLINE
 				l.token = "\n"
-				c <- l
-				stri = 0
-				commt = false
-				rrtype = false
-				owner = true
+				zl.emit()
 			}
-		case '\\':
-			// quote?
-			if commt {
-				break
-			}
-			if escape {
-				str[stri] = x
-				stri++
-				escape = false
-				break
-			}
-			str[stri] = x
-			stri++
-			escape = true
-		case '"':
-			if commt {
-				break
-			}
-			if escape {
-				str[stri] = x
-				stri++
-				escape = false
-				break
-			}
-			space = false
-			// send previous gathered text and the quote
-			if stri != 0 {
+			break
+		}
+
+		if zl.brace == 0 {
+			// If there is previous text, we should output it here
+			if zl.stri != 0 {
 				l.value = _STRING
-				l.token = string(str[:stri])
-				c <- l
-				stri = 0
-			}
-			l.value = _QUOTE
-			l.token = "\""
-			c <- l
-			quote = !quote
-		case '(', ')':
-			if quote {
-				str[stri] = x
-				stri++
-				break
-			}
-			if commt {
-				break
-			}
-			if escape {
-				str[stri] = x
-				stri++
-				escape = false
-				break
-			}
-			switch x {
-			case ')':
-				brace--
-				if brace < 0 {
-					l.token = "extra closing brace"
-					l.err = true
-					c <- l
-					return
+				l.token = string(zl.str[:zl.stri])
+				if !zl.rrtype {
+					if _, ok := Str_rr[strings.ToUpper(l.token)]; ok {
+						l.value = _RRTYPE
+						zl.rrtype = true
+					}
 				}
-			case '(':
-				brace++
-			}
-		default:
-			if commt {
-				break
+				zl.emit()
+			}
+			l.value = _NEWLINE
+			l.token = "\n"
+			zl.emit()
+			zl.stri = 0
+			zl.commt = false
+			zl.rrtype = false
+			zl.owner = true
+		}
+	case '\\':
+		// quote?
+		if zl.commt {
+			break
+		}
+		if zl.escape {
+			zl.str[zl.stri] = x
+			zl.stri++
+			zl.escape = false
+			break
+		}
+		zl.str[zl.stri] = x
+		zl.stri++
+		zl.escape = true
+	case '"':
+		if zl.commt {
+			break
+		}
+		if zl.escape {
+			zl.str[zl.stri] = x
+			zl.stri++
+			zl.escape = false
+			break
+		}
+		zl.space = false
+		// send previous gathered text and the quote
+		if zl.stri != 0 {
+			l.value = _STRING
+			l.token = string(zl.str[:zl.stri])
+			zl.emit()
+			zl.stri = 0
+		}
+		l.value = _QUOTE
+		l.token = "\""
+		zl.emit()
+		zl.quote = !zl.quote
+	case '(', ')':
+		if zl.quote {
+			zl.str[zl.stri] = x
+			zl.stri++
+			break
+		}
+		if zl.commt {
+			break
+		}
+		if zl.escape {
+			zl.str[zl.stri] = x
+			zl.stri++
+			zl.escape = false
+			break
+		}
+		switch x {
+		case ')':
+			zl.brace--
+			if zl.brace < 0 {
+				l.token = "extra closing brace"
+				l.err = true
+				zl.emit()
+				zl.brace = 0
+				return
 			}
-			escape = false
-			str[stri] = x
-			stri++
-			space = false
+		case '(':
+			zl.brace++
 		}
-		x, err = s.tokenText()
-	}
-	// Hmm.
-	if stri > 0 {
-		// Send remainder
-		l.token = string(str[:stri])
-		l.value = _STRING
-		c <- l
+	default:
+		if zl.commt {
+			break
+		}
+		zl.escape = false
+		zl.str[zl.stri] = x
+		zl.stri++
+		zl.space = false
 	}
 }
 
@@ -800,13 +966,13 @@ func locCheckEast(token string, longitude uint32) (uint32, bool) {
 	return longitude, false
 }
 
-func slurpRemainder(c chan lex, f string) *ParseError {
-	l := <-c
+func slurpRemainder(c *zlexer, f string) *ParseError {
+	l, _ := c.Next()
 	switch l.value {
 	case _BLANK:
-		l = <-c
+		l, _ = c.Next()
 		if l.value != _NEWLINE && l.value != _EOF {
-			return &ParseError{f, "garbage after rdata", l}
+			return &ParseError{f, "garbage after rdata", l, ""}
 		}
 		// Ok
 	case _NEWLINE:
@@ -814,7 +980,7 @@ func slurpRemainder(c chan lex, f string) *ParseError {
 	case _EOF:
 		// Ok
 	default:
-		return &ParseError{f, "garbage after rdata", l}
+		return &ParseError{f, "garbage after rdata", l, ""}
 	}
 	return nil
 }
@@ -12,7 +12,7 @@ import (
 // After the rdata there may come 1 _BLANK and then a _NEWLINE
 // or immediately a _NEWLINE. If this is not the case we flag
 // an *ParseError: garbage after rdata.
-func setRR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setRR(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	var r RR
 	e := new(ParseError)
 	switch h.Rrtype {
@@ -102,39 +102,39 @@ Slurp:
 	return r, e
 }
 
-func setA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setA(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_A)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.A = net.ParseIP(l.token)
 	if rr.A == nil {
-		return nil, &ParseError{f, "bad A A", l}
+		return nil, &ParseError{f, "bad A A", l, ""}
 	}
 	return rr, nil
 }
 
-func setAAAA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setAAAA(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_AAAA)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.AAAA = net.ParseIP(l.token)
 	if rr.AAAA == nil {
-		return nil, &ParseError{f, "bad AAAA AAAA", l}
+		return nil, &ParseError{f, "bad AAAA AAAA", l, ""}
 	}
 	return rr, nil
 }
 
-func setNS(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setNS(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_NS)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Ns = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad NS Ns", l}
+		return nil, &ParseError{f, "bad NS Ns", l, ""}
 	}
 	if rr.Ns[ld-1] != '.' {
 		rr.Ns = appendOrigin(rr.Ns, o)
@@ -142,15 +142,15 @@ func setNS(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setPTR(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_PTR)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Ptr = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad PTR Ptr", l}
+		return nil, &ParseError{f, "bad PTR Ptr", l, ""}
 	}
 	if rr.Ptr[ld-1] != '.' {
 		rr.Ptr = appendOrigin(rr.Ptr, o)
@@ -158,25 +158,25 @@ func setPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setRP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setRP(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_RP)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Mbox = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad RP Mbox", l}
+		return nil, &ParseError{f, "bad RP Mbox", l, ""}
 	}
 	if rr.Mbox[ld-1] != '.' {
 		rr.Mbox = appendOrigin(rr.Mbox, o)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	rr.Txt = l.token
 	_, ld, ok = IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad RP Txt", l}
+		return nil, &ParseError{f, "bad RP Txt", l, ""}
 	}
 	if rr.Txt[ld-1] != '.' {
 		rr.Txt = appendOrigin(rr.Txt, o)
@@ -185,22 +185,22 @@ func setRP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setMX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setMX(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_MX)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad MX Pref", l}
+		return nil, &ParseError{f, "bad MX Pref", l, ""}
 	} else {
 		rr.Pref = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	rr.Mx = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MX Mx", l}
+		return nil, &ParseError{f, "bad MX Mx", l, ""}
 	}
 	if rr.Mx[ld-1] != '.' {
 		rr.Mx = appendOrigin(rr.Mx, o)
@@ -208,15 +208,15 @@ func setMX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setCNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setCNAME(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_CNAME)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Target = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad CNAME Target", l}
+		return nil, &ParseError{f, "bad CNAME Target", l, ""}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -224,15 +224,15 @@ func setCNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setDNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setDNAME(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_DNAME)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Target = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad CNAME Target", l}
+		return nil, &ParseError{f, "bad CNAME Target", l, ""}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -240,42 +240,42 @@ func setDNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setSOA(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_SOA)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.Ns = l.token
-	<-c // _BLANK
+	c.Next() // _BLANK
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad SOA Ns", l}
+		return nil, &ParseError{f, "bad SOA Ns", l, ""}
 	}
 	if rr.Ns[ld-1] != '.' {
 		rr.Ns = appendOrigin(rr.Ns, o)
 	}
 
-	l = <-c
+	l, _ = c.Next()
 	rr.Mbox = l.token
 	_, ld, ok = IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad SOA Mbox", l}
+		return nil, &ParseError{f, "bad SOA Mbox", l, ""}
 	}
 	if rr.Mbox[ld-1] != '.' {
 		rr.Mbox = appendOrigin(rr.Mbox, o)
 	}
-	<-c // _BLANK
+	c.Next() // _BLANK
 
 	var v uint32
 	for i := 0; i < 5; i++ {
-		l = <-c
+		l, _ = c.Next()
 		if j, e := strconv.Atoi(l.token); e != nil {
 			if i == 0 {
 				// Serial should be a number
-				return nil, &ParseError{f, "bad SOA zone parameter", l}
+				return nil, &ParseError{f, "bad SOA zone parameter", l, ""}
 			}
 			if v, ok = stringToTtl(l.token); !ok {
-				return nil, &ParseError{f, "bad SOA zone parameter", l}
+				return nil, &ParseError{f, "bad SOA zone parameter", l, ""}
 
 			}
 		} else {
@@ -284,16 +284,16 @@ func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		switch i {
 		case 0:
 			rr.Serial = v
-			<-c // _BLANK
+			c.Next() // _BLANK
 		case 1:
 			rr.Refresh = v
-			<-c // _BLANK
+			c.Next() // _BLANK
 		case 2:
 			rr.Retry = v
-			<-c // _BLANK
+			c.Next() // _BLANK
 		case 3:
 			rr.Expire = v
-			<-c // _BLANK
+			c.Next() // _BLANK
 		case 4:
 			rr.Minttl = v
 		}
@@ -301,36 +301,36 @@ func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setSRV(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setSRV(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_SRV)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Priority", l}
+		return nil, &ParseError{f, "bad SRV Priority", l, ""}
 	} else {
 		rr.Priority = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Weight", l}
+		return nil, &ParseError{f, "bad SRV Weight", l, ""}
 	} else {
 		rr.Weight = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Port", l}
+		return nil, &ParseError{f, "bad SRV Port", l, ""}
 	} else {
 		rr.Port = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	rr.Target = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad SRV Target", l}
+		return nil, &ParseError{f, "bad SRV Target", l, ""}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -338,86 +338,86 @@ func setSRV(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setNAPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setNAPTR(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_NAPTR)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Order", l}
+		return nil, &ParseError{f, "bad NAPTR Order", l, ""}
 	} else {
 		rr.Order = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Preference", l}
+		return nil, &ParseError{f, "bad NAPTR Preference", l, ""}
 	} else {
 		rr.Preference = uint16(i)
 	}
 	// Flags
-	<-c     // _BLANK
-	l = <-c // _QUOTE
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Flags", l}
+		return nil, &ParseError{f, "bad NAPTR Flags", l, ""}
 	}
-	l = <-c // Either String or Quote
+	l, _ = c.Next() // Either String or Quote
 	if l.value == _STRING {
 		rr.Flags = l.token
-		l = <-c // _QUOTE
+		l, _ = c.Next() // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Flags", l}
+			return nil, &ParseError{f, "bad NAPTR Flags", l, ""}
 		}
 	} else if l.value == _QUOTE {
 		rr.Flags = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Flags", l}
+		return nil, &ParseError{f, "bad NAPTR Flags", l, ""}
 	}
 
 	// Service
-	<-c     // _BLANK
-	l = <-c // _QUOTE
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Service", l}
+		return nil, &ParseError{f, "bad NAPTR Service", l, ""}
 	}
-	l = <-c // Either String or Quote
+	l, _ = c.Next() // Either String or Quote
 	if l.value == _STRING {
 		rr.Service = l.token
-		l = <-c // _QUOTE
+		l, _ = c.Next() // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Service", l}
+			return nil, &ParseError{f, "bad NAPTR Service", l, ""}
 		}
 	} else if l.value == _QUOTE {
 		rr.Service = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Service", l}
+		return nil, &ParseError{f, "bad NAPTR Service", l, ""}
 	}
 
 	// Regexp
-	<-c     // _BLANK
-	l = <-c // _QUOTE
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Regexp", l}
+		return nil, &ParseError{f, "bad NAPTR Regexp", l, ""}
 	}
-	l = <-c // Either String or Quote
+	l, _ = c.Next() // Either String or Quote
 	if l.value == _STRING {
 		rr.Regexp = l.token
-		l = <-c // _QUOTE
+		l, _ = c.Next() // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Regexp", l}
+			return nil, &ParseError{f, "bad NAPTR Regexp", l, ""}
 		}
 	} else if l.value == _QUOTE {
 		rr.Regexp = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Regexp", l}
+		return nil, &ParseError{f, "bad NAPTR Regexp", l, ""}
 	}
 	// After quote no space??
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	rr.Replacement = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad NAPTR Replacement", l}
+		return nil, &ParseError{f, "bad NAPTR Replacement", l, ""}
 	}
 	if rr.Replacement[ld-1] != '.' {
 		rr.Replacement = appendOrigin(rr.Replacement, o)
@@ -425,25 +425,25 @@ func setNAPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setTALINK(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setTALINK(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_TALINK)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.PreviousName = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad TALINK PreviousName", l}
+		return nil, &ParseError{f, "bad TALINK PreviousName", l, ""}
 	}
 	if rr.PreviousName[ld-1] != '.' {
 		rr.PreviousName = appendOrigin(rr.PreviousName, o)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	rr.NextName = l.token
 	_, ld, ok = IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad TALINK NextName", l}
+		return nil, &ParseError{f, "bad TALINK NextName", l, ""}
 	}
 	if rr.NextName[ld-1] != '.' {
 		rr.NextName = appendOrigin(rr.NextName, o)
@@ -451,7 +451,7 @@ func setTALINK(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
-func setLOC(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setLOC(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_LOC)
 	rr.Hdr = h
 	// Non zero defaults for LOC record, see RFC 1876, Section 3.
@@ -460,89 +460,89 @@ func setLOC(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Size = 18		// 1
 	ok := false
 	// North
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude", l}
+		return nil, &ParseError{f, "bad LOC Latitude", l, ""}
 	} else {
 		rr.Latitude = 1000 * 60 * 60 * uint32(i)
 	}
-	<-c // _BLANK
+	c.Next() // _BLANK
 	// Either number, 'N' or 'S'
-	l = <-c
+	l, _ = c.Next()
 	if rr.Latitude, ok = locCheckNorth(l.token, rr.Latitude); ok {
 		goto East
 	}
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude minutes", l}
+		return nil, &ParseError{f, "bad LOC Latitude minutes", l, ""}
 	} else {
 		rr.Latitude += 1000 * 60 * uint32(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude seconds", l}
+		return nil, &ParseError{f, "bad LOC Latitude seconds", l, ""}
 	} else {
 		rr.Latitude += uint32(1000 * i)
 	}
-	<-c // _BLANK
+	c.Next() // _BLANK
 	// Either number, 'N' or 'S'
-	l = <-c
+	l, _ = c.Next()
 	if rr.Latitude, ok = locCheckNorth(l.token, rr.Latitude); ok {
 		goto East
 	}
 	// If still alive, flag an error
-	return nil, &ParseError{f, "bad LOC Latitude North/South", l}
+	return nil, &ParseError{f, "bad LOC Latitude North/South", l, ""}
 
 East:
 	// East
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude", l}
+		return nil, &ParseError{f, "bad LOC Longitude", l, ""}
 	} else {
 		rr.Longitude = 1000 * 60 * 60 * uint32(i)
 	}
-	<-c // _BLANK
+	c.Next() // _BLANK
 	// Either number, 'E' or 'W'
-	l = <-c
+	l, _ = c.Next()
 	if rr.Longitude, ok = locCheckEast(l.token, rr.Longitude); ok {
 		goto Altitude
 	}
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude minutes", l}
+		return nil, &ParseError{f, "bad LOC Longitude minutes", l, ""}
 	} else {
 		rr.Longitude += 1000 * 60 * uint32(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude seconds", l}
+		return nil, &ParseError{f, "bad LOC Longitude seconds", l, ""}
 	} else {
 		rr.Longitude += uint32(1000 * i)
 	}
-	<-c // _BLANK
+	c.Next() // _BLANK
 	// Either number, 'E' or 'W'
-	l = <-c
+	l, _ = c.Next()
 	if rr.Longitude, ok = locCheckEast(l.token, rr.Longitude); ok {
 		goto Altitude
 	}
 	// If still alive, flag an error
-	return nil, &ParseError{f, "bad LOC Longitude East/West", l}
+	return nil, &ParseError{f, "bad LOC Longitude East/West", l, ""}
 
 Altitude:
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if l.token[len(l.token)-1] == 'M' || l.token[len(l.token)-1] == 'm' {
 		l.token = l.token[0 : len(l.token)-1]
 	}
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Altitude", l}
+		return nil, &ParseError{f, "bad LOC Altitude", l, ""}
 	} else {
 		rr.Altitude = uint32(i*100.0 + 10000000.0 + 0.5)
 	}
 
 	// And now optionally the other values
-	l = <-c
+	l, _ = c.Next()
 	count := 0
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -550,19 +550,19 @@ Altitude:
 			switch count {
 			case 0: // Size
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC Size", l}
+					return nil, &ParseError{f, "bad LOC Size", l, ""}
 				} else {
 					rr.Size = (e & 0x0f) | (m << 4 & 0xf0)
 				}
 			case 1: // HorizPre
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC HorizPre", l}
+					return nil, &ParseError{f, "bad LOC HorizPre", l, ""}
 				} else {
 					rr.HorizPre = (e & 0x0f) | (m << 4 & 0xf0)
 				}
 			case 2: // VertPre
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC VertPre", l}
+					return nil, &ParseError{f, "bad LOC VertPre", l, ""}
 				} else {
 					rr.VertPre = (e & 0x0f) | (m << 4 & 0xf0)
 				}
@@ -571,43 +571,43 @@ Altitude:
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad LOC Size, HorizPre or VertPre", l}
+			return nil, &ParseError{f, "bad LOC Size, HorizPre or VertPre", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	return rr, nil
 }
 
-func setHIP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setHIP(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_HIP)
 	rr.Hdr = h
 
 	// HitLength is not represented
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad HIP PublicKeyAlgorithm", l}
+		return nil, &ParseError{f, "bad HIP PublicKeyAlgorithm", l, ""}
 	} else {
 		rr.PublicKeyAlgorithm = uint8(i)
 	}
-	<-c              // _BLANK
-	l = <-c          // _STRING
+	c.Next()              // _BLANK
+	l, _ = c.Next()          // _STRING
 	rr.Hit = l.token // This can not contain spaces, see RFC 5205 Section 6.
 	rr.HitLength = uint8(len(rr.Hit)) / 2
 
-	<-c                    // _BLANK
-	l = <-c                // _STRING
+	c.Next()                    // _BLANK
+	l, _ = c.Next()                // _STRING
 	rr.PublicKey = l.token // This cannot contain spaces
 	rr.PublicKeyLength = uint16(base64.StdEncoding.DecodedLen(len(rr.PublicKey)))
 
 	// RendezvousServers (if any)
-	l = <-c
+	l, _ = c.Next()
 	xs := make([]string, 0)
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
 		case _STRING:
 			_, ld, ok := IsDomainName(l.token)
 			if !ok {
-				return nil, &ParseError{f, "bad HIP RendezvousServers", l}
+				return nil, &ParseError{f, "bad HIP RendezvousServers", l, ""}
 			}
 			if l.token[ld-1] != '.' {
 				l.token = appendOrigin(l.token, o)
@@ -616,40 +616,40 @@ func setHIP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad HIP RendezvousServers", l}
+			return nil, &ParseError{f, "bad HIP RendezvousServers", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.RendezvousServers = xs
 	return rr, nil
 }
 
-func setCERT(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setCERT(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_CERT)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad CERT Type", l}
+		return nil, &ParseError{f, "bad CERT Type", l, ""}
 	} else {
 		rr.Type = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR KeyTag", l}
+		return nil, &ParseError{f, "bad NAPTR KeyTag", l, ""}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Algorithm", l}
+		return nil, &ParseError{f, "bad NAPTR Algorithm", l, ""}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
 	// Get the remaining data until we see a NEWLINE
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -658,78 +658,78 @@ func setCERT(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad NAPTR Certificate", l}
+			return nil, &ParseError{f, "bad NAPTR Certificate", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Certificate = s
 
 	return rr, nil
 }
 
-func setRRSIG(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setRRSIG(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_RRSIG)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if t, ok := Str_rr[strings.ToUpper(l.token)]; !ok {
-		return nil, &ParseError{f, "bad RRSIG Typecovered", l}
+		return nil, &ParseError{f, "bad RRSIG Typecovered", l, ""}
 	} else {
 		rr.TypeCovered = t
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Algorithm", l}
+		return nil, &ParseError{f, "bad RRSIG Algorithm", l, ""}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Labels", l}
+		return nil, &ParseError{f, "bad RRSIG Labels", l, ""}
 	} else {
 		rr.Labels = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG OrigTtl", l}
+		return nil, &ParseError{f, "bad RRSIG OrigTtl", l, ""}
 	} else {
 		rr.OrigTtl = uint32(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := DateToTime(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Expiration", l}
+		return nil, &ParseError{f, "bad RRSIG Expiration", l, ""}
 	} else {
 		rr.Expiration = i
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := DateToTime(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Inception", l}
+		return nil, &ParseError{f, "bad RRSIG Inception", l, ""}
 	} else {
 		rr.Inception = i
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG KeyTag", l}
+		return nil, &ParseError{f, "bad RRSIG KeyTag", l, ""}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	rr.SignerName = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad RRSIG SignerName", l}
+		return nil, &ParseError{f, "bad RRSIG SignerName", l, ""}
 	}
 	if rr.SignerName[ld-1] != '.' {
 		rr.SignerName = appendOrigin(rr.SignerName, o)
 	}
 	// Get the remaining data until we see a NEWLINE
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -738,23 +738,23 @@ func setRRSIG(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad RRSIG Signature", l}
+			return nil, &ParseError{f, "bad RRSIG Signature", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Signature = s
 	return rr, nil
 }
 
-func setNSEC(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setNSEC(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_NSEC)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	rr.NextDomain = l.token
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad NSEC NextDomain", l}
+		return nil, &ParseError{f, "bad NSEC NextDomain", l, ""}
 	}
 	if rr.NextDomain[ld-1] != '.' {
 		rr.NextDomain = appendOrigin(rr.NextDomain, o)
@@ -762,7 +762,7 @@ func setNSEC(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	rr.TypeBitMap = make([]uint16, 0)
 	var k uint16
-	l = <-c
+	l, _ = c.Next()
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
 		case _BLANK:
@@ -770,52 +770,52 @@ func setNSEC(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _STRING:
 			if k, ok = Str_rr[strings.ToUpper(l.token)]; !ok {
 				if k, ok = typeToInt(l.token); !ok {
-					return nil, &ParseError{f, "bad NSEC TypeBitMap", l}
+					return nil, &ParseError{f, "bad NSEC TypeBitMap", l, ""}
 				}
 			}
 			rr.TypeBitMap = append(rr.TypeBitMap, k)
 		default:
-			return nil, &ParseError{f, "bad NSEC TypeBitMap", l}
+			return nil, &ParseError{f, "bad NSEC TypeBitMap", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	return rr, nil
 }
 
-func setNSEC3(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setNSEC3(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_NSEC3)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Hash", l}
+		return nil, &ParseError{f, "bad NSEC3 Hash", l, ""}
 	} else {
 		rr.Hash = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Flags", l}
+		return nil, &ParseError{f, "bad NSEC3 Flags", l, ""}
 	} else {
 		rr.Flags = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Iterations", l}
+		return nil, &ParseError{f, "bad NSEC3 Iterations", l, ""}
 	} else {
 		rr.Iterations = uint16(i)
 	}
-	<-c
-	l = <-c
+	c.Next()
+	l, _ = c.Next()
 	if len(l.token) == 0 {
-		return nil, &ParseError{f, "bad NSEC3 Salt", l}
+		return nil, &ParseError{f, "bad NSEC3 Salt", l, ""}
 	}
 	rr.SaltLength = uint8(len(l.token)) / 2
 	rr.Salt = l.token
 
-	<-c
-	l = <-c
+	c.Next()
+	l, _ = c.Next()
 	rr.HashLength = 20 // Fix for NSEC3 (sha1 160 bits)
 	rr.NextDomain = l.token
 
@@ -824,7 +824,7 @@ func setNSEC3(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		k  uint16
 		ok bool
 	)
-	l = <-c
+	l, _ = c.Next()
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
 		case _BLANK:
@@ -832,97 +832,97 @@ func setNSEC3(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _STRING:
 			if k, ok = Str_rr[strings.ToUpper(l.token)]; !ok {
 				if k, ok = typeToInt(l.token); !ok {
-					return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l}
+					return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l, ""}
 				}
 			}
 			rr.TypeBitMap = append(rr.TypeBitMap, k)
 		default:
-			return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l}
+			return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	return rr, nil
 }
 
-func setNSEC3PARAM(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setNSEC3PARAM(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_NSEC3PARAM)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Hash", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Hash", l, ""}
 	} else {
 		rr.Hash = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Flags", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Flags", l, ""}
 	} else {
 		rr.Flags = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Iterations", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Iterations", l, ""}
 	} else {
 		rr.Iterations = uint16(i)
 	}
-	<-c
-	l = <-c
+	c.Next()
+	l, _ = c.Next()
 	rr.SaltLength = uint8(len(l.token))
 	rr.Salt = l.token
 	return rr, nil
 }
 
-func setSSHFP(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setSSHFP(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_SSHFP)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SSHFP Algorithm", l}
+		return nil, &ParseError{f, "bad SSHFP Algorithm", l, ""}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SSHFP Type", l}
+		return nil, &ParseError{f, "bad SSHFP Type", l, ""}
 	} else {
 		rr.Type = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	rr.FingerPrint = l.token
 	return rr, nil
 }
 
-func setDNSKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setDNSKEY(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_DNSKEY)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Flags", l}
+		return nil, &ParseError{f, "bad DNSKEY Flags", l, ""}
 	} else {
 		rr.Flags = uint16(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Protocol", l}
+		return nil, &ParseError{f, "bad DNSKEY Protocol", l, ""}
 	} else {
 		rr.Protocol = uint8(i)
 	}
-	<-c     // _BLANK
-	l = <-c // _STRING
+	c.Next()     // _BLANK
+	l, _ = c.Next() // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Algorithm", l}
+		return nil, &ParseError{f, "bad DNSKEY Algorithm", l, ""}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	l = <-c
+	l, _ = c.Next()
 	var s string
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -931,43 +931,43 @@ func setDNSKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad DNSKEY PublicKey", l}
+			return nil, &ParseError{f, "bad DNSKEY PublicKey", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.PublicKey = s
 	return rr, nil
 }
 
-func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setDS(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_DS)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DS KeyTag", l}
+		return nil, &ParseError{f, "bad DS KeyTag", l, ""}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := Str_alg[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad DS Algorithm", l}
+			return nil, &ParseError{f, "bad DS Algorithm", l, ""}
 		} else {
 			rr.Algorithm = i
 		}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DS DigestType", l}
+		return nil, &ParseError{f, "bad DS DigestType", l, ""}
 	} else {
 		rr.DigestType = uint8(i)
 	}
 	// There can be spaces here...
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -976,43 +976,43 @@ func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad DS Digest", l}
+			return nil, &ParseError{f, "bad DS Digest", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Digest = s
 	return rr, nil
 }
 
-func setDLV(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setDLV(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_DLV)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DLV KeyTag", l}
+		return nil, &ParseError{f, "bad DLV KeyTag", l, ""}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := Str_alg[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad DLV Algorithm", l}
+			return nil, &ParseError{f, "bad DLV Algorithm", l, ""}
 		} else {
 			rr.Algorithm = i
 		}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DLV DigestType", l}
+		return nil, &ParseError{f, "bad DLV DigestType", l, ""}
 	} else {
 		rr.DigestType = uint8(i)
 	}
 	// There can be spaces here...
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1021,43 +1021,43 @@ func setDLV(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad DLV Digest", l}
+			return nil, &ParseError{f, "bad DLV Digest", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Digest = s
 	return rr, nil
 }
 
-func setTA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setTA(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_TA)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TA KeyTag", l}
+		return nil, &ParseError{f, "bad TA KeyTag", l, ""}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := Str_alg[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad TA Algorithm", l}
+			return nil, &ParseError{f, "bad TA Algorithm", l, ""}
 		} else {
 			rr.Algorithm = i
 		}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TA DigestType", l}
+		return nil, &ParseError{f, "bad TA DigestType", l, ""}
 	} else {
 		rr.DigestType = uint8(i)
 	}
 	// There can be spaces here...
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1066,39 +1066,39 @@ func setTA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad TA Digest", l}
+			return nil, &ParseError{f, "bad TA Digest", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Digest = s
 	return rr, nil
 }
 
-func setTLSA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setTLSA(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_TLSA)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA Usage", l}
+		return nil, &ParseError{f, "bad TLSA Usage", l, ""}
 	} else {
 		rr.Usage = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA Selector", l}
+		return nil, &ParseError{f, "bad TLSA Selector", l, ""}
 	} else {
 		rr.Selector = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA MatchingType", l}
+		return nil, &ParseError{f, "bad TLSA MatchingType", l, ""}
 	} else {
 		rr.MatchingType = uint8(i)
 	}
 	// There can be spaces here...
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1107,29 +1107,29 @@ func setTLSA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad TLSA Certificate", l}
+			return nil, &ParseError{f, "bad TLSA Certificate", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Certificate = s
 	return rr, nil
 }
 
-func setRFC3597(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setRFC3597(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_RFC3597)
 	rr.Hdr = h
-	l := <-c
+	l, _ := c.Next()
 	if l.token != "\\#" {
-		return nil, &ParseError{f, "unkown RR type", l}
+		return nil, &ParseError{f, "unkown RR type", l, ""}
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	rdlength, e := strconv.Atoi(l.token)
 	if e != nil {
-		return nil, &ParseError{f, "bad RFC3597 Rdata", l}
+		return nil, &ParseError{f, "bad RFC3597 Rdata", l, ""}
 	}
 	// There can be spaces here...
-	l = <-c
+	l, _ = c.Next()
 	s := ""
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1138,24 +1138,24 @@ func setRFC3597(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad RFC3597 Rdata", l}
+			return nil, &ParseError{f, "bad RFC3597 Rdata", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	if rdlength*2 != len(s) {
-		return nil, &ParseError{f, "bad RFC3597 Rdata", l}
+		return nil, &ParseError{f, "bad RFC3597 Rdata", l, ""}
 	}
 	rr.Rdata = s
 	return rr, nil
 }
 
-func setSPF(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setSPF(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_SPF)
 	rr.Hdr = h
 
 	// Get the remaining data until we see a NEWLINE
 	quote := false
-	l := <-c
+	l, _ := c.Next()
 	var s []string
 	switch l.value == _QUOTE {
 	case true: // A number of quoted string
@@ -1167,36 +1167,36 @@ func setSPF(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 			case _BLANK:
 				if quote {
 					// _BLANK can only be seen in between txt parts.
-					return nil, &ParseError{f, "bad SPF Txt", l}
+					return nil, &ParseError{f, "bad SPF Txt", l, ""}
 				}
 			case _QUOTE:
 				quote = !quote
 			default:
-				return nil, &ParseError{f, "bad SPF Txt", l}
+				return nil, &ParseError{f, "bad SPF Txt", l, ""}
 			}
-			l = <-c
+			l, _ = c.Next()
 		}
 		if quote {
-			return nil, &ParseError{f, "bad SPF Txt", l}
+			return nil, &ParseError{f, "bad SPF Txt", l, ""}
 		}
 	case false: // Unquoted text record
 		s = make([]string, 1)
 		for l.value != _NEWLINE && l.value != _EOF {
 			s[0] += l.token
-			l = <-c
+			l, _ = c.Next()
 		}
 	}
 	rr.Txt = s
 	return rr, nil
 }
 
-func setTXT(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setTXT(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_TXT)
 	rr.Hdr = h
 
 	// Get the remaining data until we see a NEWLINE
 	quote := false
-	l := <-c
+	l, _ := c.Next()
 	var s []string
 	switch l.value == _QUOTE {
 	case true: // A number of quoted string
@@ -1208,43 +1208,43 @@ func setTXT(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 			case _BLANK:
 				if quote {
 					// _BLANK can only be seen in between txt parts.
-					return nil, &ParseError{f, "bad TXT Txt", l}
+					return nil, &ParseError{f, "bad TXT Txt", l, ""}
 				}
 			case _QUOTE:
 				quote = !quote
 			default:
-				return nil, &ParseError{f, "bad TXT Txt", l}
+				return nil, &ParseError{f, "bad TXT Txt", l, ""}
 			}
-			l = <-c
+			l, _ = c.Next()
 		}
 		if quote {
-			return nil, &ParseError{f, "bad TXT Txt", l}
+			return nil, &ParseError{f, "bad TXT Txt", l, ""}
 		}
 	case false: // Unquoted text record
 		s = make([]string, 1)
 		for l.value != _NEWLINE && l.value != _EOF {
 			s[0] += l.token
-			l = <-c
+			l, _ = c.Next()
 		}
 	}
 	rr.Txt = s
 	return rr, nil
 }
 
-func setURI(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setURI(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	rr := new(RR_URI)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad URI Priority", l}
+		return nil, &ParseError{f, "bad URI Priority", l, ""}
 	} else {
 		rr.Priority = uint16(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad URI Weight", l}
+		return nil, &ParseError{f, "bad URI Weight", l, ""}
 	} else {
 		rr.Weight = uint16(i)
 	}
@@ -1252,7 +1252,7 @@ func setURI(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	// Get the remaining data until we see a NEWLINE
 	quote := false
-	l = <-c
+	l, _ = c.Next()
 	var s string
 	switch l.value == _QUOTE {
 	case true:
@@ -1263,53 +1263,53 @@ func setURI(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 			case _BLANK:
 				if quote {
 					// _BLANK can only be seen in between txt parts.
-					return nil, &ParseError{f, "bad URI Target", l}
+					return nil, &ParseError{f, "bad URI Target", l, ""}
 				}
 			case _QUOTE:
 				quote = !quote
 			default:
-				return nil, &ParseError{f, "bad URI Target", l}
+				return nil, &ParseError{f, "bad URI Target", l, ""}
 			}
-			l = <-c
+			l, _ = c.Next()
 		}
 		if quote {
-			return nil, &ParseError{f, "bad URI Target", l}
+			return nil, &ParseError{f, "bad URI Target", l, ""}
 		}
 	case false: // Unquoted
-		return nil, &ParseError{f, "bad URI Target", l}
+		return nil, &ParseError{f, "bad URI Target", l, ""}
 	}
 	rr.Target = s
 	return rr, nil
 }
 
-func setIPSECKEY(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
+func setIPSECKEY(h RR_Header, c *zlexer, o, f string) (RR, *ParseError) {
 	rr := new(RR_IPSECKEY)
 	rr.Hdr = h
 
-	l := <-c
+	l, _ := c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY Precedence", l}
+		return nil, &ParseError{f, "bad IPSECKEY Precedence", l, ""}
 	} else {
 		rr.Precedence = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY GatewayType", l}
+		return nil, &ParseError{f, "bad IPSECKEY GatewayType", l, ""}
 	} else {
 		rr.GatewayType = uint8(i)
 	}
-	<-c // _BLANK
-	l = <-c
+	c.Next() // _BLANK
+	l, _ = c.Next()
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY Algorithm", l}
+		return nil, &ParseError{f, "bad IPSECKEY Algorithm", l, ""}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	<-c
-	l = <-c
+	c.Next()
+	l, _ = c.Next()
 	rr.Gateway = l.token
-	l = <-c
+	l, _ = c.Next()
 	var s string
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1318,20 +1318,20 @@ func setIPSECKEY(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad IPSECKEY PublicKey", l}
+			return nil, &ParseError{f, "bad IPSECKEY PublicKey", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.PublicKey = s
 	return rr, nil
 }
 
-func setDHCID(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+func setDHCID(h RR_Header, c *zlexer, f string) (RR, *ParseError) {
 	// awesome record to parse!
 	rr := new(RR_DHCID)
 	rr.Hdr = h
 
-	l := <-c // _STRING
+	l, _ := c.Next() // _STRING
 	var s string
 	for l.value != _NEWLINE && l.value != _EOF {
 		switch l.value {
@@ -1340,9 +1340,9 @@ func setDHCID(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad DHCID Digest", l}
+			return nil, &ParseError{f, "bad DHCID Digest", l, ""}
 		}
-		l = <-c
+		l, _ = c.Next()
 	}
 	rr.Digest = s
 	return rr, nil
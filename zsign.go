@@ -0,0 +1,343 @@
+package dns
+
+// Signing a complete zone, see RFC 4033, RFC 4034 and RFC 4035.
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SigningKey is a DNSKEY together with its private key.
+type SigningKey struct {
+	DNSKEY  *RR_DNSKEY
+	Private PrivateKey
+}
+
+// ZoneSigner signs zones. The zero value is not usable, at least one key
+// must be set.
+type ZoneSigner struct {
+	KSK        []*SigningKey // keys that sign the DNSKEY RRset
+	ZSK        []*SigningKey // keys that sign the other RRsets
+	Inception  uint32        // inception of the signatures, one hour ago when zero
+	Expiration uint32        // expiration of the signatures, Validity after the inception when zero
+	Validity   time.Duration // validity period of the signatures when Expiration is zero, thirty days when zero
+	Jitter     time.Duration // a random duration up to Jitter is subtracted from each validity period
+	Refresh    time.Duration // Resign re-signs the signatures expiring within Refresh, a quarter of Validity when zero
+	Serial     int           // the serial scheme used when Resign changes the zone, see NextSerial
+	Clock      Clock         // if nil the system clock is used
+	// If not nil, an NSEC3 chain with the hash algorithm, iterations and
+	// salt from NSEC3 is made instead of an NSEC chain.
+	NSEC3 *RR_NSEC3PARAM
+	// With NSEC3, leave the delegations without DS out of the chain
+	// (opt-out, RFC 5155 section 6).
+	OptOut bool
+}
+
+// Sign signs the zone with apex origin and returns the signed zone in
+// the order used by WriteZone. The RRSIG, NSEC, NSEC3 and NSEC3PARAM
+// records in rrs are discarded and created anew.
+//
+// The DNSKEYs of all keys are added to the apex, with the TTL of the SOA,
+// if they are not already in the zone. The DNSKEY RRset is signed with
+// the KSKs, all other authoritative RRsets are signed with the ZSKs. When
+// there are no KSKs the ZSKs also sign the DNSKEY RRset, when there are no
+// ZSKs the KSKs sign all RRsets: they are used as combined signing keys
+// (CSKs). At a delegation
+// only the DS RRset is signed; the NS RRset there and the glue below it
+// are not, as they are not authoritative. The NSEC chain links all the
+// authoritative names and the delegations in canonical order, the TTL of
+// the NSEC records is the minimum TTL from the SOA.
+//
+// With NSEC3 an NSEC3PARAM record is added to the apex and the NSEC3 chain
+// also covers the empty non-terminals. With OptOut the delegations without
+// a DS, and the empty non-terminals only leading to them, are left out and
+// the opt-out flag is set in all NSEC3 records. When two names hash to the
+// same value ErrNsec3Hash is returned; sign again with another salt.
+func (s *ZoneSigner) Sign(origin string, rrs []RR) ([]RR, error) {
+	origin = strings.ToLower(Fqdn(origin))
+	zone, err := s.zone(origin, rrs)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := s.chain(zone, origin)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range chain {
+		name := r.Header().Name
+		if _, ok := zone[name]; !ok {
+			zone[name] = make(map[uint16][]RR)
+		}
+		zone[name][r.Header().Rrtype] = []RR{r}
+	}
+	soa := zone[origin][TypeSOA][0].(*RR_SOA)
+	var out []RR
+	for name, types := range zone {
+		for t, rrset := range types {
+			out = append(out, rrset...)
+			if !signable(zone, origin, name, t) {
+				continue
+			}
+			sigs, err := s.sign(s.keys(t), soa.Hdr.Name, rrset)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sigs...)
+		}
+	}
+	sort.Sort(&zoneSorter{out, origin})
+	return out, nil
+}
+
+// chain returns the records of the NSEC chain of the zone, or the
+// NSEC3PARAM and the records of the NSEC3 chain. The zone must not contain
+// DNSSEC records.
+func (s *ZoneSigner) chain(zone map[string]map[uint16][]RR, origin string) ([]RR, error) {
+	if s.NSEC3 != nil {
+		return s.nsec3(zone, origin)
+	}
+	return s.nsec(zone, origin), nil
+}
+
+// nsec returns the NSEC chain of the zone.
+func (s *ZoneSigner) nsec(zone map[string]map[uint16][]RR, origin string) []RR {
+	soa := zone[origin][TypeSOA][0].(*RR_SOA)
+	names := signedNames(zone, origin)
+	chain := make([]RR, len(names))
+	for i, name := range names {
+		next := names[0]
+		if i+1 < len(names) {
+			next = names[i+1]
+		}
+		bitmap := append(signedTypes(zone[name], name != origin), TypeRRSIG, TypeNSEC)
+		sort.Sort(uint16Slice(bitmap))
+		chain[i] = &RR_NSEC{Hdr: RR_Header{Name: name, Rrtype: TypeNSEC, Class: soa.Hdr.Class, Ttl: soa.Minttl},
+			NextDomain: next, TypeBitMap: bitmap}
+	}
+	return chain
+}
+
+// nsec3 returns the NSEC3PARAM and the NSEC3 chain of the zone.
+func (s *ZoneSigner) nsec3(zone map[string]map[uint16][]RR, origin string) ([]RR, error) {
+	soa := zone[origin][TypeSOA][0].(*RR_SOA)
+	param := s.nsec3param(soa)
+	salt := param.Salt
+	flags := uint8(0)
+	if s.OptOut {
+		flags = 1
+	}
+	out := []RR{param}
+
+	// The names in the chain, with the empty non-terminals above them
+	chain := make(map[string]map[uint16][]RR)
+	for _, name := range signedNames(zone, origin) {
+		types := zone[name]
+		if s.OptOut && name != origin && len(types[TypeNS]) > 0 && len(types[TypeDS]) == 0 {
+			continue
+		}
+		chain[name] = types
+		labels := SplitLabels(name)
+		for i := 1; i < len(labels)-CompareLabels(name, origin); i++ {
+			ent := strings.Join(labels[i:], ".") + "."
+			if _, ok := chain[ent]; !ok {
+				chain[ent] = zone[ent] // nil for an empty non-terminal
+			}
+		}
+	}
+	hashes := make([]string, 0, len(chain))
+	owner := make(map[string]string)
+	for name := range chain {
+		h := HashName(name, s.NSEC3.Hash, s.NSEC3.Iterations, salt)
+		if h == "" {
+			return nil, ErrAlg
+		}
+		if _, ok := owner[h]; ok {
+			return nil, ErrNsec3Hash
+		}
+		owner[h] = name
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	for i, h := range hashes {
+		next := hashes[0]
+		if i+1 < len(hashes) {
+			next = hashes[i+1]
+		}
+		name := owner[h]
+		bitmap := signedTypes(chain[name], name != origin)
+		if len(bitmap) > 0 && (name == origin || len(chain[name][TypeNS]) == 0 || len(chain[name][TypeDS]) > 0) {
+			bitmap = append(bitmap, TypeRRSIG)
+		}
+		if name == origin {
+			bitmap = append(bitmap, TypeNSEC3PARAM)
+		}
+		sort.Sort(uint16Slice(bitmap))
+		nsec3 := &RR_NSEC3{Hdr: RR_Header{Name: strings.ToLower(h) + "." + origin, Rrtype: TypeNSEC3, Class: soa.Hdr.Class, Ttl: soa.Minttl},
+			Hash: s.NSEC3.Hash, Flags: flags, Iterations: s.NSEC3.Iterations, SaltLength: uint8(len(salt) / 2), Salt: salt,
+			HashLength: 20, NextDomain: next, TypeBitMap: bitmap}
+		out = append(out, nsec3)
+	}
+	return out, nil
+}
+
+// nsec3param returns the NSEC3PARAM of the zone with SOA soa.
+func (s *ZoneSigner) nsec3param(soa *RR_SOA) *RR_NSEC3PARAM {
+	salt := s.NSEC3.Salt
+	if salt == "-" {
+		salt = ""
+	}
+	return &RR_NSEC3PARAM{Hdr: RR_Header{Name: strings.ToLower(soa.Hdr.Name), Rrtype: TypeNSEC3PARAM, Class: soa.Hdr.Class},
+		Hash: s.NSEC3.Hash, Iterations: s.NSEC3.Iterations, SaltLength: uint8(len(salt) / 2), Salt: salt}
+}
+
+// zone sorts rrs per name and type, drops the existing DNSSEC records and
+// adds the DNSKEYs.
+func (s *ZoneSigner) zone(origin string, rrs []RR) (map[string]map[uint16][]RR, error) {
+	if len(s.KSK)+len(s.ZSK) == 0 {
+		return nil, ErrKey
+	}
+	zone := make(map[string]map[uint16][]RR)
+	for _, r := range rrs {
+		name := strings.ToLower(r.Header().Name)
+		if !IsSubDomain(origin, name) {
+			return nil, &Error{Err: "dns: out of zone data", Name: r.Header().Name}
+		}
+		switch r.Header().Rrtype {
+		case TypeRRSIG, TypeNSEC, TypeNSEC3, TypeNSEC3PARAM:
+			continue
+		}
+		if _, ok := zone[name]; !ok {
+			zone[name] = make(map[uint16][]RR)
+		}
+		zone[name][r.Header().Rrtype] = append(zone[name][r.Header().Rrtype], r)
+	}
+	if len(zone[origin][TypeSOA]) != 1 {
+		return nil, ErrSoa
+	}
+	soa := zone[origin][TypeSOA][0]
+	for _, k := range append(append([]*SigningKey{}, s.KSK...), s.ZSK...) {
+		found := false
+		for _, r := range zone[origin][TypeDNSKEY] {
+			if sameKey(r.(*RR_DNSKEY), k.DNSKEY) {
+				found = true
+			}
+		}
+		if !found {
+			key := *k.DNSKEY
+			key.Hdr = RR_Header{Name: soa.Header().Name, Rrtype: TypeDNSKEY, Class: soa.Header().Class, Ttl: soa.Header().Ttl}
+			zone[origin][TypeDNSKEY] = append(zone[origin][TypeDNSKEY], &key)
+		}
+	}
+	return zone, nil
+}
+
+// keys returns the keys that sign RRsets of type t.
+func (s *ZoneSigner) keys(t uint16) []*SigningKey {
+	if (t == TypeDNSKEY && len(s.KSK) > 0) || len(s.ZSK) == 0 {
+		return s.KSK
+	}
+	return s.ZSK
+}
+
+// sign signs rrset with each of the keys, signer is the name of the zone.
+func (s *ZoneSigner) sign(keys []*SigningKey, signer string, rrset []RR) ([]RR, error) {
+	inception, expiration := s.Inception, s.Expiration
+	if inception == 0 {
+		inception = uint32(s.clock().Now().Unix() - 3600)
+	}
+	if expiration == 0 {
+		validity := s.Validity
+		if validity == 0 {
+			validity = 30 * 24 * time.Hour
+		}
+		if s.Jitter > 0 {
+			validity -= time.Duration(rand.Int63n(int64(s.Jitter)))
+		}
+		expiration = inception + uint32(validity/time.Second)
+	}
+	sigs := make([]RR, 0, len(keys))
+	for _, k := range keys {
+		sig := new(RR_RRSIG)
+		sig.Hdr.Ttl = rrset[0].Header().Ttl
+		sig.Algorithm = k.DNSKEY.Algorithm
+		sig.KeyTag = k.DNSKEY.KeyTag()
+		sig.SignerName = signer
+		sig.Inception = inception
+		sig.Expiration = expiration
+		if err := sig.Sign(k.Private, rrset); err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func (s *ZoneSigner) clock() Clock {
+	if s.Clock == nil {
+		return systemClock{}
+	}
+	return s.Clock
+}
+
+// sameKey checks if the rdata of k1 and k2 is equal.
+func sameKey(k1, k2 *RR_DNSKEY) bool {
+	return k1.Flags == k2.Flags && k1.Protocol == k2.Protocol && k1.Algorithm == k2.Algorithm && k1.PublicKey == k2.PublicKey
+}
+
+// signable checks if the RRset of type t at name must be signed: it is
+// authoritative data and not an RRSIG. At a delegation only the DS and
+// NSEC RRsets are authoritative.
+func signable(zone map[string]map[uint16][]RR, origin, name string, t uint16) bool {
+	switch {
+	case t == TypeRRSIG:
+		return false
+	case name != origin && len(zone[name][TypeNS]) > 0:
+		return t == TypeDS || t == TypeNSEC
+	}
+	return authoritative(zone, origin, name)
+}
+
+// authoritative checks if the zone is authoritative for name: it is not
+// below a delegation.
+func authoritative(zone map[string]map[uint16][]RR, origin, name string) bool {
+	return delegation(zone, origin, name) == "" || delegation(zone, origin, name) == name
+}
+
+// signedNames returns the names that are part of the NSEC chain in
+// canonical order: the authoritative names and the delegations.
+func signedNames(zone map[string]map[uint16][]RR, origin string) []string {
+	names := make([]string, 0, len(zone))
+	for name := range zone {
+		if authoritative(zone, origin, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(nameSlice(names))
+	return names
+}
+
+// signedTypes returns the types of a name with types for its NSEC or
+// NSEC3 type bitmap, without RRSIG and NSEC or NSEC3. At a delegation
+// (cut is true and there is an NS RRset) only NS and DS are included, as
+// the other types are glue.
+func signedTypes(types map[uint16][]RR, cut bool) []uint16 {
+	var bitmap []uint16
+	cut = cut && len(types[TypeNS]) > 0
+	for _, t := range sortedTypes(types) {
+		switch {
+		case t == TypeRRSIG || t == TypeNSEC || t == TypeNSEC3:
+		case cut && t != TypeNS && t != TypeDS:
+		default:
+			bitmap = append(bitmap, t)
+		}
+	}
+	return bitmap
+}
+
+type uint16Slice []uint16
+
+func (p uint16Slice) Len() int           { return len(p) }
+func (p uint16Slice) Less(i, j int) bool { return p[i] < p[j] }
+func (p uint16Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
@@ -0,0 +1,223 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+const signTestZone = `@ 3600 IN SOA ns hostmaster 1 14400 3600 604800 86400
+@ 3600 IN NS ns
+ns 3600 IN A 127.0.0.1
+www 3600 IN A 127.0.0.2
+www 3600 IN A 127.0.0.3
+a.b.c 3600 IN TXT "empty non-terminals"
+sub 3600 IN NS ns.sub
+sub 3600 IN DS 60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118
+ns.sub 3600 IN A 127.0.0.4
+insecure 3600 IN NS ns.example.org.
+`
+
+func signTestKey(t *testing.T, flags uint16) *SigningKey {
+	key := &RR_DNSKEY{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeDNSKEY, Class: ClassINET, Ttl: 3600},
+		Flags: flags, Protocol: 3, Algorithm: RSASHA256}
+	priv, err := key.Generate(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	return &SigningKey{key, priv}
+}
+
+// signedKey identifies an RRset, or its RRSIGs, in a signed zone.
+type signedKey struct {
+	name string
+	t    uint16
+	sig  bool
+}
+
+// signedZone sorts the signed zone per RRset. The RRSIGs are stored under
+// the type they cover.
+func signedZone(rrs []RR) map[signedKey][]RR {
+	zone := make(map[signedKey][]RR)
+	for _, r := range rrs {
+		k := signedKey{r.Header().Name, r.Header().Rrtype, false}
+		if sig, ok := r.(*RR_RRSIG); ok {
+			k = signedKey{r.Header().Name, sig.TypeCovered, true}
+		}
+		zone[k] = append(zone[k], r)
+	}
+	return zone
+}
+
+func TestZoneSigner(t *testing.T) {
+	ksk, zsk := signTestKey(t, ZONE|SEP), signTestKey(t, ZONE)
+	s := &ZoneSigner{KSK: []*SigningKey{ksk}, ZSK: []*SigningKey{zsk}}
+	rrs, err := s.Sign("miek.nl.", parseZoneString(t, signTestZone))
+	if err != nil {
+		t.Fatalf("Failed to sign the zone: %s", err)
+	}
+	if errs := CheckZone("miek.nl.", rrs); len(errs) != 0 {
+		t.Fatalf("Signed zone has problems: %v", errs)
+	}
+	zone := signedZone(rrs)
+	if n := len(zone[signedKey{"miek.nl.", TypeDNSKEY, false}]); n != 2 {
+		t.Fatalf("Expected 2 DNSKEYs, got %d", n)
+	}
+	for k, sigs := range zone {
+		if !k.sig {
+			continue
+		}
+		if len(sigs) != 1 {
+			t.Fatalf("Expected one RRSIG for %s %s, got %d", k.name, typeString(k.t), len(sigs))
+		}
+		key := zsk.DNSKEY
+		if k.t == TypeDNSKEY {
+			key = ksk.DNSKEY
+		}
+		if err := sigs[0].(*RR_RRSIG).Verify(key, zone[signedKey{k.name, k.t, false}]); err != nil {
+			t.Logf("Failed to verify the RRSIG of %s %s: %s", k.name, typeString(k.t), err)
+			t.Fail()
+		}
+	}
+	for _, k := range []signedKey{{"sub.miek.nl.", TypeNS, true}, {"ns.sub.miek.nl.", TypeA, true}, {"insecure.miek.nl.", TypeNS, true}} {
+		if len(zone[k]) != 0 {
+			t.Logf("%s %s should not be signed", k.name, typeString(k.t))
+			t.Fail()
+		}
+	}
+	if len(zone[signedKey{"sub.miek.nl.", TypeDS, true}]) != 1 {
+		t.Log("DS at the delegation is not signed")
+		t.Fail()
+	}
+
+	chain := []struct {
+		name, next, types string
+	}{
+		{"miek.nl.", "a.b.c.miek.nl.", "NS SOA RRSIG NSEC DNSKEY"},
+		{"a.b.c.miek.nl.", "insecure.miek.nl.", "TXT RRSIG NSEC"},
+		{"insecure.miek.nl.", "ns.miek.nl.", "NS RRSIG NSEC"},
+		{"ns.miek.nl.", "sub.miek.nl.", "A RRSIG NSEC"},
+		{"sub.miek.nl.", "www.miek.nl.", "NS DS RRSIG NSEC"},
+		{"www.miek.nl.", "miek.nl.", "A RRSIG NSEC"},
+	}
+	n := 0
+	for _, r := range rrs {
+		if _, ok := r.(*RR_NSEC); ok {
+			n++
+		}
+	}
+	if n != len(chain) {
+		t.Fatalf("Expected %d NSEC records, got %d", len(chain), n)
+	}
+	for _, c := range chain {
+		nsec := zone[signedKey{c.name, TypeNSEC, false}]
+		if len(nsec) != 1 {
+			t.Fatalf("No NSEC for %s", c.name)
+		}
+		if s := nsec[0].String(); s != c.name+"\t86400\tIN\tNSEC\t"+c.next+" "+c.types {
+			t.Logf("Wrong NSEC for %s: %s", c.name, s)
+			t.Fail()
+		}
+	}
+}
+
+// With only KSKs they are used as combined signing keys.
+func TestZoneSignerCsk(t *testing.T) {
+	csk := signTestKey(t, ZONE|SEP)
+	s := &ZoneSigner{KSK: []*SigningKey{csk}}
+	rrs, err := s.Sign("miek.nl.", parseZoneString(t, signTestZone))
+	if err != nil {
+		t.Fatalf("Failed to sign the zone: %s", err)
+	}
+	if errs := CheckZone("miek.nl.", rrs); len(errs) != 0 {
+		t.Fatalf("Signed zone has problems: %v", errs)
+	}
+	zone := signedZone(rrs)
+	for _, k := range []signedKey{{"miek.nl.", TypeSOA, true}, {"miek.nl.", TypeNS, true}, {"miek.nl.", TypeDNSKEY, true},
+		{"www.miek.nl.", TypeA, true}, {"sub.miek.nl.", TypeDS, true}, {"www.miek.nl.", TypeNSEC, true}} {
+		sigs := zone[k]
+		if len(sigs) != 1 {
+			t.Fatalf("Expected one RRSIG for %s %s, got %d", k.name, typeString(k.t), len(sigs))
+		}
+		if err := sigs[0].(*RR_RRSIG).Verify(csk.DNSKEY, zone[signedKey{k.name, k.t, false}]); err != nil {
+			t.Logf("Failed to verify the RRSIG of %s %s: %s", k.name, typeString(k.t), err)
+			t.Fail()
+		}
+	}
+}
+
+func TestZoneSignerNsec3(t *testing.T) {
+	zsk := signTestKey(t, ZONE|SEP)
+	for _, optout := range []bool{false, true} {
+		s := &ZoneSigner{ZSK: []*SigningKey{zsk}, OptOut: optout,
+			NSEC3: &RR_NSEC3PARAM{Hash: SHA1, Iterations: 1, Salt: "AABBCCDD"}}
+		rrs, err := s.Sign("miek.nl.", parseZoneString(t, signTestZone))
+		if err != nil {
+			t.Fatalf("Failed to sign the zone: %s", err)
+		}
+		if errs := CheckZone("miek.nl.", rrs); len(errs) != 0 {
+			t.Fatalf("Signed zone has problems: %v", errs)
+		}
+		zone := signedZone(rrs)
+		if len(zone[signedKey{"miek.nl.", TypeNSEC3PARAM, true}]) != 1 {
+			t.Fatal("No signed NSEC3PARAM")
+		}
+		types := map[string]string{
+			"miek.nl.":          "NS SOA RRSIG DNSKEY NSEC3PARAM",
+			"c.miek.nl.":        "",
+			"b.c.miek.nl.":      "",
+			"a.b.c.miek.nl.":    "TXT RRSIG",
+			"insecure.miek.nl.": "NS",
+			"ns.miek.nl.":       "A RRSIG",
+			"sub.miek.nl.":      "NS DS RRSIG",
+			"www.miek.nl.":      "A RRSIG",
+		}
+		if optout {
+			delete(types, "insecure.miek.nl.")
+		}
+		var chain []*RR_NSEC3
+		for _, r := range rrs {
+			if n, ok := r.(*RR_NSEC3); ok {
+				chain = append(chain, n)
+			}
+		}
+		if len(chain) != len(types) {
+			t.Fatalf("Expected %d NSEC3 records, got %d", len(types), len(chain))
+		}
+		for name, bitmap := range types {
+			found := false
+			for _, n := range chain {
+				if !n.Match(name) {
+					continue
+				}
+				found = true
+				s, want := n.String(), " "+n.NextDomain
+				if bitmap != "" {
+					want += " " + bitmap
+				}
+				if !strings.HasSuffix(s, want) || (n.Flags == 1) != optout {
+					t.Logf("Wrong NSEC3 for %s: %s", name, s)
+					t.Fail()
+				}
+				if err := zone[signedKey{n.Hdr.Name, TypeNSEC3, true}][0].(*RR_RRSIG).Verify(zsk.DNSKEY, []RR{n}); err != nil {
+					t.Logf("Failed to verify the RRSIG of the NSEC3 for %s: %s", name, err)
+					t.Fail()
+				}
+			}
+			if !found {
+				t.Logf("No NSEC3 for %s", name)
+				t.Fail()
+			}
+		}
+		// The chain must be closed
+		next := make(map[string]bool)
+		for _, n := range chain {
+			next[strings.ToLower(n.NextDomain)+".miek.nl."] = true
+		}
+		for _, n := range chain {
+			if !next[n.Hdr.Name] {
+				t.Logf("NSEC3 chain is broken at %s", n.Hdr.Name)
+				t.Fail()
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package dns
+
+// Processing dynamic updates on the server side, see RFC 2136 section 3.
+
+import (
+	"strings"
+	"time"
+)
+
+// Update processes the dynamic update u, a message with OpcodeUpdate, for
+// the zone. It returns the rcode for the response and the difference
+// made to the zone, which is nil when nothing changed.
+//
+// The zone section must name the zone, otherwise RcodeNotAuth is returned.
+// All RRs must be inside the zone (RcodeNotZone). The prerequisites are
+// checked (RcodeYXDomain, RcodeNameError, RcodeYXRrset, RcodeNXRrset) and
+// the updates are prescanned (RcodeFormatError) before anything is changed,
+// so either the whole update is applied or nothing is.
+// Updates that RFC 2136 says to ignore are silently skipped: deleting the
+// SOA or the last NS of the apex, adding a CNAME to a name with other data
+// or other data to a CNAME, and adding a SOA with a serial that is not
+// greater than the current one. When the zone changes and the SOA was not
+// updated explicitly, the serial is set with NextSerial using scheme.
+func (z *Zone) Update(u *Msg, scheme int) (int, *ZoneDiff) {
+	if len(u.Question) != 1 || u.Question[0].Qtype != TypeSOA {
+		return RcodeFormatError, nil
+	}
+	zone := strings.ToLower(z.Origin)
+	zclass := u.Question[0].Qclass
+	if strings.ToLower(Fqdn(u.Question[0].Name)) != zone {
+		return RcodeNotAuth, nil
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if rcode := z.prerequisites(u.Answer, zclass); rcode != RcodeSuccess {
+		return rcode, nil
+	}
+	for _, r := range u.Ns {
+		if rcode := z.prescan(r, zclass); rcode != RcodeSuccess {
+			return rcode, nil
+		}
+	}
+
+	old := z.soa()
+	var soa *RR_SOA // set when the SOA is updated
+	var log []zoneChange
+	for _, r := range u.Ns {
+		h := r.Header()
+		name := strings.ToLower(h.Name)
+		switch h.Class {
+		case zclass:
+			switch h.Rrtype {
+			case TypeSOA:
+				if s, ok := r.(*RR_SOA); ok && name == zone && old != nil && SerialGreater(s.Serial, old.Serial) {
+					soa = s
+				}
+				continue
+			case TypeCNAME:
+				if z.hasOtherThanCname(name) {
+					continue
+				}
+				z.removeRRset(name, TypeCNAME, &log)
+			default:
+				if len(z.names[name][TypeCNAME]) > 0 && h.Rrtype != TypeRRSIG && h.Rrtype != TypeNSEC {
+					continue
+				}
+				// Replace an RR with the same rdata, updating the TTL
+				if r1 := z.findRdata(r); r1 != nil {
+					z.remove(r1)
+					log = append(log, zoneChange{r1, false})
+				}
+			}
+			if z.insert(r) {
+				log = append(log, zoneChange{r, true})
+			}
+		case ClassANY:
+			types := []uint16{h.Rrtype}
+			if h.Rrtype == TypeANY {
+				types = types[:0]
+				for t := range z.names[name] {
+					types = append(types, t)
+				}
+			}
+			for _, t := range types {
+				if name == zone && (t == TypeSOA || t == TypeNS) {
+					continue
+				}
+				z.removeRRset(name, t, &log)
+			}
+		case ClassNONE:
+			if h.Rrtype == TypeSOA || (name == zone && h.Rrtype == TypeNS && len(z.names[name][TypeNS]) == 1) {
+				continue
+			}
+			if r1 := z.findRdata(r); r1 != nil {
+				z.remove(r1)
+				log = append(log, zoneChange{r1, false})
+			}
+		}
+	}
+
+	d := netChanges(log)
+	if len(d.Added) == 0 && len(d.Removed) == 0 && soa == nil {
+		return RcodeSuccess, nil
+	}
+	if soa == nil && old != nil {
+		s := *old
+		s.Serial = NextSerial(old.Serial, scheme, time.Now())
+		soa = &s
+	}
+	if soa != nil {
+		z.insert(soa)
+	}
+	d.OldSoa, d.NewSoa = old, soa
+	return RcodeSuccess, d
+}
+
+// prerequisites checks the prerequisite section of an update, RFC 2136
+// section 3.2.
+func (z *Zone) prerequisites(prereq []RR, zclass uint16) int {
+	temp := make(map[string][]RR) // the value dependent prerequisites, per name and type
+	for _, r := range prereq {
+		h := r.Header()
+		name := strings.ToLower(h.Name)
+		if h.Ttl != 0 {
+			return RcodeFormatError
+		}
+		if !IsSubDomain(strings.ToLower(z.Origin), name) {
+			return RcodeNotZone
+		}
+		switch h.Class {
+		case ClassANY:
+			if h.Rdlength != 0 {
+				return RcodeFormatError
+			}
+			if h.Rrtype == TypeANY {
+				if len(z.names[name]) == 0 {
+					return RcodeNameError
+				}
+			} else if len(z.names[name][h.Rrtype]) == 0 {
+				return RcodeNXRrset
+			}
+		case ClassNONE:
+			if h.Rdlength != 0 {
+				return RcodeFormatError
+			}
+			if h.Rrtype == TypeANY {
+				if len(z.names[name]) != 0 {
+					return RcodeYXDomain
+				}
+			} else if len(z.names[name][h.Rrtype]) != 0 {
+				return RcodeYXRrset
+			}
+		case zclass:
+			k := name + " " + typeString(h.Rrtype)
+			temp[k] = append(temp[k], r)
+		default:
+			return RcodeFormatError
+		}
+	}
+	for _, rrset := range temp {
+		h := rrset[0].Header()
+		zrrset := z.names[strings.ToLower(h.Name)][h.Rrtype]
+		want := make(map[string]bool)
+		for _, r := range rrset {
+			want[rdataString(r)] = true
+		}
+		if len(want) != len(zrrset) {
+			return RcodeNXRrset
+		}
+		for _, r := range zrrset {
+			if !want[rdataString(r)] {
+				return RcodeNXRrset
+			}
+		}
+	}
+	return RcodeSuccess
+}
+
+// prescan checks an RR of the update section, RFC 2136 section 3.4.1.
+func (z *Zone) prescan(r RR, zclass uint16) int {
+	h := r.Header()
+	if !IsSubDomain(strings.ToLower(z.Origin), strings.ToLower(h.Name)) {
+		return RcodeNotZone
+	}
+	meta := h.Rrtype == TypeAXFR || h.Rrtype == TypeIXFR || h.Rrtype == TypeMAILA || h.Rrtype == TypeMAILB
+	switch h.Class {
+	case zclass:
+		if meta || h.Rrtype == TypeANY {
+			return RcodeFormatError
+		}
+	case ClassANY:
+		if h.Ttl != 0 || h.Rdlength != 0 || meta {
+			return RcodeFormatError
+		}
+	case ClassNONE:
+		if h.Ttl != 0 || meta || h.Rrtype == TypeANY {
+			return RcodeFormatError
+		}
+	default:
+		return RcodeFormatError
+	}
+	return RcodeSuccess
+}
+
+// removeRRset removes the RRset with owner name and type t, recording the
+// changes in log.
+func (z *Zone) removeRRset(name string, t uint16, log *[]zoneChange) {
+	rrset := append([]RR{}, z.names[name][t]...)
+	for _, r := range rrset {
+		z.remove(r)
+		*log = append(*log, zoneChange{r, false})
+	}
+}
+
+// findRdata returns the RR in the zone with the same owner name, type and
+// rdata as r, or nil.
+func (z *Zone) findRdata(r RR) RR {
+	k := rdataString(r)
+	for _, r1 := range z.names[strings.ToLower(r.Header().Name)][r.Header().Rrtype] {
+		if rdataString(r1) == k {
+			return r1
+		}
+	}
+	return nil
+}
+
+// hasOtherThanCname checks if name has data that can not exist next to a CNAME.
+func (z *Zone) hasOtherThanCname(name string) bool {
+	for t := range z.names[name] {
+		switch t {
+		case TypeCNAME, TypeRRSIG, TypeNSEC:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// netChanges returns the difference the changes in log result in.
+func netChanges(log []zoneChange) *ZoneDiff {
+	count := make(map[string]int)
+	for _, c := range log {
+		if c.add {
+			count[rrKey(c.r)]++
+		} else {
+			count[rrKey(c.r)]--
+		}
+	}
+	d := new(ZoneDiff)
+	for _, c := range log {
+		k := rrKey(c.r)
+		switch {
+		case count[k] > 0 && c.add:
+			d.Added = append(d.Added, c.r)
+			count[k] = 0
+		case count[k] < 0 && !c.add:
+			d.Removed = append(d.Removed, c.r)
+			count[k] = 0
+		}
+	}
+	return d
+}
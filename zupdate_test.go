@@ -0,0 +1,137 @@
+package dns
+
+import (
+	"testing"
+)
+
+func newRR(t *testing.T, s string) RR {
+	r, err := NewRR(s)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %s", s, err)
+	}
+	return r
+}
+
+func updateTestZone(t *testing.T) *Zone {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{
+		"miek.nl. 3600 IN SOA ns.miek.nl. miek.miek.nl. 10 14400 3600 604800 86400",
+		"miek.nl. 3600 IN NS ns.miek.nl.",
+		"ns.miek.nl. 3600 IN A 127.0.0.1",
+		"www.miek.nl. 3600 IN A 127.0.0.2",
+		"www.miek.nl. 3600 IN A 127.0.0.3",
+		"alias.miek.nl. 3600 IN CNAME www.miek.nl.",
+	} {
+		z.Insert(newRR(t, s))
+	}
+	return z
+}
+
+// wire packs and unpacks u, like a server receives it.
+func wire(t *testing.T, u *Msg) *Msg {
+	buf, ok := u.Pack()
+	if !ok {
+		t.Fatal("Failed to pack update")
+	}
+	m := new(Msg)
+	if !m.Unpack(buf) {
+		t.Fatal("Failed to unpack update")
+	}
+	return m
+}
+
+func TestZoneUpdatePrerequisites(t *testing.T) {
+	tests := []struct {
+		prereq func(u *Msg)
+		rcode  int
+	}{
+		{func(u *Msg) { u.NameUsed([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.1")}) }, RcodeSuccess},
+		{func(u *Msg) { u.NameUsed([]RR{newRR(t, "nx.miek.nl. IN A 127.0.0.1")}) }, RcodeNameError},
+		{func(u *Msg) { u.NameNotUsed([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.1")}) }, RcodeYXDomain},
+		{func(u *Msg) { u.RRsetUsedNoRdata([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.1")}) }, RcodeSuccess},
+		{func(u *Msg) { u.RRsetUsedNoRdata([]RR{newRR(t, "www.miek.nl. IN MX 10 mx.miek.nl.")}) }, RcodeNXRrset},
+		{func(u *Msg) { u.RRsetNotUsed([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.1")}) }, RcodeYXRrset},
+		{func(u *Msg) {
+			u.RRsetUsedRdata([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.2"), newRR(t, "www.miek.nl. IN A 127.0.0.3")})
+		}, RcodeSuccess},
+		{func(u *Msg) { u.RRsetUsedRdata([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.2")}) }, RcodeNXRrset},
+		{func(u *Msg) { u.NameUsed([]RR{newRR(t, "www.example.org. IN A 127.0.0.1")}) }, RcodeNotZone},
+	}
+	for i, tc := range tests {
+		z := updateTestZone(t)
+		u := NewUpdate("miek.nl.", ClassINET)
+		tc.prereq(u)
+		if rcode, _ := z.Update(wire(t, u), SerialIncrement); rcode != tc.rcode {
+			t.Logf("Test %d: expected rcode %s, got %s", i, Rcode_str[tc.rcode], Rcode_str[rcode])
+			t.Fail()
+		}
+	}
+	z := updateTestZone(t)
+	if rcode, _ := z.Update(NewUpdate("example.org.", ClassINET), SerialIncrement); rcode != RcodeNotAuth {
+		t.Logf("Expected NOTAUTH for another zone, got %s", Rcode_str[rcode])
+		t.Fail()
+	}
+}
+
+func TestZoneUpdate(t *testing.T) {
+	z := updateTestZone(t)
+	u := NewUpdate("miek.nl.", ClassINET)
+	u.RRsetAddRdata([]RR{
+		newRR(t, "new.miek.nl. 300 IN A 127.0.0.4"),
+		newRR(t, "alias.miek.nl. 300 IN A 127.0.0.5"),     // ignored, CNAME
+		newRR(t, "www.miek.nl. 300 IN CNAME ns.miek.nl."), // ignored, other data
+	})
+	rcode, d := z.Update(wire(t, u), SerialIncrement)
+	if rcode != RcodeSuccess || d == nil || len(d.Added) != 1 || len(d.Removed) != 0 {
+		t.Fatalf("Failed to add: %s %v", Rcode_str[rcode], d)
+	}
+	if d.OldSoa.Serial != 10 || d.NewSoa.Serial != 11 || z.Soa().Serial != 11 {
+		t.Fatalf("Serial not incremented")
+	}
+
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.RRsetDeleteRR([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.2"), newRR(t, "miek.nl. IN NS ns.miek.nl.")})
+	if rcode, d = z.Update(wire(t, u), SerialIncrement); rcode != RcodeSuccess || len(d.Removed) != 1 {
+		t.Fatalf("Failed to delete RR: %s %v", Rcode_str[rcode], d)
+	}
+	if len(z.RRset("miek.nl.", TypeNS)) != 1 {
+		t.Fatal("Last NS of the apex deleted")
+	}
+
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.RRsetDelete([]RR{newRR(t, "www.miek.nl. IN A 127.0.0.3"), newRR(t, "miek.nl. IN SOA ns.miek.nl. miek.miek.nl. 10 14400 3600 604800 86400")})
+	if rcode, d = z.Update(wire(t, u), SerialIncrement); rcode != RcodeSuccess || len(d.Removed) != 1 || z.Soa() == nil {
+		t.Fatalf("Failed to delete RRset: %s %v", Rcode_str[rcode], d)
+	}
+
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.NameDelete([]RR{newRR(t, "miek.nl. IN A 127.0.0.1"), newRR(t, "alias.miek.nl. IN A 127.0.0.1")})
+	if rcode, d = z.Update(wire(t, u), SerialIncrement); rcode != RcodeSuccess || len(d.Removed) != 1 {
+		t.Fatalf("Failed to delete name: %s %v", Rcode_str[rcode], d)
+	}
+	if z.Soa() == nil || len(z.RRset("miek.nl.", TypeNS)) != 1 || len(z.RRset("alias.miek.nl.", TypeCNAME)) != 0 {
+		t.Fatal("Deleting all RRsets of the apex should keep SOA and NS")
+	}
+
+	// Explicit SOA update, with a greater serial
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.RRsetAddRdata([]RR{newRR(t, "miek.nl. 3600 IN SOA ns.miek.nl. miek.miek.nl. 100 14400 3600 604800 86400")})
+	if rcode, d = z.Update(wire(t, u), SerialIncrement); rcode != RcodeSuccess || z.Soa().Serial != 100 {
+		t.Fatalf("Failed to update SOA: %s", Rcode_str[rcode])
+	}
+
+	// Nothing changes, no new serial
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.RRsetDeleteRR([]RR{newRR(t, "nx.miek.nl. IN A 127.0.0.2")})
+	if rcode, d = z.Update(wire(t, u), SerialIncrement); rcode != RcodeSuccess || d != nil || z.Soa().Serial != 100 {
+		t.Fatalf("Expected no change")
+	}
+
+	// Out of zone update, nothing applied
+	n := z.Len()
+	u = NewUpdate("miek.nl.", ClassINET)
+	u.RRsetAddRdata([]RR{newRR(t, "a.miek.nl. IN A 127.0.0.1"), newRR(t, "www.example.org. IN A 127.0.0.1")})
+	if rcode, _ = z.Update(wire(t, u), SerialIncrement); rcode != RcodeNotZone || z.Len() != n {
+		t.Fatalf("Expected NOTZONE and no changes, got %s", Rcode_str[rcode])
+	}
+}
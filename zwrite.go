@@ -0,0 +1,162 @@
+package dns
+
+// Write a set of RRs as a RFC 1035 zone file.
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteZone writes the RRs in rrs as a zone file to w. The output starts
+// with an $ORIGIN origin and a $TTL directive, the $TTL is set to the most
+// used TTL in rrs. After that the SOA record(s) of the apex follow, and then
+// the rest of the RRs grouped by owner name in canonical (RFC 4034) order.
+// Owner names are written relative to origin and are left blank when they
+// are equal to the owner name of the previous RR. The TTL is only written
+// when it differs from the $TTL. The columns are aligned.
+// Reading the output back with ParseZone yields the same set of RRs.
+func WriteZone(w io.Writer, origin string, rrs []RR) error {
+	origin = Fqdn(origin)
+	zone := make([]RR, len(rrs))
+	copy(zone, rrs)
+	sort.Sort(&zoneSorter{zone, origin})
+
+	defttl := zoneTtl(rrs)
+	lines := make([][4]string, len(zone)) // owner, ttl, class and type of each RR
+	var width [4]int
+	// The parser makes each explicit TTL the default for the following RRs,
+	// only leave the TTL out when both the parser and $TTL agree.
+	curttl := defttl
+	prev := ""
+	for i, r := range zone {
+		h := r.Header()
+		if h.Name != prev {
+			lines[i][0] = relativeName(h.Name, origin)
+			prev = h.Name
+		}
+		if h.Ttl != defttl || curttl != defttl {
+			lines[i][1] = strconv.FormatInt(int64(h.Ttl), 10)
+			curttl = h.Ttl
+		}
+		if _, ok := Class_str[h.Class]; ok {
+			lines[i][2] = Class_str[h.Class]
+		} else {
+			lines[i][2] = "CLASS" + strconv.Itoa(int(h.Class))
+		}
+		if _, ok := Rr_str[h.Rrtype]; ok {
+			lines[i][3] = Rr_str[h.Rrtype]
+		} else {
+			lines[i][3] = "TYPE" + strconv.Itoa(int(h.Rrtype))
+		}
+		for j := 0; j < 4; j++ {
+			if len(lines[i][j]) > width[j] {
+				width[j] = len(lines[i][j])
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, "$ORIGIN "+origin+"\n$TTL "+strconv.FormatInt(int64(defttl), 10)+"\n"); err != nil {
+		return err
+	}
+	for i, r := range zone {
+		s := ""
+		for j := 0; j < 4; j++ {
+			if width[j] == 0 {
+				continue
+			}
+			s += lines[i][j] + strings.Repeat(" ", width[j]-len(lines[i][j])+1)
+		}
+		s += rdataString(r) + "\n"
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rdataString returns the rdata of r in its text representation: what is
+// left of the presentation format after the header.
+func rdataString(r RR) string {
+	return strings.TrimLeft(strings.TrimPrefix(r.String(), r.Header().String()), " \t")
+}
+
+// zoneTtl returns the TTL used most in rrs, or DefaultTtl when rrs is empty.
+func zoneTtl(rrs []RR) uint32 {
+	count := make(map[uint32]int)
+	ttl := uint32(DefaultTtl)
+	max := 0
+	for _, r := range rrs {
+		t := r.Header().Ttl
+		count[t]++
+		if count[t] > max || (count[t] == max && t < ttl) {
+			max = count[t]
+			ttl = t
+		}
+	}
+	return ttl
+}
+
+// relativeName returns s relative to origin, or s itself when it is not
+// below origin. The origin itself is returned as "@".
+func relativeName(s, origin string) string {
+	if strings.ToLower(s) == strings.ToLower(origin) {
+		return "@"
+	}
+	if origin == "." {
+		return s[:len(s)-1]
+	}
+	if IsSubDomain(strings.ToLower(origin), strings.ToLower(s)) {
+		return s[:len(s)-len(origin)-1]
+	}
+	return s
+}
+
+// zoneSorter sorts RRs in the order used by WriteZone: the apex first,
+// then the other names in the zone and then the names outside of it. Each
+// of these is in canonical name order. Within a name the RRs are sorted on
+// type, with the exception that SOA and NS come first. RRs of the same type
+// are sorted on their text representation to make the order stable.
+type zoneSorter struct {
+	rrs    []RR
+	origin string
+}
+
+func (z *zoneSorter) Len() int      { return len(z.rrs) }
+func (z *zoneSorter) Swap(i, j int) { z.rrs[i], z.rrs[j] = z.rrs[j], z.rrs[i] }
+func (z *zoneSorter) Less(i, j int) bool {
+	hi, hj := z.rrs[i].Header(), z.rrs[j].Header()
+	if pi, pj := z.place(hi.Name), z.place(hj.Name); pi != pj {
+		return pi < pj
+	}
+	if c := compareNames(hi.Name, hj.Name); c != 0 {
+		return c < 0
+	}
+	if hi.Rrtype != hj.Rrtype {
+		return typeOrder(hi.Rrtype) < typeOrder(hj.Rrtype)
+	}
+	return z.rrs[i].String() < z.rrs[j].String()
+}
+
+// place returns 0 for the apex, 1 for names in the zone and 2 for the rest.
+func (z *zoneSorter) place(s string) int {
+	switch {
+	case compareNames(s, z.origin) == 0:
+		return 0
+	case IsSubDomain(strings.ToLower(z.origin), strings.ToLower(s)):
+		return 1
+	}
+	return 2
+}
+
+// typeOrder returns a sort key for an RR type, sorting SOA and NS first.
+func typeOrder(t uint16) int {
+	switch t {
+	case TypeSOA:
+		return -2
+	case TypeNS:
+		return -1
+	}
+	return int(t)
+}
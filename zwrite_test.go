@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriteZone(t *testing.T) {
+	zone := `$ORIGIN miek.nl.
+www	300	IN	A	127.0.0.1
+@	3600	IN	NS	linode.atoom.net.
+a	3600	IN	MX	10 mx.miek.nl.
+@	3600	IN	SOA	linode.atoom.net. miek.miek.nl. 1282630057 14400 3600 604800 86400
+www	3600	IN	TXT	"hello world"
+a.b	60	IN	AAAA	::1
+	3600	IN	A	127.0.0.2
+@	3600	IN	NS	ns.example.org.
+glue.example.org.	3600	IN	A	127.0.0.3
+`
+	var rrs []RR
+	for x := range ParseZone(strings.NewReader(zone), "", "") {
+		if x.Error != nil {
+			t.Fatalf("Failed to parse the zone: %s", x.Error)
+		}
+		rrs = append(rrs, x.RR)
+	}
+	out := new(bytes.Buffer)
+	if err := WriteZone(out, "miek.nl.", rrs); err != nil {
+		t.Fatalf("Failed to write the zone: %s", err)
+	}
+	t.Logf("\n%s", out.String())
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "$ORIGIN miek.nl." || lines[1] != "$TTL 3600" {
+		t.Logf("Bad directives: %q %q", lines[0], lines[1])
+		t.Fail()
+	}
+	if !strings.HasPrefix(lines[2], "@ ") || !strings.Contains(lines[2], "SOA") {
+		t.Logf("SOA should be the first RR: %q", lines[2])
+		t.Fail()
+	}
+
+	var rrs1 []RR
+	for x := range ParseZone(out, "", "") {
+		if x.Error != nil {
+			t.Fatalf("Failed to parse the written zone: %s", x.Error)
+		}
+		rrs1 = append(rrs1, x.RR)
+	}
+	if len(rrs) != len(rrs1) {
+		t.Fatalf("Expected %d RRs, got %d", len(rrs), len(rrs1))
+	}
+	s, s1 := make([]string, len(rrs)), make([]string, len(rrs1))
+	for i := range rrs {
+		s[i] = rrs[i].String()
+		s1[i] = rrs1[i].String()
+	}
+	sort.Strings(s)
+	sort.Strings(s1)
+	for i := range s {
+		if s[i] != s1[i] {
+			t.Logf("RR differs after writing: %s != %s", s[i], s1[i])
+			t.Fail()
+		}
+	}
+}